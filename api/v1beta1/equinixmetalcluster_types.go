@@ -17,19 +17,74 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
+const (
+	// ClusterFinalizer allows ReconcileEquinixMetalCluster to clean up EquinixMetal resources before
+	// removing it from the apiserver.
+	ClusterFinalizer = "equinixmetalcluster.infrastructure.cluster.x-k8s.io"
+
+	// MaxHourlySpendAnnotation sets the budget of a Cluster or a Namespace, as the maximum hourly price in
+	// US dollars of all their devices, such as "25.5". The creation of EquinixMetalMachines and
+	// EquinixMetalMachineTemplates that would exceed it is rejected.
+	MaxHourlySpendAnnotation = "equinixmetal.infrastructure.cluster.x-k8s.io/max-hourly-spend"
+)
+
 const (
 	// NetworkInfrastructureReadyCondition reports of current status of cluster infrastructure.
 	NetworkInfrastructureReadyCondition clusterv1.ConditionType = "NetworkInfrastructureReady"
+
+	// ControlPlaneEndpointFailedReason used when the control plane endpoint IP couldn't be reserved.
+	ControlPlaneEndpointFailedReason = "ControlPlaneEndpointFailed"
+	// ControlPlaneEndpointInvalidReason used when the control plane endpoint of an externally managed
+	// cluster is missing or invalid.
+	ControlPlaneEndpointInvalidReason = "ControlPlaneEndpointInvalid"
+)
+
+const (
+	// ProjectReadyCondition reports on the creation of the project of clusters with a managed project.
+	ProjectReadyCondition clusterv1.ConditionType = "ProjectReady"
+
+	// ProjectCreationFailedReason used when the project of the cluster couldn't be created.
+	ProjectCreationFailedReason = "ProjectCreationFailed"
+	// ProjectAccessFailedReason used when the project of an externally managed cluster does not exist or
+	// the API key is not allowed to access it.
+	ProjectAccessFailedReason = "ProjectAccessFailed"
+	// WaitingForDevicesDeletionReason used when the project of the cluster is waiting for its devices
+	// to be deleted before being deleted.
+	WaitingForDevicesDeletionReason = "WaitingForDevicesDeletion"
+)
+
+const (
+	// SSHKeysReadyCondition reports on the registration of the SSH keys of the cluster in its project.
+	SSHKeysReadyCondition clusterv1.ConditionType = "SSHKeysReady"
+
+	// SSHKeysFailedReason used when the SSH keys of the cluster couldn't be read or registered.
+	SSHKeysFailedReason = "SSHKeysFailed"
+)
+
+const (
+	// HardwareReservationsRetainedCondition reports on the hardware reservations retained for the machines of
+	// the cluster by the HardwareReservationDeletePolicy of deleted EquinixMetalMachines.
+	HardwareReservationsRetainedCondition clusterv1.ConditionType = "HardwareReservationsRetained"
 )
 
 // EquinixMetalClusterSpec defines the desired state of EquinixMetalCluster.
 type EquinixMetalClusterSpec struct {
 	// ProjectID represents the Equinix Metal Project where this cluster will be placed into.
-	ProjectID string `json:"projectID"`
+	// It is set to the ID of the created project when Project is set.
+	// +optional
+	ProjectID string `json:"projectID,omitempty"`
+
+	// Project makes the cluster create a dedicated Equinix Metal project, and delete it once all its
+	// devices are gone when the cluster is deleted. ProjectID must not be set along with it.
+	// The project is marked as created for the cluster in its custom data: an existing project with
+	// the same name is never used, and only the project created for the cluster is deleted.
+	// +optional
+	Project *ManagedProject `json:"project,omitempty"`
 
 	// Metro represents the Equinix Metal metro for this cluster.
 	Metro string `json:"metro,omitempty"`
@@ -40,6 +95,48 @@ type EquinixMetalClusterSpec struct {
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
 	// +optional
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
+
+	// SSHKeys are the public SSH keys registered in the project and attached to every device of the
+	// cluster. They are removed from the project when the cluster is deleted.
+	// +optional
+	SSHKeys *SSHKeySources `json:"sshKeys,omitempty"`
+
+	// DeletionProtection protects all the devices of the cluster and the EquinixMetalCluster until their
+	// deletion is confirmed with the DeletionConfirmedAnnotation, see docs/deletion-protection.md.
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+}
+
+// SSHKeySources are the sources of the public SSH keys of a cluster.
+//
+// The keys are attached to the devices as their project_ssh_keys. Equinix Metal then only authorizes these
+// keys: the other SSH keys of the project, and the keys of the project members and of the organization,
+// which are attached to devices by default, are no longer attached to the devices of the cluster.
+type SSHKeySources struct {
+	// SecretRefs reference Secrets in the namespace of the cluster whose values are public SSH keys,
+	// one per line in the authorized_keys format. Lines that are not valid keys fail the reconciliation.
+	// +optional
+	SecretRefs []corev1.LocalObjectReference `json:"secretRefs,omitempty"`
+
+	// ConfigMapRefs reference ConfigMaps in the namespace of the cluster whose values are public SSH keys,
+	// one per line in the authorized_keys format. Lines that are not valid keys fail the reconciliation.
+	// +optional
+	ConfigMapRefs []corev1.LocalObjectReference `json:"configMapRefs,omitempty"`
+
+	// Generate generates a keypair for the cluster, stored in the Secret "<name>-ssh-keypair" of type
+	// kubernetes.io/ssh-auth.
+	// +optional
+	Generate bool `json:"generate,omitempty"`
+}
+
+// ManagedProject describes the Equinix Metal project created for a cluster.
+type ManagedProject struct {
+	// OrganizationID is the Equinix Metal organization the project is created in.
+	OrganizationID string `json:"organizationID"`
+
+	// Name is the name of the project. Defaults to "<namespace>-<cluster name>".
+	// +optional
+	Name string `json:"name,omitempty"`
 }
 
 // EquinixMetalClusterStatus defines the observed state of EquinixMetalCluster.
@@ -54,11 +151,50 @@ type EquinixMetalClusterStatus struct {
 	// +optional
 	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
 
+	// SSHKeyIDs are the IDs of the project SSH keys registered for the cluster.
+	// +optional
+	SSHKeyIDs []string `json:"sshKeyIDs,omitempty"`
+
+	// Cost is the running cost of the devices of the cluster.
+	// +optional
+	Cost *ClusterCost `json:"cost,omitempty"`
+
+	// RetainedHardwareReservations are the provisionable hardware reservations of the project tagged for the
+	// machines of the cluster by the HardwareReservationDeletePolicy of deleted EquinixMetalMachines.
+	// +optional
+	RetainedHardwareReservations []RetainedHardwareReservation `json:"retainedHardwareReservations,omitempty"`
+
 	// Conditions defines current service state of the EquinixMetalCluster.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
+// RetainedHardwareReservation is a hardware reservation kept for the machines of a cluster.
+type RetainedHardwareReservation struct {
+	// ID is the ID of the hardware reservation.
+	ID string `json:"id"`
+
+	// Pool is the name of the MachineDeployment whose machines reuse the hardware reservation,
+	// "control-plane" for control plane machines, or empty for the other machines of the cluster.
+	// +optional
+	Pool string `json:"pool,omitempty"`
+
+	// MachineType is the EquinixMetal plan of the hardware reservation.
+	MachineType string `json:"machineType"`
+}
+
+// ClusterCost is the aggregated price of the devices of a cluster.
+type ClusterCost struct {
+	// Machines is the number of EquinixMetalMachines whose device cost is known.
+	Machines int32 `json:"machines"`
+
+	// HourlyPrice is the price of the devices of the cluster per hour in US dollars.
+	HourlyPrice string `json:"hourlyPrice"`
+
+	// MonthlyPrice is the price of the devices of the cluster per month in US dollars.
+	MonthlyPrice string `json:"monthlyPrice"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:path=equinixmetalclusters,scope=Namespaced,categories=cluster-api
@@ -67,6 +203,12 @@ type EquinixMetalClusterStatus struct {
 //+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="EquinixMetalCluster ready status"
 
 // EquinixMetalCluster is the Schema for the equinixmetalclusters API.
+//
+// An EquinixMetalCluster annotated with the Cluster API "cluster.x-k8s.io/managed-by" annotation is
+// externally managed: its project and control plane endpoint are provided by another system, such as
+// Terraform. The controller then only checks that the project is accessible and that the control plane
+// endpoint is set before marking the cluster ready, and never reserves nor releases any network
+// resources, including on deletion.
 type EquinixMetalCluster struct {
 	metav1.TypeMeta   `json:",inline"` //nolint:tagliatelle
 	metav1.ObjectMeta `json:"metadata,omitempty"`
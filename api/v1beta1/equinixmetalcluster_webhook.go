@@ -17,26 +17,88 @@ limitations under the License.
 package v1beta1
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// MaxPort is the highest valid port of a control plane endpoint.
+const MaxPort = 65535
+
+var (
+	// ErrDeletionProtected is returned when deleting an EquinixMetalCluster with deletion protection.
+	ErrDeletionProtected = errors.New("deletion protection is enabled")
+
+	errUnexpectedObject = errors.New("unexpected object")
+)
+
 // SetupWebhookWithManager sets up and registers the webhook with the manager.
 func (c *EquinixMetalCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	if err := ctrl.NewWebhookManagedBy(mgr).For(c).Complete(); err != nil {
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(c).
+		WithValidator(&equinixMetalClusterValidator{Client: mgr.GetClient()}).
+		Complete(); err != nil {
 		return fmt.Errorf("failed to create EquinixMetalCluster webhook: %w", err)
 	}
 
 	return nil
 }
 
-//+kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-equinixmetalcluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalclusters,versions=v1beta1,name=validation.equinixmetalcluster.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// equinixMetalClusterValidator validates EquinixMetalClusters like their webhook.Validator implementation,
+// except that the deletion of the EquinixMetalClusters of paused Clusters is not checked, so that
+// clusterctl move can delete the EquinixMetalClusters it moved from their source management cluster.
+type equinixMetalClusterValidator struct {
+	Client client.Client
+}
+
+func (v *equinixMetalClusterValidator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	c, ok := obj.(*EquinixMetalCluster)
+	if !ok {
+		return fmt.Errorf("%w: expected an EquinixMetalCluster, got %T", errUnexpectedObject, obj)
+	}
+
+	return c.ValidateCreate()
+}
+
+func (v *equinixMetalClusterValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	c, ok := newObj.(*EquinixMetalCluster)
+	if !ok {
+		return fmt.Errorf("%w: expected an EquinixMetalCluster, got %T", errUnexpectedObject, newObj)
+	}
+
+	return c.ValidateUpdate(oldObj)
+}
+
+func (v *equinixMetalClusterValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	c, ok := obj.(*EquinixMetalCluster)
+	if !ok {
+		return fmt.Errorf("%w: expected an EquinixMetalCluster, got %T", errUnexpectedObject, obj)
+	}
+
+	cluster, err := util.GetOwnerCluster(ctx, v.Client, c.ObjectMeta)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return apierrors.NewInternalError(fmt.Errorf("failed to get owner Cluster: %w", err))
+	}
+
+	if cluster != nil && cluster.Spec.Paused {
+		return nil
+	}
+
+	return c.ValidateDelete()
+}
+
+//+kubebuilder:webhook:verbs=create;update;delete,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-equinixmetalcluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalclusters,versions=v1beta1,name=validation.equinixmetalcluster.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
 //+kubebuilder:webhook:verbs=create;update,path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-equinixmetalcluster,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalclusters,versions=v1beta1,name=default.equinixmetalcluster.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
 
 // Default implements webhook.Defaulter so a webhook will be registered for the type.
@@ -50,7 +112,26 @@ func (c *EquinixMetalCluster) ValidateCreate() error {
 	clusterlog := logf.Log.WithName("equinixmetalcluster-resource")
 	clusterlog.Info("validate create", "name", c.Name)
 
-	return nil
+	var allErrs field.ErrorList
+
+	switch {
+	case c.Spec.Project != nil && c.Spec.ProjectID != "":
+		allErrs = append(allErrs,
+			field.Forbidden(field.NewPath("spec", "projectID"), "must not be set along with spec.project"),
+		)
+	case c.Spec.Project == nil && c.Spec.ProjectID == "":
+		allErrs = append(allErrs,
+			field.Required(field.NewPath("spec", "projectID"), "either spec.projectID or spec.project must be set"),
+		)
+	}
+
+	allErrs = append(allErrs, c.validateExternallyManaged()...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("EquinixMetalCluster").GroupKind(), c.Name, allErrs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
@@ -62,13 +143,23 @@ func (c *EquinixMetalCluster) ValidateUpdate(oldRaw runtime.Object) error {
 
 	old, _ := oldRaw.(*EquinixMetalCluster)
 
-	if !reflect.DeepEqual(c.Spec.ProjectID, old.Spec.ProjectID) {
+	// The ID of a managed project is recorded once it is created.
+	projectCreated := c.Spec.Project != nil && old.Spec.ProjectID == ""
+
+	if !reflect.DeepEqual(c.Spec.ProjectID, old.Spec.ProjectID) && !projectCreated {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "projectID"),
 				c.Spec.ProjectID, "field is immutable"),
 		)
 	}
 
+	if !reflect.DeepEqual(c.Spec.Project, old.Spec.Project) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "project"),
+				c.Spec.Project, "field is immutable"),
+		)
+	}
+
 	if !reflect.DeepEqual(c.Spec.Facility, old.Spec.Facility) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "Facility"),
@@ -76,6 +167,8 @@ func (c *EquinixMetalCluster) ValidateUpdate(oldRaw runtime.Object) error {
 		)
 	}
 
+	allErrs = append(allErrs, c.validateExternallyManaged()...)
+
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -83,10 +176,63 @@ func (c *EquinixMetalCluster) ValidateUpdate(oldRaw runtime.Object) error {
 	return apierrors.NewInvalid(GroupVersion.WithKind("EquinixMetalCluster").GroupKind(), c.Name, allErrs)
 }
 
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type. Deletion
+// protection is not checked on paused EquinixMetalClusters, which clusterctl move deletes once moved.
 func (c *EquinixMetalCluster) ValidateDelete() error {
 	clusterlog := logf.Log.WithName("equinixmetalcluster-resource")
 	clusterlog.Info("validate delete", "name", c.Name)
 
+	if annotations.HasPausedAnnotation(c) {
+		return nil
+	}
+
+	if _, confirmed := c.Annotations[DeletionConfirmedAnnotation]; c.Spec.DeletionProtection && !confirmed {
+		return apierrors.NewForbidden(GroupVersion.WithResource("equinixmetalclusters").GroupResource(), c.Name,
+			fmt.Errorf("%w: set the %s annotation to confirm the deletion", ErrDeletionProtected, DeletionConfirmedAnnotation))
+	}
+
 	return nil
 }
+
+// validateExternallyManaged checks that an externally managed cluster provides the infrastructure that
+// the controller would otherwise create.
+func (c *EquinixMetalCluster) validateExternallyManaged() field.ErrorList {
+	if _, ok := c.Annotations[clusterv1.ManagedByAnnotation]; !ok {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+
+	if c.Spec.ProjectID == "" {
+		allErrs = append(allErrs,
+			field.Required(field.NewPath("spec", "projectID"), "must be set on externally managed clusters"),
+		)
+	}
+
+	if c.Spec.Project != nil {
+		allErrs = append(allErrs,
+			field.Forbidden(field.NewPath("spec", "project"), "projects of externally managed clusters are not managed"),
+		)
+	}
+
+	if c.Spec.SSHKeys != nil {
+		allErrs = append(allErrs,
+			field.Forbidden(field.NewPath("spec", "sshKeys"), "SSH keys of externally managed clusters are not managed"),
+		)
+	}
+
+	if c.Spec.ControlPlaneEndpoint.Host == "" {
+		allErrs = append(allErrs,
+			field.Required(field.NewPath("spec", "controlPlaneEndpoint", "host"),
+				"must be set on externally managed clusters"),
+		)
+	}
+
+	if port := c.Spec.ControlPlaneEndpoint.Port; port < 1 || port > MaxPort {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "controlPlaneEndpoint", "port"), port, "must be a valid port number"),
+		)
+	}
+
+	return allErrs
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEquinixMetalClusterValidateDelete(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to create scheme: %v", err)
+	}
+
+	newCluster := func(name string, paused bool) *clusterv1.Cluster {
+		return &clusterv1.Cluster{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name}, //nolint:exhaustivestruct
+			Spec:       clusterv1.ClusterSpec{Paused: paused},          //nolint:exhaustivestruct
+		}
+	}
+
+	validator := &equinixMetalClusterValidator{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(newCluster("running", false), newCluster("paused", true)).
+			Build(),
+	}
+
+	tests := []struct {
+		name        string
+		protected   bool
+		annotations map[string]string
+		owner       string
+		wantErr     bool
+	}{
+		{name: "unprotected", owner: "running"},
+		{name: "protected", protected: true, owner: "running", wantErr: true},
+		{name: "protected without owner", protected: true, wantErr: true},
+		{name: "protected with missing owner", protected: true, owner: "missing", wantErr: true},
+		{
+			name:        "confirmed",
+			protected:   true,
+			annotations: map[string]string{DeletionConfirmedAnnotation: ""},
+			owner:       "running",
+		},
+		{
+			name:        "paused annotation",
+			protected:   true,
+			annotations: map[string]string{clusterv1.PausedAnnotation: ""},
+			owner:       "running",
+		},
+		{name: "paused owner", protected: true, owner: "paused"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &EquinixMetalCluster{ //nolint:exhaustivestruct
+				ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustivestruct
+					Namespace:   "ns",
+					Name:        "cluster",
+					Annotations: tt.annotations,
+				},
+				Spec: EquinixMetalClusterSpec{DeletionProtection: tt.protected}, //nolint:exhaustivestruct
+			}
+
+			if tt.owner != "" {
+				c.OwnerReferences = []metav1.OwnerReference{{ //nolint:exhaustivestruct
+					APIVersion: clusterv1.GroupVersion.String(),
+					Kind:       "Cluster",
+					Name:       tt.owner,
+				}}
+			}
+
+			err := validator.ValidateDelete(context.Background(), c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateDelete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && !apierrors.IsForbidden(err) {
+				t.Errorf("ValidateDelete() error = %v, want forbidden", err)
+			}
+		})
+	}
+}
+
+func TestValidateExternallyManaged(t *testing.T) {
+	t.Parallel()
+
+	managedBy := map[string]string{clusterv1.ManagedByAnnotation: ""}
+	endpoint := clusterv1.APIEndpoint{Host: "192.0.2.1", Port: 6443}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		spec        EquinixMetalClusterSpec
+		want        []string
+	}{
+		{
+			name: "managed by the controller",
+			spec: EquinixMetalClusterSpec{Project: &ManagedProject{OrganizationID: "org"}}, //nolint:exhaustivestruct
+			want: []string{},
+		},
+		{
+			name:        "valid",
+			annotations: managedBy,
+			spec:        EquinixMetalClusterSpec{ProjectID: "project", ControlPlaneEndpoint: endpoint}, //nolint:exhaustivestruct
+			want:        []string{},
+		},
+		{
+			name:        "missing project and endpoint",
+			annotations: managedBy,
+			spec:        EquinixMetalClusterSpec{}, //nolint:exhaustivestruct
+			want:        []string{"spec.projectID", "spec.controlPlaneEndpoint.host", "spec.controlPlaneEndpoint.port"},
+		},
+		{
+			name:        "managed resources",
+			annotations: managedBy,
+			spec: EquinixMetalClusterSpec{ //nolint:exhaustivestruct
+				ProjectID:            "project",
+				Project:              &ManagedProject{OrganizationID: "org"}, //nolint:exhaustivestruct
+				SSHKeys:              &SSHKeySources{},                       //nolint:exhaustivestruct
+				ControlPlaneEndpoint: endpoint,
+			},
+			want: []string{"spec.project", "spec.sshKeys"},
+		},
+		{
+			name:        "port out of range",
+			annotations: managedBy,
+			spec: EquinixMetalClusterSpec{ //nolint:exhaustivestruct
+				ProjectID:            "project",
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "192.0.2.1", Port: MaxPort + 1},
+			},
+			want: []string{"spec.controlPlaneEndpoint.port"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &EquinixMetalCluster{ //nolint:exhaustivestruct
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}, //nolint:exhaustivestruct
+				Spec:       tt.spec,
+			}
+
+			if got := errorFields(c.validateExternallyManaged()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateExternallyManaged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
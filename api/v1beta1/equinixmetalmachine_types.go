@@ -44,6 +44,8 @@ const (
 	InstanceNotReadyReason = "InstanceNotReady"
 	// InstanceProvisionStartedReason set when the provisioning of an instance started.
 	InstanceProvisionStartedReason = "InstanceProvisionStarted"
+	// InstanceReinstallStartedReason set when the in-place reinstallation of an instance started.
+	InstanceReinstallStartedReason = "InstanceReinstallStarted"
 	// InstanceProvisionFailedReason used for failures during instance provisioning.
 	InstanceProvisionFailedReason = "InstanceProvisionFailed"
 	// WaitingForClusterInfrastructureReason used when machine is waiting for cluster infrastructure to be ready
@@ -51,6 +53,95 @@ const (
 	WaitingForClusterInfrastructureReason = "WaitingForClusterInfrastructure"
 	// WaitingForBootstrapDataReason used when machine is waiting for bootstrap data to be ready before proceeding.
 	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
+	// DeletionProtectedReason used when the deletion of a machine is waiting for the DeletionConfirmedAnnotation
+	// because deletion protection is enabled.
+	DeletionProtectedReason = "DeletionProtected"
+	// DeviceWipeStartedReason set when the disks of the device of a deleted machine are being wiped before
+	// its hardware reservation is retained.
+	DeviceWipeStartedReason = "DeviceWipeStarted"
+)
+
+const (
+	// CustomIPXEOS is the OS used to boot devices from an iPXE script.
+	CustomIPXEOS = "custom_ipxe"
+)
+
+const (
+	// RebootRequestedAnnotation requests a reboot of the device of an EquinixMetalMachine. Its value is the
+	// RFC 3339 time of the request, the device is rebooted once for every new value.
+	RebootRequestedAnnotation = "equinixmetal.infrastructure.cluster.x-k8s.io/reboot-requested-at"
+	// PowerOffRequestedAnnotation requests the device of an EquinixMetalMachine to be powered off. Its value is
+	// the RFC 3339 time of the request, the device is powered off once for every new value.
+	PowerOffRequestedAnnotation = "equinixmetal.infrastructure.cluster.x-k8s.io/power-off-requested-at"
+	// PowerOnRequestedAnnotation requests the device of an EquinixMetalMachine to be powered on. Its value is
+	// the RFC 3339 time of the request, the device is powered on once for every new value.
+	PowerOnRequestedAnnotation = "equinixmetal.infrastructure.cluster.x-k8s.io/power-on-requested-at"
+	// ReinstallRequestedAnnotation requests the device of an EquinixMetalMachine to be reinstalled in place,
+	// keeping its ID and hardware reservation. Its value is the RFC 3339 time of the request, the device is
+	// reinstalled once for every new value. The device is reinstalled with the bootstrap data of the machine,
+	// and the request is rejected as long as it is the bootstrap data the device was last provisioned with.
+	// The hostname of the device is kept, so the reinstalled node registers as the existing Node.
+	ReinstallRequestedAnnotation = "equinixmetal.infrastructure.cluster.x-k8s.io/reinstall-requested-at"
+	// RescueAnnotation puts the device of an EquinixMetalMachine into the Equinix Metal rescue OS while it is
+	// present. The device is rebooted into its installed OS when the annotation is removed.
+	RescueAnnotation = "equinixmetal.infrastructure.cluster.x-k8s.io/rescue"
+	// DeletionConfirmedAnnotation confirms the deletion of an EquinixMetalMachine or EquinixMetalCluster with
+	// deletion protection. On an EquinixMetalCluster, it confirms the deletion of all the machines of the cluster.
+	DeletionConfirmedAnnotation = "equinixmetal.infrastructure.cluster.x-k8s.io/deletion-confirmed"
+)
+
+const (
+	// PowerActionSucceededCondition reports on the last power action requested through annotations.
+	PowerActionSucceededCondition clusterv1.ConditionType = "PowerActionSucceeded"
+
+	// PowerActionFailedReason used when a requested power action couldn't be carried out.
+	PowerActionFailedReason = "PowerActionFailed"
+
+	// RescueModeCondition reports whether the device has been booted into the rescue OS. MachineHealthCheck
+	// remediation of the Machine is suppressed while it is true.
+	RescueModeCondition clusterv1.ConditionType = "RescueMode"
+
+	// RescueModeFailedReason used when the device couldn't be booted into the rescue OS.
+	RescueModeFailedReason = "RescueModeFailed"
+
+	// ReinstallSucceededCondition reports on the last in-place reinstallation requested through annotations.
+	ReinstallSucceededCondition clusterv1.ConditionType = "ReinstallSucceeded"
+
+	// ReinstallFailedReason used when a requested reinstallation couldn't be started.
+	ReinstallFailedReason = "ReinstallFailed"
+	// ReinstallBlockedReason used when a requested reinstallation is rejected because the bootstrap data
+	// has not changed since the device was provisioned, so its join credentials have likely expired.
+	ReinstallBlockedReason = "ReinstallBlocked"
+)
+
+// HardwareReservationDeletePolicy is what happens to the hardware reservation of a device when its
+// machine is deleted.
+// +kubebuilder:validation:Enum=Release;Keep;KeepAndDeprovision
+type HardwareReservationDeletePolicy string
+
+const (
+	// HardwareReservationDeletePolicyRelease returns the hardware reservation to the pool of the project,
+	// where any device can use it.
+	HardwareReservationDeletePolicyRelease = HardwareReservationDeletePolicy("Release")
+	// HardwareReservationDeletePolicyKeep retains the hardware reservation for the machines replacing the
+	// deleted one by tagging it on Equinix Metal.
+	HardwareReservationDeletePolicyKeep = HardwareReservationDeletePolicy("Keep")
+	// HardwareReservationDeletePolicyKeepAndDeprovision wipes the disks of the device by reinstalling it
+	// without preserving any data before retaining the hardware reservation like
+	// HardwareReservationDeletePolicyKeep.
+	HardwareReservationDeletePolicyKeepAndDeprovision = HardwareReservationDeletePolicy("KeepAndDeprovision")
+)
+
+// PowerAction is an action on the power of a device.
+type PowerAction string
+
+const (
+	// PowerActionReboot reboots the device.
+	PowerActionReboot = PowerAction("reboot")
+	// PowerActionOff powers off the device.
+	PowerActionOff = PowerAction("power_off")
+	// PowerActionOn powers on the device.
+	PowerActionOn = PowerAction("power_on")
 )
 
 // EquinixMetalResourceStatus describes the status of a EquinixMetal resource.
@@ -95,9 +186,28 @@ type EquinixMetalMachineSpec struct {
 
 	// IPXEUrl can be used to set the pxe boot url when using custom OSes with this provider.
 	// Note that OS should also be set to "custom_ipxe" if using this value.
+	// The URL is rendered as a Go template, see IPXEScript for the available variables.
 	// +optional
 	IPXEUrl string `json:"ipxeURL,omitempty"`
 
+	// IPXEScript is an inline iPXE script that can be used instead of IPXEUrl to boot custom OSes.
+	// Note that OS should also be set to "custom_ipxe" if using this value, and that the script must
+	// start with "#!ipxe".
+	// Both IPXEUrl and IPXEScript are rendered as Go templates with the following variables:
+	// {{ .MachineName }}, {{ .ClusterName }}, {{ .Namespace }} and {{ .MetadataURL }}, the URL of the
+	// metadata service endpoint serving the metadata of the device as JSON.
+	// IPXEUrl can also use {{ .BootstrapURL }}, the URL of the endpoint serving the raw bootstrap data.
+	// Since Equinix Metal reads inline scripts from the device userdata, the bootstrap data is passed
+	// as the customdata.bootstrapData field of the metadata when IPXEScript is used.
+	// +optional
+	IPXEScript string `json:"ipxeScript,omitempty"`
+
+	// AlwaysPXE makes the device boot from the iPXE script on every boot instead of only on the first one.
+	// Only valid when OS is set to "custom_ipxe". Unlike the rest of the spec, this field can be changed
+	// after the device has been created.
+	// +optional
+	AlwaysPXE bool `json:"alwaysPXE,omitempty"`
+
 	// HardwareReservationID is the unique device hardware reservation ID, a comma separated list of
 	// hardware reservation IDs, or `next-available` to automatically let the EquinixMetal api determine one.
 	// +optional
@@ -110,6 +220,150 @@ type EquinixMetalMachineSpec struct {
 	// Tags is an optional set of tags to add to EquinixMetal resources managed by the EquinixMetal provider.
 	// +optional
 	Tags []string `json:"tags,omitempty"`
+
+	// Storage is the custom disk partitioning, RAID and filesystem layout of the device.
+	// If unset, the default layout of the OS and plan is used.
+	// +optional
+	Storage *StorageLayout `json:"storage,omitempty"`
+
+	// Alternatives are tried in order when the device can't be created because its MachineType is out
+	// of capacity in its location. When the Machine has a FailureDomain, the alternatives can't move
+	// the device to another location, so only the ones in the same location are tried.
+	// +optional
+	Alternatives []Placement `json:"alternatives,omitempty"`
+
+	// ProvisioningTimeout is how long the device can take to become active before the provisioning is
+	// considered failed. Overrides the provisioning timeout of the controller.
+	// +optional
+	ProvisioningTimeout *metav1.Duration `json:"provisioningTimeout,omitempty"`
+
+	// Reinstall configures how the device is reinstalled when requested with the ReinstallRequestedAnnotation.
+	// This field can be changed after the machine has been created.
+	// +optional
+	Reinstall *ReinstallOptions `json:"reinstall,omitempty"`
+
+	// DeletionProtection locks the device until the deletion of the machine is confirmed with the
+	// DeletionConfirmedAnnotation, see docs/deletion-protection.md. This field can be changed after creation.
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+
+	// HardwareReservationDeletePolicy is what happens to the hardware reservation of the device when the
+	// machine is deleted, see docs/hardware-reservations.md. This field can be changed after creation.
+	// +kubebuilder:default=Release
+	// +optional
+	HardwareReservationDeletePolicy HardwareReservationDeletePolicy `json:"hardwareReservationDeletePolicy,omitempty"`
+}
+
+// Placement is the location and type of a device. Unset fields default to the ones of the machine.
+// +kubebuilder:validation:MinProperties=1
+type Placement struct {
+	// Metro is the EquinixMetal metro of the device.
+	// +optional
+	Metro string `json:"metro,omitempty"`
+
+	// Facility is the EquinixMetal facility of the device.
+	// +optional
+	Facility string `json:"facility,omitempty"`
+
+	// MachineType is the EquinixMetal plan of the device.
+	// +optional
+	MachineType string `json:"machineType,omitempty"`
+}
+
+// ReinstallOptions configures the in-place reinstallation of a device.
+type ReinstallOptions struct {
+	// OS is the operating system to reinstall the device with. Defaults to the OS of the machine.
+	// +optional
+	OS string `json:"os,omitempty"`
+
+	// PreserveData keeps the data of the non-OS disks of the device.
+	// +optional
+	PreserveData bool `json:"preserveData,omitempty"`
+
+	// DeprovisionFast skips wiping the disks of the device.
+	// +optional
+	DeprovisionFast bool `json:"deprovisionFast,omitempty"`
+}
+
+// StorageLayout describes the custom storage layout of an EquinixMetal device.
+type StorageLayout struct {
+	// Disks are the disks to partition.
+	// +optional
+	Disks []Disk `json:"disks,omitempty"`
+
+	// RAID are the software RAID arrays to assemble from the disk partitions.
+	// +optional
+	RAID []RAID `json:"raid,omitempty"`
+
+	// Filesystems are the filesystems to create and mount on partitions or RAID arrays.
+	// +optional
+	Filesystems []Filesystem `json:"filesystems,omitempty"`
+}
+
+// Disk describes the partitioning of a disk.
+type Disk struct {
+	// Device is the path of the disk, e.g. /dev/sda.
+	Device string `json:"device"`
+
+	// WipeTable wipes the existing partition table of the disk.
+	// +optional
+	WipeTable bool `json:"wipeTable,omitempty"`
+
+	// Partitions are the partitions to create on the disk.
+	// +optional
+	Partitions []Partition `json:"partitions,omitempty"`
+}
+
+// Partition describes a disk partition.
+type Partition struct {
+	// Label is the label of the partition.
+	Label string `json:"label"`
+
+	// Number is the number of the partition on the disk, starting at 1.
+	// +kubebuilder:validation:Minimum=1
+	Number int `json:"number"`
+
+	// Size is the size of the partition, either a number of sectors or a number followed by
+	// one of the K, M, G or T units. A size of 0 uses the rest of the disk.
+	// +kubebuilder:validation:Pattern=`^[0-9]+[KMGT]?$`
+	Size string `json:"size"`
+}
+
+// RAID describes a software RAID array.
+type RAID struct {
+	// Name is the path of the RAID array device, e.g. /dev/md/ROOT.
+	Name string `json:"name"`
+
+	// Level is the RAID level of the array.
+	// +kubebuilder:validation:Enum="0";"1";"5";"6";"10"
+	Level string `json:"level"`
+
+	// Devices are the partitions that are part of the array.
+	// +kubebuilder:validation:MinItems=2
+	Devices []string `json:"devices"`
+}
+
+// Filesystem describes a filesystem created on a partition or RAID array.
+type Filesystem struct {
+	// Mount describes how the filesystem is created and mounted.
+	Mount FilesystemMount `json:"mount"`
+}
+
+// FilesystemMount describes how a filesystem is created and mounted.
+type FilesystemMount struct {
+	// Device is the partition or RAID array holding the filesystem.
+	Device string `json:"device"`
+
+	// Format is the type of the filesystem.
+	// +kubebuilder:validation:Enum=ext2;ext3;ext4;xfs;vfat;swap
+	Format string `json:"format"`
+
+	// Point is the mount point of the filesystem, or "none" for swap.
+	Point string `json:"point"`
+
+	// Options are additional options passed when creating the filesystem.
+	// +optional
+	Options []string `json:"options,omitempty"`
 }
 
 // EquinixMetalMachineStatus defines the observed state of EquinixMetalMachine.
@@ -125,6 +379,52 @@ type EquinixMetalMachineStatus struct {
 	// +optional
 	InstanceStatus *EquinixMetalResourceStatus `json:"instanceStatus,omitempty"`
 
+	// Placement is the location and type the device was created with, which differs from the spec when
+	// one of the alternatives was used.
+	// +optional
+	Placement *Placement `json:"placement,omitempty"`
+
+	// ProvisioningRetries is the number of devices that were deleted and created again because
+	// they failed to provision or did not become active within the provisioning timeout.
+	// +optional
+	ProvisioningRetries int32 `json:"provisioningRetries,omitempty"`
+
+	// LastPowerAction is the last power action requested through annotations.
+	// +optional
+	LastPowerAction *PowerActionStatus `json:"lastPowerAction,omitempty"`
+
+	// LastReinstall is the last in-place reinstallation requested through annotations.
+	// +optional
+	LastReinstall *ReinstallStatus `json:"lastReinstall,omitempty"`
+
+	// BootstrapDataHash is the SHA-256 hash of the bootstrap data the device was last provisioned with.
+	// +optional
+	BootstrapDataHash string `json:"bootstrapDataHash,omitempty"`
+
+	// Cost is the price of the device of the machine, as listed in the Equinix Metal plan catalog
+	// for its machine type, metro and billing cycle.
+	// +optional
+	Cost *MachineCost `json:"cost,omitempty"`
+
+	// HardwareReservationID is the ID of the hardware reservation the device runs on, if any.
+	// +optional
+	HardwareReservationID string `json:"hardwareReservationID,omitempty"`
+
+	// RescueSkipsRemediation is set when rescue mode added the Cluster API skip remediation annotation to
+	// the Machine, so that it is only removed when leaving rescue mode if it was not set by the user.
+	// +optional
+	RescueSkipsRemediation bool `json:"rescueSkipsRemediation,omitempty"`
+
+	// DeviceWipe tracks the reinstallation wiping the disks of the device of the deleted machine before
+	// its hardware reservation is retained.
+	// +optional
+	DeviceWipe *DeviceWipeStatus `json:"deviceWipe,omitempty"`
+
+	// SOSConsole describes how to connect to the serial over SSH console of the device.
+	// It is only set when the SOSConsole feature gate is enabled.
+	// +optional
+	SOSConsole *SOSConsoleStatus `json:"sosConsole,omitempty"`
+
 	// Any transient errors that occur during the reconciliation of Machines
 	// can be added as events to the Machine object and/or logged in the
 	// controller's output.
@@ -155,6 +455,78 @@ type EquinixMetalMachineStatus struct {
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
+// PowerActionStatus describes a power action requested through annotations.
+type PowerActionStatus struct {
+	// Action is the power action.
+	Action PowerAction `json:"action"`
+
+	// RequestedAt is the time the action was requested at.
+	RequestedAt metav1.Time `json:"requestedAt"`
+
+	// Time is when the action was carried out.
+	Time metav1.Time `json:"time"`
+}
+
+// MachineCost is the price of the device of an EquinixMetalMachine.
+type MachineCost struct {
+	// MachineType is the plan the device was created with.
+	MachineType string `json:"machineType"`
+
+	// Metro is the metro the device runs in.
+	// +optional
+	Metro string `json:"metro,omitempty"`
+
+	// BillingCycle is the billing cycle the device is charged with.
+	// +optional
+	BillingCycle string `json:"billingCycle,omitempty"`
+
+	// HourlyPrice is the price of the device per hour in US dollars, such as "1.5".
+	HourlyPrice string `json:"hourlyPrice"`
+
+	// MonthlyPrice is the price of the device per month in US dollars.
+	MonthlyPrice string `json:"monthlyPrice"`
+}
+
+// DeviceWipeStatus tracks the reinstallation wiping the disks of a device.
+type DeviceWipeStatus struct {
+	// PreviousState is the state of the device when the wipe was started.
+	PreviousState string `json:"previousState"`
+
+	// StartTime is when the wipe was started.
+	StartTime metav1.Time `json:"startTime"`
+
+	// Reinstalling is set once the device has been seen reinstalling. The wipe is done when the
+	// device is active again.
+	// +optional
+	Reinstalling bool `json:"reinstalling,omitempty"`
+}
+
+// SOSConsoleStatus describes how to connect to the serial over SSH console of a device.
+type SOSConsoleStatus struct {
+	// Host is the SOS console host to connect to with SSH.
+	Host string `json:"host"`
+
+	// Username is the SSH username of the SOS console.
+	Username string `json:"username"`
+
+	// RootPasswordSecretRef references the Secret holding the root password of the device in its
+	// password key. It is only set if the password was still available when the device became active.
+	// +optional
+	RootPasswordSecretRef *corev1.LocalObjectReference `json:"rootPasswordSecretRef,omitempty"`
+}
+
+// ReinstallStatus describes an in-place reinstallation requested through annotations.
+type ReinstallStatus struct {
+	// OS is the operating system the device was reinstalled with.
+	OS string `json:"os"`
+
+	// RequestedAt is the time the reinstallation was requested at.
+	RequestedAt metav1.Time `json:"requestedAt"`
+
+	// Time is when the reinstallation was started.
+	Time metav1.Time `json:"time"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:path=equinixmetalmachines,scope=Namespaced,categories=cluster-api
@@ -18,7 +18,12 @@ package v1beta1
 
 import (
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
 
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -44,7 +49,14 @@ func (m *EquinixMetalMachine) ValidateCreate() error {
 	machineLog := logf.Log.WithName("equinixmetalmachine-resource")
 	machineLog.Info("validate create", "name", m.Name)
 
-	return nil
+	allErrs := validateEquinixMetalMachineSpec(m.Spec, field.NewPath("spec"))
+	allErrs = append(allErrs, validateRequestAnnotations(m.Annotations)...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("EquinixMetalMachine").GroupKind(), m.Name, allErrs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
@@ -77,12 +89,35 @@ func (m *EquinixMetalMachine) ValidateUpdate(old runtime.Object) error {
 	delete(oldEquinixMetalMachineSpec, "tags")
 	delete(newEquinixMetalMachineSpec, "tags")
 
+	// allow changes to alwaysPXE
+	delete(oldEquinixMetalMachineSpec, "alwaysPXE")
+	delete(newEquinixMetalMachineSpec, "alwaysPXE")
+
+	// allow changes to reinstall
+	delete(oldEquinixMetalMachineSpec, "reinstall")
+	delete(newEquinixMetalMachineSpec, "reinstall")
+
+	// allow changes to deletionProtection
+	delete(oldEquinixMetalMachineSpec, "deletionProtection")
+	delete(newEquinixMetalMachineSpec, "deletionProtection")
+
+	// allow changes to hardwareReservationDeletePolicy
+	delete(oldEquinixMetalMachineSpec, "hardwareReservationDeletePolicy")
+	delete(newEquinixMetalMachineSpec, "hardwareReservationDeletePolicy")
+
 	if !reflect.DeepEqual(oldEquinixMetalMachineSpec, newEquinixMetalMachineSpec) {
 		return apierrors.NewInvalid(GroupVersion.WithKind("EquinixMetalMachine").GroupKind(), m.Name, field.ErrorList{
 			field.Forbidden(field.NewPath("spec"), "cannot be modified"),
 		})
 	}
 
+	allErrs := validateIPXE(m.Spec, field.NewPath("spec"))
+	allErrs = append(allErrs, validateRequestAnnotations(m.Annotations)...)
+
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("EquinixMetalMachine").GroupKind(), m.Name, allErrs)
+	}
+
 	return nil
 }
 
@@ -99,3 +134,212 @@ func (m *EquinixMetalMachine) Default() {
 	machineLog := logf.Log.WithName("equinixmetalmachine-resource")
 	machineLog.Info("default", "name", m.Name)
 }
+
+// validateEquinixMetalMachineSpec validates an EquinixMetalMachineSpec, it is shared with EquinixMetalMachineTemplate.
+func validateEquinixMetalMachineSpec(spec EquinixMetalMachineSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := validateIPXE(spec, fldPath)
+	allErrs = append(allErrs, validateStorage(spec.Storage, fldPath.Child("storage"))...)
+
+	return allErrs
+}
+
+// validateRequestAnnotations checks that the annotations requesting device actions hold RFC 3339 times.
+func validateRequestAnnotations(annotations map[string]string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for _, name := range []string{
+		RebootRequestedAnnotation,
+		PowerOffRequestedAnnotation,
+		PowerOnRequestedAnnotation,
+		ReinstallRequestedAnnotation,
+	} {
+		value, ok := annotations[name]
+		if !ok {
+			continue
+		}
+
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			allErrs = append(allErrs,
+				field.Invalid(field.NewPath("metadata", "annotations").Key(name), value, "must be an RFC 3339 time"),
+			)
+		}
+	}
+
+	return allErrs
+}
+
+func validateIPXE(spec EquinixMetalMachineSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	// The iPXE settings also apply when the device is reinstalled with the custom iPXE OS.
+	reinstallIPXE := spec.Reinstall != nil && spec.Reinstall.OS == CustomIPXEOS
+
+	if spec.OS != CustomIPXEOS && !reinstallIPXE {
+		if spec.IPXEUrl != "" {
+			allErrs = append(allErrs,
+				field.Forbidden(fldPath.Child("ipxeURL"), fmt.Sprintf("can only be set when os is %q", CustomIPXEOS)),
+			)
+		}
+
+		if spec.IPXEScript != "" {
+			allErrs = append(allErrs,
+				field.Forbidden(fldPath.Child("ipxeScript"), fmt.Sprintf("can only be set when os is %q", CustomIPXEOS)),
+			)
+		}
+
+		if spec.AlwaysPXE {
+			allErrs = append(allErrs,
+				field.Forbidden(fldPath.Child("alwaysPXE"), fmt.Sprintf("can only be set when os is %q", CustomIPXEOS)),
+			)
+		}
+
+		return allErrs
+	}
+
+	switch {
+	case spec.IPXEUrl == "" && spec.IPXEScript == "":
+		allErrs = append(allErrs,
+			field.Required(fldPath.Child("ipxeURL"),
+				fmt.Sprintf("ipxeURL or ipxeScript is required when os is %q", CustomIPXEOS)),
+		)
+	case spec.IPXEUrl != "" && spec.IPXEScript != "":
+		allErrs = append(allErrs,
+			field.Forbidden(fldPath.Child("ipxeScript"), "cannot be set together with ipxeURL"),
+		)
+	}
+
+	if spec.IPXEUrl != "" {
+		if err := validateIPXETemplate(spec.IPXEUrl, false); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("ipxeURL"), spec.IPXEUrl, err.Error()))
+		}
+	}
+
+	if spec.IPXEScript != "" {
+		if !strings.HasPrefix(spec.IPXEScript, "#!ipxe") {
+			allErrs = append(allErrs,
+				field.Invalid(fldPath.Child("ipxeScript"), spec.IPXEScript, "must start with #!ipxe"),
+			)
+		}
+
+		if err := validateIPXETemplate(spec.IPXEScript, true); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("ipxeScript"), spec.IPXEScript, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// validateIPXETemplate checks that an iPXE template parses and only uses the variables available to it,
+// by rendering it with placeholder values. BootstrapURL is not available to inline scripts.
+func validateIPXETemplate(text string, inlineScript bool) error {
+	tmpl, err := template.New("ipxe").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	data := map[string]string{"MachineName": "", "ClusterName": "", "Namespace": "", "MetadataURL": ""}
+	if !inlineScript {
+		data["BootstrapURL"] = ""
+	}
+
+	if err := tmpl.Execute(io.Discard, data); err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return nil
+}
+
+// validateStorage checks that the RAID arrays and filesystems of a storage layout only
+// reference partitions and arrays defined in the layout.
+func validateStorage(storage *StorageLayout, fldPath *field.Path) field.ErrorList { //nolint:cyclop
+	var allErrs field.ErrorList
+
+	if storage == nil {
+		return allErrs
+	}
+
+	devices := map[string]bool{}
+
+	for i, disk := range storage.Disks {
+		diskPath := fldPath.Child("disks").Index(i)
+
+		if !strings.HasPrefix(disk.Device, "/dev/") {
+			allErrs = append(allErrs, field.Invalid(diskPath.Child("device"), disk.Device, "must be a path under /dev/"))
+		}
+
+		if devices[disk.Device] {
+			allErrs = append(allErrs, field.Duplicate(diskPath.Child("device"), disk.Device))
+		}
+
+		devices[disk.Device] = true
+
+		for j, partition := range disk.Partitions {
+			partitionDevice := partitionDevicePath(disk.Device, partition.Number)
+			if devices[partitionDevice] {
+				allErrs = append(allErrs,
+					field.Duplicate(diskPath.Child("partitions").Index(j).Child("number"), partition.Number),
+				)
+			}
+
+			devices[partitionDevice] = true
+		}
+	}
+
+	raidMembers := map[string]bool{}
+
+	for i, raid := range storage.RAID {
+		raidPath := fldPath.Child("raid").Index(i)
+
+		if !strings.HasPrefix(raid.Name, "/dev/") {
+			allErrs = append(allErrs, field.Invalid(raidPath.Child("name"), raid.Name, "must be a path under /dev/"))
+		}
+
+		for j, member := range raid.Devices {
+			memberPath := raidPath.Child("devices").Index(j)
+
+			switch {
+			case !devices[member]:
+				allErrs = append(allErrs, field.NotFound(memberPath, member))
+			case raidMembers[member]:
+				allErrs = append(allErrs, field.Duplicate(memberPath, member))
+			}
+
+			raidMembers[member] = true
+		}
+
+		devices[raid.Name] = true
+	}
+
+	mountPoints := map[string]bool{}
+
+	for i, filesystem := range storage.Filesystems {
+		mountPath := fldPath.Child("filesystems").Index(i).Child("mount")
+		mount := filesystem.Mount
+
+		if !devices[mount.Device] {
+			allErrs = append(allErrs, field.NotFound(mountPath.Child("device"), mount.Device))
+		}
+
+		switch {
+		case mount.Format == "swap" && mount.Point != "none":
+			allErrs = append(allErrs, field.Invalid(mountPath.Child("point"), mount.Point, "must be none for swap"))
+		case mount.Format != "swap" && !strings.HasPrefix(mount.Point, "/"):
+			allErrs = append(allErrs, field.Invalid(mountPath.Child("point"), mount.Point, "must be an absolute path"))
+		case mount.Format != "swap" && mountPoints[mount.Point]:
+			allErrs = append(allErrs, field.Duplicate(mountPath.Child("point"), mount.Point))
+		}
+
+		mountPoints[mount.Point] = true
+	}
+
+	return allErrs
+}
+
+// partitionDevicePath returns the path of a partition, e.g. /dev/sda1 or /dev/nvme0n1p1.
+func partitionDevicePath(disk string, number int) string {
+	if disk != "" && unicode.IsDigit(rune(disk[len(disk)-1])) {
+		return fmt.Sprintf("%sp%d", disk, number)
+	}
+
+	return fmt.Sprintf("%s%d", disk, number)
+}
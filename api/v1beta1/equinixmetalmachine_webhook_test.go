@@ -0,0 +1,317 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// errorFields returns the fields of the errors of a field.ErrorList.
+func errorFields(errs field.ErrorList) []string {
+	fields := make([]string, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+
+	return fields
+}
+
+func TestValidateIPXE(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		spec EquinixMetalMachineSpec
+		want []string
+	}{
+		{
+			name: "other OS",
+			spec: EquinixMetalMachineSpec{OS: "ubuntu_20_04"}, //nolint:exhaustivestruct
+			want: []string{},
+		},
+		{
+			name: "iPXE settings on other OS",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:         "ubuntu_20_04",
+				IPXEUrl:    "https://boot.example.com",
+				IPXEScript: "#!ipxe",
+				AlwaysPXE:  true,
+			},
+			want: []string{"spec.ipxeURL", "spec.ipxeScript", "spec.alwaysPXE"},
+		},
+		{
+			name: "reinstalled with custom iPXE OS",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:        "ubuntu_20_04",
+				IPXEUrl:   "https://boot.example.com",
+				Reinstall: &ReinstallOptions{OS: CustomIPXEOS}, //nolint:exhaustivestruct
+			},
+			want: []string{},
+		},
+		{
+			name: "URL",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:        CustomIPXEOS,
+				IPXEUrl:   "https://boot.example.com/{{ .MachineName }}?data={{ .BootstrapURL }}",
+				AlwaysPXE: true,
+			},
+			want: []string{},
+		},
+		{
+			name: "script",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:         CustomIPXEOS,
+				IPXEScript: "#!ipxe\nchain https://boot.example.com/{{ .ClusterName }}?metadata={{ .MetadataURL }}",
+			},
+			want: []string{},
+		},
+		{
+			name: "no URL or script",
+			spec: EquinixMetalMachineSpec{OS: CustomIPXEOS}, //nolint:exhaustivestruct
+			want: []string{"spec.ipxeURL"},
+		},
+		{
+			name: "URL and script",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:         CustomIPXEOS,
+				IPXEUrl:    "https://boot.example.com",
+				IPXEScript: "#!ipxe",
+			},
+			want: []string{"spec.ipxeScript"},
+		},
+		{
+			name: "script without shebang",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:         CustomIPXEOS,
+				IPXEScript: "chain https://boot.example.com",
+			},
+			want: []string{"spec.ipxeScript"},
+		},
+		{
+			name: "bad URL template",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:      CustomIPXEOS,
+				IPXEUrl: "https://boot.example.com/{{ .MachineName",
+			},
+			want: []string{"spec.ipxeURL"},
+		},
+		{
+			name: "unknown variable",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:      CustomIPXEOS,
+				IPXEUrl: "https://boot.example.com/{{ .Hostname }}",
+			},
+			want: []string{"spec.ipxeURL"},
+		},
+		{
+			name: "bootstrap URL in script",
+			spec: EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				OS:         CustomIPXEOS,
+				IPXEScript: "#!ipxe\nchain {{ .BootstrapURL }}",
+			},
+			want: []string{"spec.ipxeScript"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := errorFields(validateIPXE(tt.spec, field.NewPath("spec")))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateIPXE() errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStorage(t *testing.T) {
+	t.Parallel()
+
+	disk := func(device string, numbers ...int) Disk {
+		partitions := make([]Partition, 0, len(numbers))
+		for _, n := range numbers {
+			partitions = append(partitions, Partition{Label: "part", Number: n, Size: "1GB"})
+		}
+
+		return Disk{Device: device, Partitions: partitions} //nolint:exhaustivestruct
+	}
+
+	filesystem := func(device, format, point string) Filesystem {
+		return Filesystem{Mount: FilesystemMount{Device: device, Format: format, Point: point}} //nolint:exhaustivestruct
+	}
+
+	tests := []struct {
+		name    string
+		storage *StorageLayout
+		want    []string
+	}{
+		{name: "default layout", storage: nil, want: []string{}},
+		{
+			name: "RAID",
+			storage: &StorageLayout{
+				Disks: []Disk{disk("/dev/sda", 1, 2), disk("/dev/sdb", 1, 2)},
+				RAID: []RAID{
+					{Name: "/dev/md0", Level: "1", Devices: []string{"/dev/sda2", "/dev/sdb2"}},
+				},
+				Filesystems: []Filesystem{
+					filesystem("/dev/sda1", "swap", "none"),
+					filesystem("/dev/sdb1", "swap", "none"),
+					filesystem("/dev/md0", "ext4", "/"),
+				},
+			},
+			want: []string{},
+		},
+		{
+			name: "NVMe partitions",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks:       []Disk{disk("/dev/nvme0n1", 1)},
+				Filesystems: []Filesystem{filesystem("/dev/nvme0n1p1", "ext4", "/")},
+			},
+			want: []string{},
+		},
+		{
+			name: "disk outside of /dev",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks: []Disk{disk("sda")},
+			},
+			want: []string{"spec.storage.disks[0].device"},
+		},
+		{
+			name: "duplicate disk",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks: []Disk{disk("/dev/sda"), disk("/dev/sda")},
+			},
+			want: []string{"spec.storage.disks[1].device"},
+		},
+		{
+			name: "duplicate partition",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks: []Disk{disk("/dev/sda", 1, 1)},
+			},
+			want: []string{"spec.storage.disks[0].partitions[1].number"},
+		},
+		{
+			name: "RAID outside of /dev",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks: []Disk{disk("/dev/sda", 1)},
+				RAID:  []RAID{{Name: "md0", Level: "1", Devices: []string{"/dev/sda1"}}},
+			},
+			want: []string{"spec.storage.raid[0].name"},
+		},
+		{
+			name: "unknown RAID member",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks: []Disk{disk("/dev/sda", 1)},
+				RAID:  []RAID{{Name: "/dev/md0", Level: "1", Devices: []string{"/dev/sda1", "/dev/sdb1"}}},
+			},
+			want: []string{"spec.storage.raid[0].devices[1]"},
+		},
+		{
+			name: "RAID member in two arrays",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks: []Disk{disk("/dev/sda", 1, 2)},
+				RAID: []RAID{
+					{Name: "/dev/md0", Level: "1", Devices: []string{"/dev/sda1", "/dev/sda2"}},
+					{Name: "/dev/md1", Level: "1", Devices: []string{"/dev/sda2"}},
+				},
+			},
+			want: []string{"spec.storage.raid[1].devices[0]"},
+		},
+		{
+			name: "unknown filesystem device",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks:       []Disk{disk("/dev/sda", 1)},
+				Filesystems: []Filesystem{filesystem("/dev/sda2", "ext4", "/")},
+			},
+			want: []string{"spec.storage.filesystems[0].mount.device"},
+		},
+		{
+			name: "mounted swap",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks:       []Disk{disk("/dev/sda", 1)},
+				Filesystems: []Filesystem{filesystem("/dev/sda1", "swap", "/swap")},
+			},
+			want: []string{"spec.storage.filesystems[0].mount.point"},
+		},
+		{
+			name: "relative mount point",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks:       []Disk{disk("/dev/sda", 1)},
+				Filesystems: []Filesystem{filesystem("/dev/sda1", "ext4", "data")},
+			},
+			want: []string{"spec.storage.filesystems[0].mount.point"},
+		},
+		{
+			name: "duplicate mount point",
+			storage: &StorageLayout{ //nolint:exhaustivestruct
+				Disks: []Disk{disk("/dev/sda", 1, 2)},
+				Filesystems: []Filesystem{
+					filesystem("/dev/sda1", "ext4", "/"),
+					filesystem("/dev/sda2", "ext4", "/"),
+				},
+			},
+			want: []string{"spec.storage.filesystems[1].mount.point"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := errorFields(validateStorage(tt.storage, field.NewPath("spec", "storage")))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateStorage() errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionDevicePath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		disk   string
+		number int
+		want   string
+	}{
+		{disk: "/dev/sda", number: 1, want: "/dev/sda1"},
+		{disk: "/dev/vdb", number: 12, want: "/dev/vdb12"},
+		{disk: "/dev/nvme0n1", number: 2, want: "/dev/nvme0n1p2"},
+		{disk: "/dev/md0", number: 1, want: "/dev/md0p1"},
+		{disk: "", number: 1, want: "1"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.want, func(t *testing.T) {
+			t.Parallel()
+
+			if got := partitionDevicePath(tt.disk, tt.number); got != tt.want {
+				t.Errorf("partitionDevicePath(%q, %d) = %q, want %q", tt.disk, tt.number, got, tt.want)
+			}
+		})
+	}
+}
@@ -17,7 +17,26 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// CapacityCheckQuantityAnnotation is the number of servers whose availability is checked for an
+	// EquinixMetalMachineTemplate. Defaults to 1.
+	CapacityCheckQuantityAnnotation = "equinixmetal.infrastructure.cluster.x-k8s.io/capacity-check-quantity"
+)
+
+const (
+	// CapacityAvailableCondition reports whether Equinix Metal has capacity for machines created from
+	// an EquinixMetalMachineTemplate in its location.
+	CapacityAvailableCondition clusterv1.ConditionType = "CapacityAvailable"
+
+	// InsufficientCapacityReason used when the machine type of the template is out of capacity in its location.
+	InsufficientCapacityReason = "InsufficientCapacity"
+	// CapacityCheckFailedReason used when the capacity couldn't be checked.
+	CapacityCheckFailedReason = "CapacityCheckFailed"
 )
 
 // EquinixMetalMachineTemplateResource describes the data needed to create am EquinixMetalMachine from a template.
@@ -31,7 +50,46 @@ type EquinixMetalMachineTemplateSpec struct {
 	Template EquinixMetalMachineTemplateResource `json:"template"`
 }
 
+// Architecture is the CPU architecture of a node.
+// +kubebuilder:validation:Enum=amd64;arm64
+type Architecture string
+
+// Supported architectures of the machines.
+const (
+	ArchitectureAMD64 Architecture = "amd64"
+	ArchitectureARM64 Architecture = "arm64"
+)
+
+// NodeInfo describes the nodes created from an EquinixMetalMachineTemplate.
+type NodeInfo struct {
+	// Architecture is the CPU architecture of the node, like amd64 or arm64.
+	// +optional
+	Architecture Architecture `json:"architecture,omitempty"`
+
+	// OperatingSystem is the operating system of the node, like linux.
+	// +optional
+	OperatingSystem string `json:"operatingSystem,omitempty"`
+}
+
+// EquinixMetalMachineTemplateStatus defines the observed state of EquinixMetalMachineTemplate.
+type EquinixMetalMachineTemplateStatus struct {
+	// Capacity is the resources of the machines created from the template, such as CPU, memory and GPUs,
+	// as described by the Equinix Metal plan catalog for the machine type. It lets the cluster-autoscaler
+	// scale MachineDeployments from zero.
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+
+	// NodeInfo describes the nodes created from the template.
+	// +optional
+	NodeInfo *NodeInfo `json:"nodeInfo,omitempty"`
+
+	// Conditions defines current service state of the EquinixMetalMachineTemplate.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
 //+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
 //+kubebuilder:resource:path=equinixmetalmachinetemplates,scope=Namespaced,categories=cluster-api
 //+kubebuilder:storageversion
 
@@ -40,7 +98,8 @@ type EquinixMetalMachineTemplate struct {
 	metav1.TypeMeta   `json:",inline"` //nolint:tagliatelle
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec EquinixMetalMachineTemplateSpec `json:"spec,omitempty"`
+	Spec   EquinixMetalMachineTemplateSpec   `json:"spec,omitempty"`
+	Status EquinixMetalMachineTemplateStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -52,6 +111,16 @@ type EquinixMetalMachineTemplateList struct {
 	Items           []EquinixMetalMachineTemplate `json:"items"`
 }
 
+// GetConditions returns the list of conditions for an EquinixMetalMachineTemplate API object.
+func (m *EquinixMetalMachineTemplate) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions will set the given conditions on an EquinixMetalMachineTemplate object.
+func (m *EquinixMetalMachineTemplate) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
 func init() { //nolint:gochecknoinits
 	SchemeBuilder.Register(new(EquinixMetalMachineTemplate), new(EquinixMetalMachineTemplateList))
 }
@@ -22,6 +22,7 @@ import (
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -42,7 +43,13 @@ func (m *EquinixMetalMachineTemplate) ValidateCreate() error {
 	machineTemplateLog := logf.Log.WithName("equinixmetalmachinetemplate-resource")
 	machineTemplateLog.Info("validate create", "name", m.Name)
 
-	return nil
+	allErrs := validateEquinixMetalMachineSpec(m.Spec.Template.Spec, field.NewPath("spec", "template", "spec"))
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("EquinixMetalMachineTemplate").GroupKind(), m.Name, allErrs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
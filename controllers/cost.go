@@ -0,0 +1,183 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// reconcileCost records the price of the device of the machine on its status. The price is only looked
+// up again, in the plan cache, when the device was created with another plan or in another metro.
+// Failing to look the price up does not block the reconciliation of the machine.
+func (r *EquinixMetalMachineReconciler) reconcileCost(ctx context.Context, scope *machineScope, device *metal.Device) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	machineType := equinixMetalMachine.Spec.MachineType
+	if device.Plan != nil && device.Plan.Slug != "" {
+		machineType = device.Plan.Slug
+	}
+
+	var metro, facility string
+	if device.Metro != nil {
+		metro = device.Metro.Code
+	}
+
+	if device.Facility != nil {
+		facility = device.Facility.Code
+	}
+
+	billingCycle := equinixMetalMachine.Spec.BillingCycle
+
+	if cost := equinixMetalMachine.Status.Cost; cost != nil &&
+		cost.MachineType == machineType && cost.Metro == metro && cost.BillingCycle == billingCycle {
+		return
+	}
+
+	plan, err := r.PlanCache.GetPlan(ctx, machineType)
+	if err != nil {
+		log.Error(err, "Failed to look up the price of the device", "machineType", machineType)
+
+		return
+	}
+
+	if plan == nil {
+		log.Info("Machine type not found in the Equinix Metal plan catalog", "machineType", machineType)
+
+		return
+	}
+
+	price := plan.Price(metro, facility)
+	if price == nil {
+		log.Info("Machine type has no price in the Equinix Metal plan catalog", "machineType", machineType)
+
+		return
+	}
+
+	hourly, monthly := price.ForBillingCycle(billingCycle)
+
+	equinixMetalMachine.Status.Cost = &infrav1.MachineCost{
+		MachineType:  machineType,
+		Metro:        metro,
+		BillingCycle: billingCycle,
+		HourlyPrice:  metal.FormatPrice(hourly),
+		MonthlyPrice: metal.FormatPrice(monthly),
+	}
+}
+
+// reconcileCost records the aggregated price of the devices of the machines of the cluster.
+func (r *EquinixMetalClusterReconciler) reconcileCost(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) error {
+	machines := new(infrav1.EquinixMetalMachineList)
+	if err := r.Client.List(ctx, machines, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name}); err != nil {
+		return fmt.Errorf("failed to list EquinixMetalMachines: %w", err)
+	}
+
+	var (
+		count           int32
+		hourly, monthly float64
+	)
+
+	for i := range machines.Items {
+		cost := machines.Items[i].Status.Cost
+		if cost == nil || machines.Items[i].Spec.ProviderID == nil {
+			continue
+		}
+
+		machineHourly, err := strconv.ParseFloat(cost.HourlyPrice, 64)
+		if err != nil {
+			continue
+		}
+
+		machineMonthly, err := strconv.ParseFloat(cost.MonthlyPrice, 64)
+		if err != nil {
+			continue
+		}
+
+		count++
+		hourly += machineHourly
+		monthly += machineMonthly
+	}
+
+	equinixMetalCluster.Status.Cost = &infrav1.ClusterCost{
+		Machines:     count,
+		HourlyPrice:  metal.FormatPrice(hourly),
+		MonthlyPrice: metal.FormatPrice(monthly),
+	}
+
+	return nil
+}
+
+// equinixMetalMachineToEquinixMetalCluster maps an EquinixMetalMachine to the EquinixMetalCluster of its
+// cluster, so that the cost of the cluster follows the costs of its machines.
+func (r *EquinixMetalClusterReconciler) equinixMetalMachineToEquinixMetalCluster(o client.Object) []reconcile.Request {
+	clusterName, ok := o.GetLabels()[clusterv1.ClusterLabelName]
+	if !ok {
+		return nil
+	}
+
+	cluster := new(clusterv1.Cluster)
+	key := client.ObjectKey{Namespace: o.GetNamespace(), Name: clusterName}
+
+	if err := r.Client.Get(context.Background(), key, cluster); err != nil {
+		return nil
+	}
+
+	ref := cluster.Spec.InfrastructureRef
+	if ref == nil || ref.GroupVersionKind() != infrav1.GroupVersion.WithKind("EquinixMetalCluster") {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: cluster.Namespace, Name: ref.Name}}}
+}
+
+// machineCostChanged filters EquinixMetalMachine updates that do not change the cost of the machine.
+func machineCostChanged() predicate.Funcs {
+	return predicate.Funcs{ //nolint:exhaustivestruct
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldMachine, ok := e.ObjectOld.(*infrav1.EquinixMetalMachine)
+			if !ok {
+				return false
+			}
+
+			newMachine, ok := e.ObjectNew.(*infrav1.EquinixMetalMachine)
+			if !ok {
+				return false
+			}
+
+			return !apiequality.Semantic.DeepEqual(oldMachine.Status.Cost, newMachine.Status.Cost) ||
+				(oldMachine.Spec.ProviderID == nil) != (newMachine.Spec.ProviderID == nil)
+		},
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// deletionConfirmationRequeueInterval is how often the deletion of a protected machine checks for confirmation.
+	deletionConfirmationRequeueInterval = time.Minute
+
+	// deletionProtectionHook is the pre-drain delete hook holding the deletion of the Machines with deletion
+	// protection until it is confirmed, so that their Node is not drained before the device is unlocked.
+	deletionProtectionHook = clusterv1.PreDrainDeleteHookAnnotationPrefix + "/equinixmetal-deletion-protection"
+	// deletionProtectionHookOwner is the owner of the deletionProtectionHook.
+	deletionProtectionHookOwner = "cluster-api-provider-equinixmetal"
+)
+
+// deletionProtected returns true if deletion protection is enabled on the machine or its cluster.
+func (s *machineScope) deletionProtected() bool {
+	return s.equinixMetalMachine.Spec.DeletionProtection || s.equinixMetalCluster.Spec.DeletionProtection
+}
+
+// deletionConfirmed returns true if the deletion of the machine, or of all the machines of its
+// cluster, has been confirmed.
+func (s *machineScope) deletionConfirmed() bool {
+	if _, ok := s.equinixMetalMachine.Annotations[infrav1.DeletionConfirmedAnnotation]; ok {
+		return true
+	}
+
+	_, ok := s.equinixMetalCluster.Annotations[infrav1.DeletionConfirmedAnnotation]
+
+	return ok
+}
+
+// reconcileDeviceLock locks the device while deletion protection is enabled, and unlocks it once disabled.
+func (r *EquinixMetalMachineReconciler) reconcileDeviceLock(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) error {
+	if device.Locked == scope.deletionProtected() {
+		return nil
+	}
+
+	return r.setDeviceLock(ctx, scope, device, scope.deletionProtected())
+}
+
+// reconcileDeletionHook adds a pre-drain delete hook to the Machine while deletion protection is enabled
+// and the deletion has not been confirmed, so that deleting the Machine waits for the confirmation before
+// draining its Node and deleting the EquinixMetalMachine. The hook is removed once the deletion is confirmed
+// or deletion protection is disabled. Control plane providers may still remove the etcd member of a
+// control plane machine before deleting its Machine.
+func (r *EquinixMetalMachineReconciler) reconcileDeletionHook(ctx context.Context, scope *machineScope) error {
+	machine := scope.machine
+	hold := scope.deletionProtected() && !scope.deletionConfirmed()
+
+	if _, ok := machine.Annotations[deletionProtectionHook]; ok == hold {
+		if hold && !machine.DeletionTimestamp.IsZero() {
+			ctrl.LoggerFrom(ctx).Info("Waiting for the deletion of the protected Machine to be confirmed",
+				"annotation", infrav1.DeletionConfirmedAnnotation)
+		}
+
+		return nil
+	}
+
+	patch := client.MergeFrom(machine.DeepCopy())
+
+	if hold {
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+
+		machine.Annotations[deletionProtectionHook] = deletionProtectionHookOwner
+	} else {
+		delete(machine.Annotations, deletionProtectionHook)
+	}
+
+	if err := r.Client.Patch(ctx, machine, patch); err != nil {
+		return fmt.Errorf("failed to patch Machine %s: %w", machine.Name, err)
+	}
+
+	return nil
+}
+
+// unlockForDeletion unlocks the device of a machine being deleted. It returns false while deletion
+// protection is enabled and the deletion has not been confirmed.
+func (r *EquinixMetalMachineReconciler) unlockForDeletion(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) (bool, error) {
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	if scope.deletionProtected() && !scope.deletionConfirmed() {
+		ctrl.LoggerFrom(ctx).Info("Waiting for the deletion of the protected device to be confirmed", "device", device.ID)
+		conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+			infrav1.DeletionProtectedReason, clusterv1.ConditionSeverityWarning,
+			"deletion protection is enabled, set the %s annotation to confirm the deletion",
+			infrav1.DeletionConfirmedAnnotation)
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, infrav1.DeletionProtectedReason,
+			"Device %s is protected from deletion, set the %s annotation to confirm the deletion",
+			device.ID, infrav1.DeletionConfirmedAnnotation)
+
+		return false, nil
+	}
+
+	if !device.Locked {
+		return true, nil
+	}
+
+	if err := r.setDeviceLock(ctx, scope, device, false); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *EquinixMetalMachineReconciler) setDeviceLock(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+	locked bool,
+) error {
+	if _, err := r.MetalClient.UpdateDevice(ctx, device.ID, &metal.DeviceUpdateRequest{ //nolint:exhaustivestruct
+		Locked: &locked,
+	}); err != nil {
+		return fmt.Errorf("failed to update device lock: %w", err)
+	}
+
+	r.invalidateDevice(scope, device)
+
+	device.Locked = locked
+
+	action := "Unlocked"
+	if locked {
+		action = "Locked"
+	}
+
+	ctrl.LoggerFrom(ctx).Info(action+" device", "device", device.ID)
+	r.Recorder.Eventf(scope.equinixMetalMachine, corev1.EventTypeNormal, action+"Device",
+		"%s device %s", action, device.ID)
+
+	return nil
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+)
+
+var deletionConfirmed = map[string]string{infrav1.DeletionConfirmedAnnotation: ""} //nolint:gochecknoglobals
+
+func newDeletionProtectionTestScope(
+	machineProtected, clusterProtected bool,
+	machineAnnotations, clusterAnnotations map[string]string,
+) *machineScope {
+	return &machineScope{
+		cluster: &clusterv1.Cluster{}, //nolint:exhaustivestruct
+		machine: &clusterv1.Machine{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "machine"}, //nolint:exhaustivestruct
+		},
+		equinixMetalCluster: &infrav1.EquinixMetalCluster{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Annotations: clusterAnnotations},                    //nolint:exhaustivestruct
+			Spec:       infrav1.EquinixMetalClusterSpec{DeletionProtection: clusterProtected}, //nolint:exhaustivestruct
+		},
+		equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Annotations: machineAnnotations},                    //nolint:exhaustivestruct
+			Spec:       infrav1.EquinixMetalMachineSpec{DeletionProtection: machineProtected}, //nolint:exhaustivestruct
+		},
+	}
+}
+
+func TestDeletionProtection(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name               string
+		machineProtected   bool
+		clusterProtected   bool
+		machineAnnotations map[string]string
+		clusterAnnotations map[string]string
+		wantProtected      bool
+		wantConfirmed      bool
+	}{
+		{name: "unprotected"},
+		{name: "machine protected", machineProtected: true, wantProtected: true},
+		{name: "cluster protected", clusterProtected: true, wantProtected: true},
+		{
+			name:               "machine deletion confirmed",
+			machineProtected:   true,
+			machineAnnotations: deletionConfirmed,
+			wantProtected:      true,
+			wantConfirmed:      true,
+		},
+		{
+			name:               "cluster deletion confirmed",
+			machineProtected:   true,
+			clusterAnnotations: deletionConfirmed,
+			wantProtected:      true,
+			wantConfirmed:      true,
+		},
+		{
+			name:               "other annotations",
+			clusterProtected:   true,
+			machineAnnotations: map[string]string{"other": ""},
+			clusterAnnotations: map[string]string{"other": ""},
+			wantProtected:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scope := newDeletionProtectionTestScope(tt.machineProtected, tt.clusterProtected,
+				tt.machineAnnotations, tt.clusterAnnotations)
+
+			if got := scope.deletionProtected(); got != tt.wantProtected {
+				t.Errorf("deletionProtected() = %v, want %v", got, tt.wantProtected)
+			}
+
+			if got := scope.deletionConfirmed(); got != tt.wantConfirmed {
+				t.Errorf("deletionConfirmed() = %v, want %v", got, tt.wantConfirmed)
+			}
+		})
+	}
+}
+
+func TestReconcileDeletionHook(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name               string
+		protected          bool
+		machineAnnotations map[string]string
+		hooked             bool
+		deleting           bool
+		wantHook           bool
+	}{
+		{name: "unprotected", wantHook: false},
+		{name: "protected", protected: true, wantHook: true},
+		{name: "protected and hooked", protected: true, hooked: true, wantHook: true},
+		{name: "protection disabled", hooked: true, wantHook: false},
+		{
+			name:               "deletion confirmed",
+			protected:          true,
+			machineAnnotations: deletionConfirmed,
+			hooked:             true,
+			deleting:           true,
+			wantHook:           false,
+		},
+		{name: "deleting", protected: true, hooked: true, deleting: true, wantHook: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scope := newDeletionProtectionTestScope(tt.protected, false, tt.machineAnnotations, nil)
+			machine := scope.machine
+			machine.Annotations = map[string]string{"other": "value"}
+
+			if tt.hooked {
+				machine.Annotations[deletionProtectionHook] = deletionProtectionHookOwner
+			}
+
+			if tt.deleting {
+				machine.Finalizers = []string{clusterv1.MachineFinalizer}
+				machine.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			}
+
+			r := &EquinixMetalMachineReconciler{Client: newTestClient(t, machine.DeepCopy())} //nolint:exhaustivestruct
+
+			if err := r.reconcileDeletionHook(context.Background(), scope); err != nil {
+				t.Fatalf("reconcileDeletionHook() error = %v", err)
+			}
+
+			got := new(clusterv1.Machine)
+			if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(machine), got); err != nil {
+				t.Fatalf("failed to get Machine: %v", err)
+			}
+
+			if _, ok := got.Annotations[deletionProtectionHook]; ok != tt.wantHook {
+				t.Errorf("reconcileDeletionHook() hook = %v, want %v", ok, tt.wantHook)
+			}
+
+			if got.Annotations["other"] != "value" {
+				t.Errorf("reconcileDeletionHook() annotations = %v, want the other annotations kept", got.Annotations)
+			}
+		})
+	}
+}
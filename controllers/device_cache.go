@@ -0,0 +1,295 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// cachedProjectExpiry is how long a project is kept in the DeviceCache after its last lookup.
+	cachedProjectExpiry = 30 * time.Minute
+	// deviceEventsBufferSize is the number of pending reconcile triggers for EquinixMetalMachines.
+	deviceEventsBufferSize = 1024
+)
+
+// DeviceLister gets and lists Equinix Metal devices, as metal.Client does.
+type DeviceLister interface {
+	GetDevice(ctx context.Context, deviceID string) (*metal.Device, error)
+	ListDevices(ctx context.Context, projectID string) ([]metal.Device, error)
+}
+
+// DeviceCache is a read-through cache of the Equinix Metal devices, refreshed with one bulk list
+// per project at every Interval, so that reconciling EquinixMetalMachines costs API calls per
+// project rather than per machine. The EquinixMetalMachines are requeued when the state of their
+// device changes.
+type DeviceCache struct {
+	client.Client
+	MetalClient DeviceLister
+
+	// Interval is how often the devices of the cached projects are listed.
+	Interval time.Duration
+
+	mu       sync.Mutex
+	projects map[string]*cachedProject
+	events   chan event.GenericEvent
+}
+
+// cachedProject holds the devices of an Equinix Metal project.
+type cachedProject struct {
+	devices  map[string]*metal.Device
+	listed   bool
+	lastUsed time.Time
+}
+
+// NewDeviceCache returns a new DeviceCache. It must be added to a Manager with SetupWithManager.
+func NewDeviceCache(metalClient DeviceLister, interval time.Duration) *DeviceCache {
+	return &DeviceCache{ //nolint:exhaustivestruct
+		MetalClient: metalClient,
+		Interval:    interval,
+		projects:    map[string]*cachedProject{},
+		events:      make(chan event.GenericEvent, deviceEventsBufferSize),
+	}
+}
+
+// SetupWithManager adds the DeviceCache to the Manager.
+func (c *DeviceCache) SetupWithManager(mgr ctrl.Manager) error {
+	if c.Client == nil {
+		c.Client = mgr.GetClient()
+	}
+
+	if err := mgr.Add(c); err != nil {
+		return fmt.Errorf("failed to add device cache to manager: %w", err)
+	}
+
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the cache is only used by the reconcilers.
+func (c *DeviceCache) NeedLeaderElection() bool {
+	return true
+}
+
+// Events returns the channel on which the EquinixMetalMachines whose device changed are sent.
+func (c *DeviceCache) Events() <-chan event.GenericEvent {
+	return c.events
+}
+
+// Start implements manager.Runnable and blocks until the context is cancelled.
+func (c *DeviceCache) Start(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx).WithName("device-cache")
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	log.Info("Starting device cache", "interval", c.Interval)
+
+	wait.UntilWithContext(ctx, c.refresh, c.Interval)
+
+	return nil
+}
+
+// Device returns the device with the given ID from the cache, getting it from the Equinix Metal
+// API when its project has not been listed yet or when it was created since the last listing.
+// The returned device is a copy that can be modified by the caller.
+func (c *DeviceCache) Device(ctx context.Context, projectID, deviceID string) (*metal.Device, error) {
+	c.mu.Lock()
+
+	project, ok := c.projects[projectID]
+	if !ok {
+		project = &cachedProject{devices: map[string]*metal.Device{}} //nolint:exhaustivestruct
+		c.projects[projectID] = project
+	}
+
+	project.lastUsed = time.Now()
+	cached, ok := project.devices[deviceID]
+
+	c.mu.Unlock()
+
+	if ok {
+		device := *cached
+
+		return &device, nil
+	}
+
+	device, err := c.MetalClient.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.mu.Lock()
+	cachedDevice := *device
+	project.devices[deviceID] = &cachedDevice
+	c.mu.Unlock()
+
+	return device, nil
+}
+
+// Invalidate removes a device from the cache after it has been modified, so that the next
+// lookup gets it from the Equinix Metal API.
+func (c *DeviceCache) Invalidate(projectID, deviceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if project, ok := c.projects[projectID]; ok {
+		delete(project.devices, deviceID)
+	}
+}
+
+// InvalidateDevice removes a device from the cache whatever its project, so that the next
+// lookup gets it from the Equinix Metal API.
+func (c *DeviceCache) InvalidateDevice(deviceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, project := range c.projects {
+		delete(project.devices, deviceID)
+	}
+}
+
+// refresh lists the devices of every cached project and requeues the EquinixMetalMachines
+// whose device changed state or disappeared since the previous listing.
+func (c *DeviceCache) refresh(ctx context.Context) {
+	log := ctrl.LoggerFrom(ctx)
+
+	changed := []*metal.Device{}
+
+	for _, projectID := range c.projectIDs() {
+		devices, err := c.MetalClient.ListDevices(ctx, projectID)
+		if err != nil {
+			log.Error(err, "Failed to list devices", "project", projectID)
+
+			continue
+		}
+
+		changed = append(changed, c.update(projectID, devices)...)
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+
+	if err := c.notify(ctx, changed); err != nil {
+		log.Error(err, "Failed to requeue EquinixMetalMachines of changed devices")
+	}
+}
+
+// projectIDs returns the cached projects, forgetting the ones that have not been used recently.
+func (c *DeviceCache) projectIDs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	projectIDs := make([]string, 0, len(c.projects))
+
+	for projectID, project := range c.projects {
+		if time.Since(project.lastUsed) > cachedProjectExpiry {
+			delete(c.projects, projectID)
+
+			continue
+		}
+
+		projectIDs = append(projectIDs, projectID)
+	}
+
+	return projectIDs
+}
+
+// update replaces the devices of a project and returns the devices that changed state or
+// disappeared since the previous listing.
+func (c *DeviceCache) update(projectID string, devices []metal.Device) []*metal.Device {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	project, ok := c.projects[projectID]
+	if !ok {
+		return nil
+	}
+
+	changed := []*metal.Device{}
+	listed := make(map[string]*metal.Device, len(devices))
+
+	for i := range devices {
+		device := &devices[i]
+		listed[device.ID] = device
+
+		if previous, ok := project.devices[device.ID]; ok && previous.State != device.State {
+			changed = append(changed, device)
+		}
+	}
+
+	if project.listed {
+		for id, previous := range project.devices {
+			if _, ok := listed[id]; !ok {
+				changed = append(changed, previous)
+			}
+		}
+	}
+
+	project.devices = listed
+	project.listed = true
+
+	return changed
+}
+
+// notify sends the EquinixMetalMachines owning the given devices to the events channel. The devices are
+// matched by the providerID of the machines, or by their UID tag while their device is being created.
+func (c *DeviceCache) notify(ctx context.Context, devices []*metal.Device) error {
+	machines := new(infrav1.EquinixMetalMachineList)
+	if err := c.Client.List(ctx, machines); err != nil {
+		return fmt.Errorf("failed to list EquinixMetalMachines: %w", err)
+	}
+
+	byDeviceID := make(map[string]*infrav1.EquinixMetalMachine, len(machines.Items))
+	byUID := make(map[types.UID]*infrav1.EquinixMetalMachine, len(machines.Items))
+
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		byUID[machine.UID] = machine
+
+		if machine.Spec.ProviderID != nil && *machine.Spec.ProviderID != "" {
+			byDeviceID[metal.DeviceIDFromProviderID(*machine.Spec.ProviderID)] = machine
+		}
+	}
+
+	for _, device := range devices {
+		machine, ok := byDeviceID[device.ID]
+		if !ok {
+			uid, tagged := metal.MachineUIDFromTags(device.Tags)
+			if machine, ok = byUID[uid]; !tagged || !ok {
+				continue
+			}
+		}
+
+		select {
+		case c.events <- event.GenericEvent{Object: machine}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// fakeDeviceLister serves the devices of a project and counts the devices looked up one by one.
+type fakeDeviceLister struct {
+	mu      sync.Mutex
+	devices []metal.Device
+	gets    int
+}
+
+func (l *fakeDeviceLister) setDevices(devices ...metal.Device) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.devices = devices
+}
+
+func (l *fakeDeviceLister) GetDevice(_ context.Context, deviceID string) (*metal.Device, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.gets++
+
+	for i := range l.devices {
+		if l.devices[i].ID == deviceID {
+			device := l.devices[i]
+
+			return &device, nil
+		}
+	}
+
+	return nil, &metal.APIError{StatusCode: http.StatusNotFound} //nolint:exhaustivestruct
+}
+
+func (l *fakeDeviceLister) ListDevices(_ context.Context, _ string) ([]metal.Device, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]metal.Device{}, l.devices...), nil
+}
+
+// notifiedMachines returns the names of the EquinixMetalMachines sent to the events channel of the cache.
+func notifiedMachines(c *DeviceCache) []string {
+	names := []string{}
+
+	for {
+		select {
+		case e := <-c.Events():
+			names = append(names, e.Object.GetName())
+		default:
+			sort.Strings(names)
+
+			return names
+		}
+	}
+}
+
+func TestDeviceCache(t *testing.T) {
+	t.Parallel()
+
+	providerID := "equinixmetal://provisioned"
+	machines := []*infrav1.EquinixMetalMachine{
+		{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "provisioned"},  //nolint:exhaustivestruct
+			Spec:       infrav1.EquinixMetalMachineSpec{ProviderID: &providerID}, //nolint:exhaustivestruct
+		},
+		{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "creating", UID: "creating-uid"}, //nolint:exhaustivestruct
+		},
+	}
+
+	provisioned := metal.Device{ID: "provisioned", State: metal.DeviceStateActive} //nolint:exhaustivestruct
+	creating := metal.Device{                                                      //nolint:exhaustivestruct
+		ID:    "creating",
+		State: metal.DeviceStateProvisioning,
+		Tags:  []string{metal.MachineTag("creating-uid")},
+	}
+	unowned := metal.Device{ID: "unowned", State: metal.DeviceStateProvisioning} //nolint:exhaustivestruct
+
+	withState := func(device metal.Device, state string) metal.Device {
+		device.State = state
+
+		return device
+	}
+
+	lister := new(fakeDeviceLister)
+	lister.setDevices(provisioned, creating, unowned)
+
+	cache := NewDeviceCache(lister, time.Minute)
+	cache.Client = newTestClient(t, machines[0], machines[1])
+
+	ctx := context.Background()
+
+	if _, err := cache.Device(ctx, "project", "provisioned"); err != nil {
+		t.Fatalf("Device() error = %v", err)
+	}
+
+	steps := []struct {
+		name    string
+		devices []metal.Device
+		want    []string
+	}{
+		{name: "first listing", devices: []metal.Device{provisioned, creating, unowned}, want: []string{}},
+		{name: "unchanged", devices: []metal.Device{provisioned, creating, unowned}, want: []string{}},
+		{
+			name:    "changed state",
+			devices: []metal.Device{provisioned, withState(creating, metal.DeviceStateActive), unowned},
+			want:    []string{"creating"},
+		},
+		{
+			name: "changed state of unowned device",
+			devices: []metal.Device{
+				provisioned, withState(creating, metal.DeviceStateActive), withState(unowned, metal.DeviceStateActive),
+			},
+			want: []string{},
+		},
+		{
+			name:    "deleted",
+			devices: []metal.Device{withState(creating, metal.DeviceStateActive), unowned},
+			want:    []string{"provisioned"},
+		},
+	}
+
+	for _, step := range steps {
+		lister.setDevices(step.devices...)
+		cache.refresh(ctx)
+
+		if got := notifiedMachines(cache); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: notified machines = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	device, err := cache.Device(ctx, "project", "creating")
+	if err != nil {
+		t.Fatalf("Device() error = %v", err)
+	}
+
+	if device.State != metal.DeviceStateActive {
+		t.Errorf("Device() state = %q, want %q", device.State, metal.DeviceStateActive)
+	}
+
+	if lister.gets != 1 {
+		t.Errorf("Device() got %d devices from the API, want 1", lister.gets)
+	}
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// DeviceEventsPath is the path of the HTTP endpoint receiving Equinix Metal events.
+	DeviceEventsPath = "/metal-events"
+
+	maxEventSize          = 1 << 20
+	receiverReadTimeout   = 10 * time.Second
+	receiverShutdownDelay = 5 * time.Second
+)
+
+// ErrMissingReceiverToken is returned when the HTTP endpoint receiving events is enabled without a token.
+var ErrMissingReceiverToken = errors.New("the device event receiver requires a bearer token")
+
+// DeviceEventSource requeues EquinixMetalMachines within seconds of Equinix Metal reporting an
+// event for their device, such as it being provisioned, powered off, deleted or failing. Events
+// are polled from the projects of the EquinixMetalClusters, received on an HTTP endpoint, or both.
+type DeviceEventSource struct {
+	client.Client
+	MetalClient *metal.Client
+
+	// PollInterval is how often the project events are listed. Polling is disabled when 0.
+	PollInterval time.Duration
+	// ReceiverAddr is the bind address of the HTTP endpoint receiving events. It is disabled when empty.
+	ReceiverAddr string
+	// ReceiverToken is the bearer token event producers must authenticate with. The HTTP endpoint
+	// refuses to start without it.
+	ReceiverToken string
+	// DeviceCache, if set, forgets the devices that had an event, so that the requeued
+	// EquinixMetalMachines see their current state.
+	DeviceCache *DeviceCache
+
+	events   chan event.GenericEvent
+	lastSeen map[string]time.Time
+}
+
+// NewDeviceEventSource returns a new DeviceEventSource. It must be added to a Manager with SetupWithManager.
+func NewDeviceEventSource(metalClient *metal.Client) *DeviceEventSource {
+	return &DeviceEventSource{ //nolint:exhaustivestruct
+		MetalClient: metalClient,
+		events:      make(chan event.GenericEvent, deviceEventsBufferSize),
+	}
+}
+
+// SetupWithManager adds the DeviceEventSource to the Manager.
+func (s *DeviceEventSource) SetupWithManager(mgr ctrl.Manager) error {
+	if s.Client == nil {
+		s.Client = mgr.GetClient()
+	}
+
+	if err := mgr.Add(s); err != nil {
+		return fmt.Errorf("failed to add device event source to manager: %w", err)
+	}
+
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the events are only consumed by the leader.
+func (s *DeviceEventSource) NeedLeaderElection() bool {
+	return true
+}
+
+// Events returns the channel on which the EquinixMetalMachines whose device had an event are sent.
+func (s *DeviceEventSource) Events() <-chan event.GenericEvent {
+	return s.events
+}
+
+// Start implements manager.Runnable and blocks until the context is cancelled.
+func (s *DeviceEventSource) Start(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx).WithName("device-events")
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	errs := make(chan error, 1)
+
+	if s.ReceiverAddr != "" {
+		if s.ReceiverToken == "" {
+			return ErrMissingReceiverToken
+		}
+
+		server := &http.Server{ //nolint:exhaustivestruct
+			Addr:        s.ReceiverAddr,
+			Handler:     s.receiver(ctx),
+			ReadTimeout: receiverReadTimeout,
+		}
+
+		go func() {
+			<-ctx.Done()
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), receiverShutdownDelay)
+			defer cancel()
+
+			_ = server.Shutdown(shutdownCtx) //nolint:contextcheck
+		}()
+
+		go func() {
+			log.Info("Starting device event receiver", "addr", s.ReceiverAddr, "path", DeviceEventsPath)
+
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("device event receiver failed: %w", err)
+			}
+		}()
+	}
+
+	if s.PollInterval > 0 {
+		s.lastSeen = map[string]time.Time{}
+
+		log.Info("Starting device event poller", "interval", s.PollInterval)
+
+		go wait.UntilWithContext(ctx, func(ctx context.Context) {
+			if err := s.poll(ctx); err != nil {
+				log.Error(err, "Device event polling failed")
+			}
+		}, s.PollInterval)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+// receiver returns the handler of the HTTP endpoint receiving the Equinix Metal events.
+func (s *DeviceEventSource) receiver(ctx context.Context) http.Handler {
+	log := ctrl.LoggerFrom(ctx)
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(DeviceEventsPath, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		if !s.authorized(req) {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		var e metal.Event
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxEventSize)).Decode(&e); err != nil {
+			http.Error(w, "invalid event", http.StatusBadRequest)
+
+			return
+		}
+
+		if deviceID := e.DeviceID(); e.IsDeviceStateEvent() && deviceID != "" {
+			log.V(4).Info("Received device event", "type", e.Type, "device", deviceID)
+
+			if err := s.requeue(ctx, sets.NewString(deviceID)); err != nil {
+				log.Error(err, "Failed to requeue EquinixMetalMachine of device", "device", deviceID)
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	return mux
+}
+
+func (s *DeviceEventSource) authorized(req *http.Request) bool {
+	if s.ReceiverToken == "" {
+		return false
+	}
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.ReceiverToken)) == 1
+}
+
+// poll lists the events of the projects of the EquinixMetalClusters created since the previous poll.
+func (s *DeviceEventSource) poll(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	projectIDs, err := s.projectIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	deviceIDs := sets.NewString()
+
+	for _, projectID := range projectIDs.List() {
+		since, ok := s.lastSeen[projectID]
+		if !ok {
+			// Only react to the events that happen from now on.
+			s.lastSeen[projectID] = time.Now()
+
+			continue
+		}
+
+		events, err := s.MetalClient.ListProjectEvents(ctx, projectID, since)
+		if err != nil {
+			log.Error(err, "Failed to list events", "project", projectID)
+
+			continue
+		}
+
+		for i := range events {
+			if events[i].CreatedAt.After(s.lastSeen[projectID]) {
+				s.lastSeen[projectID] = events[i].CreatedAt
+			}
+
+			if deviceID := events[i].DeviceID(); events[i].IsDeviceStateEvent() && deviceID != "" {
+				deviceIDs.Insert(deviceID)
+			}
+		}
+	}
+
+	for projectID := range s.lastSeen {
+		if !projectIDs.Has(projectID) {
+			delete(s.lastSeen, projectID)
+		}
+	}
+
+	if deviceIDs.Len() == 0 {
+		return nil
+	}
+
+	return s.requeue(ctx, deviceIDs)
+}
+
+func (s *DeviceEventSource) projectIDs(ctx context.Context) (sets.String, error) {
+	clusters := new(infrav1.EquinixMetalClusterList)
+	if err := s.Client.List(ctx, clusters); err != nil {
+		return nil, fmt.Errorf("failed to list EquinixMetalClusters: %w", err)
+	}
+
+	projectIDs := sets.NewString()
+
+	for _, cluster := range clusters.Items {
+		if cluster.Spec.ProjectID != "" {
+			projectIDs.Insert(cluster.Spec.ProjectID)
+		}
+	}
+
+	return projectIDs, nil
+}
+
+// requeue sends the EquinixMetalMachines of the given devices to the events channel, after
+// removing the devices from the device cache.
+func (s *DeviceEventSource) requeue(ctx context.Context, deviceIDs sets.String) error {
+	if s.DeviceCache != nil {
+		for _, deviceID := range deviceIDs.List() {
+			s.DeviceCache.InvalidateDevice(deviceID)
+		}
+	}
+
+	machines := new(infrav1.EquinixMetalMachineList)
+	if err := s.Client.List(ctx, machines); err != nil {
+		return fmt.Errorf("failed to list EquinixMetalMachines: %w", err)
+	}
+
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if machine.Spec.ProviderID == nil || !deviceIDs.Has(metal.DeviceIDFromProviderID(*machine.Spec.ProviderID)) {
+			continue
+		}
+
+		select {
+		case s.events <- event.GenericEvent{Object: machine}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// newDeviceEventTestMachines returns EquinixMetalMachines whose devices have the given IDs.
+func newDeviceEventTestMachines(deviceIDs ...string) []client.Object {
+	machines := make([]client.Object, 0, len(deviceIDs))
+
+	for _, id := range deviceIDs {
+		providerID := "equinixmetal://" + id
+		machines = append(machines, &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: id},             //nolint:exhaustivestruct
+			Spec:       infrav1.EquinixMetalMachineSpec{ProviderID: &providerID}, //nolint:exhaustivestruct
+		})
+	}
+
+	return machines
+}
+
+// notifiedEventMachines returns the names of the EquinixMetalMachines sent to the events channel of the source.
+func notifiedEventMachines(s *DeviceEventSource) []string {
+	names := []string{}
+
+	for {
+		select {
+		case e := <-s.Events():
+			names = append(names, e.Object.GetName())
+		default:
+			return names
+		}
+	}
+}
+
+func TestDeviceEventReceiver(t *testing.T) {
+	t.Parallel()
+
+	deviceEvent := `{"type": "instance.powered_off", "relationships": [{"href": "/metal/v1/devices/a"}]}`
+
+	tests := []struct {
+		name          string
+		receiverToken string
+		method        string
+		authorization string
+		body          string
+		wantStatus    int
+		want          []string
+	}{
+		{
+			name:          "device event",
+			receiverToken: "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer secret",
+			body:          deviceEvent,
+			wantStatus:    http.StatusAccepted,
+			want:          []string{"a"},
+		},
+		{
+			name:          "event of another device",
+			receiverToken: "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer secret",
+			body:          `{"type": "instance.deleted", "relationships": [{"href": "/metal/v1/devices/unknown"}]}`,
+			wantStatus:    http.StatusAccepted,
+			want:          []string{},
+		},
+		{
+			name:          "other event",
+			receiverToken: "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer secret",
+			body:          `{"type": "instance.created", "relationships": [{"href": "/metal/v1/devices/a"}]}`,
+			wantStatus:    http.StatusAccepted,
+			want:          []string{},
+		},
+		{
+			name:          "invalid event",
+			receiverToken: "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer secret",
+			body:          "{",
+			wantStatus:    http.StatusBadRequest,
+			want:          []string{},
+		},
+		{
+			name:          "wrong token",
+			receiverToken: "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer guess",
+			body:          deviceEvent,
+			wantStatus:    http.StatusUnauthorized,
+			want:          []string{},
+		},
+		{
+			name:          "no token",
+			receiverToken: "secret",
+			method:        http.MethodPost,
+			body:          deviceEvent,
+			wantStatus:    http.StatusUnauthorized,
+			want:          []string{},
+		},
+		{
+			name:          "no receiver token",
+			receiverToken: "",
+			method:        http.MethodPost,
+			authorization: "Bearer ",
+			body:          deviceEvent,
+			wantStatus:    http.StatusUnauthorized,
+			want:          []string{},
+		},
+		{
+			name:          "GET",
+			receiverToken: "secret",
+			method:        http.MethodGet,
+			authorization: "Bearer secret",
+			wantStatus:    http.StatusMethodNotAllowed,
+			want:          []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewDeviceEventSource(nil)
+			s.Client = newTestClient(t, newDeviceEventTestMachines("a", "b")...)
+			s.ReceiverToken = tt.receiverToken
+
+			req := httptest.NewRequest(tt.method, DeviceEventsPath, strings.NewReader(tt.body))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			resp := httptest.NewRecorder()
+			s.receiver(context.Background()).ServeHTTP(resp, req)
+
+			if resp.Code != tt.wantStatus {
+				t.Errorf("receiver status = %d, want %d", resp.Code, tt.wantStatus)
+			}
+
+			if got := notifiedEventMachines(s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("receiver notified machines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceEventPoller(t *testing.T) {
+	t.Parallel()
+
+	lastPoll := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	event := func(eventType, deviceID string, after time.Duration) metal.Event {
+		return metal.Event{ //nolint:exhaustivestruct
+			Type:          eventType,
+			Relationships: []metal.Href{{Href: "/metal/v1/devices/" + deviceID}},
+			CreatedAt:     lastPoll.Add(after),
+		}
+	}
+
+	// Events are listed newest first.
+	events := []metal.Event{
+		event(metal.EventTypeDevicePoweredOn, "a", 4*time.Second),
+		event(metal.EventTypeDevicePoweredOff, "a", 3*time.Second),
+		event("instance.created", "b", 2*time.Second),
+		event(metal.EventTypeDeviceProvisioned, "b", time.Second),
+		event(metal.EventTypeDeviceFailed, "c", 0),
+	}
+
+	objects := newDeviceEventTestMachines("a", "b", "c")
+	for _, projectID := range []string{"project", "new"} {
+		objects = append(objects, &infrav1.EquinixMetalCluster{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: projectID},   //nolint:exhaustivestruct
+			Spec:       infrav1.EquinixMetalClusterSpec{ProjectID: projectID}, //nolint:exhaustivestruct
+		})
+	}
+
+	s := NewDeviceEventSource(newTestMetalClient(t, map[string]interface{}{
+		"/projects/project/events": map[string]interface{}{"events": events},
+	}))
+	s.Client = newTestClient(t, objects...)
+	s.lastSeen = map[string]time.Time{"project": lastPoll, "gone": lastPoll}
+
+	ctx := context.Background()
+
+	if err := s.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+
+	// The events of a device are coalesced, and the event of the previous poll is not seen again.
+	got := notifiedEventMachines(s)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("poll() notified machines = %v, want %v", got, want)
+	}
+
+	if want := lastPoll.Add(4 * time.Second); !s.lastSeen["project"].Equal(want) {
+		t.Errorf("poll() last seen event of project = %v, want %v", s.lastSeen["project"], want)
+	}
+
+	if _, ok := s.lastSeen["new"]; !ok {
+		t.Errorf("poll() did not start following the events of the new project")
+	}
+
+	if _, ok := s.lastSeen["gone"]; ok {
+		t.Errorf("poll() kept following the events of a removed project")
+	}
+
+	if err := s.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+
+	if got := notifiedEventMachines(s); len(got) != 0 {
+		t.Errorf("second poll() notified machines = %v, want none", got)
+	}
+}
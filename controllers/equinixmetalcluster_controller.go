@@ -18,54 +18,373 @@ package controllers
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// defaultAPIServerPort is the port of the control plane endpoint when the Cluster does not set one.
+	defaultAPIServerPort = 6443
+
+	// devicesReleaseRequeueInterval is how often a deleted cluster checks whether its devices are gone.
+	devicesReleaseRequeueInterval = 15 * time.Second
 )
 
 // EquinixMetalClusterReconciler reconciles a EquinixMetalCluster object.
 type EquinixMetalClusterReconciler struct {
 	client.Client
+	MetalClient      *metal.Client
 	Recorder         record.EventRecorder
 	WatchFilterValue string
-}
 
-var ErrNotImplemented = errors.New("not implemented yet")
+	// ManagerID identifies the management cluster in the ownership tags of the IP reservations when set.
+	ManagerID string
+}
 
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalclusters/finalizers,verbs=update
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalmachines,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the EquinixMetalCluster object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
-func (r *EquinixMetalClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+// Reconcile reserves the network resources of an EquinixMetalCluster and releases them on deletion.
+// The network resources of externally managed EquinixMetalClusters are only validated.
+func (r *EquinixMetalClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	log := ctrl.LoggerFrom(ctx)
-	log.Info("In Reconcile")
 
-	// TODO(user): your logic here
+	equinixMetalCluster := new(infrav1.EquinixMetalCluster)
+	if err := r.Client.Get(ctx, req.NamespacedName, equinixMetalCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("failed to get EquinixMetalCluster: %w", err)
+	}
+
+	cluster, err := util.GetOwnerCluster(ctx, r.Client, equinixMetalCluster.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get owner Cluster: %w", err)
+	}
+
+	if cluster == nil {
+		log.Info("Cluster Controller has not yet set OwnerRef")
+
+		return ctrl.Result{}, nil
+	}
+
+	if annotations.IsPaused(cluster, equinixMetalCluster) {
+		log.Info("EquinixMetalCluster or linked Cluster is marked as paused. Won't reconcile")
+
+		return ctrl.Result{}, nil
+	}
+
+	log = log.WithValues("cluster", cluster.Name)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	patchHelper, err := patch.NewHelper(equinixMetalCluster, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to init patch helper: %w", err)
+	}
+
+	defer func() {
+		conditions.SetSummary(equinixMetalCluster,
+			conditions.WithConditions(
+				infrav1.ProjectReadyCondition,
+				infrav1.NetworkInfrastructureReadyCondition,
+				infrav1.SSHKeysReadyCondition,
+			),
+		)
+
+		if err := patchHelper.Patch(
+			ctx,
+			equinixMetalCluster,
+			patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+				clusterv1.ReadyCondition,
+				infrav1.NetworkInfrastructureReadyCondition,
+				infrav1.ProjectReadyCondition,
+				infrav1.SSHKeysReadyCondition,
+				infrav1.HardwareReservationsRetainedCondition,
+			}},
+		); err != nil && reterr == nil {
+			reterr = fmt.Errorf("failed to patch EquinixMetalCluster: %w", err)
+		}
+	}()
+
+	if annotations.IsExternallyManaged(equinixMetalCluster) {
+		if !equinixMetalCluster.DeletionTimestamp.IsZero() {
+			reconcileExternallyManagedDelete(equinixMetalCluster)
+
+			return ctrl.Result{}, nil
+		}
+
+		result, err := r.reconcileExternallyManaged(ctx, cluster, equinixMetalCluster)
+
+		return requeueOnMetalAPIUnavailable(ctx, result, err)
+	}
+
+	if !equinixMetalCluster.DeletionTimestamp.IsZero() {
+		result, err := r.reconcileDelete(ctx, cluster, equinixMetalCluster)
+
+		return requeueOnMetalAPIUnavailable(ctx, result, err)
+	}
 
-	return ctrl.Result{}, ErrNotImplemented
+	result, err := r.reconcileNormal(ctx, cluster, equinixMetalCluster)
+
+	return requeueOnMetalAPIUnavailable(ctx, result, err)
+}
+
+func (r *EquinixMetalClusterReconciler) reconcileNormal(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) (ctrl.Result, error) {
+	controllerutil.AddFinalizer(equinixMetalCluster, infrav1.ClusterFinalizer)
+
+	if equinixMetalCluster.Spec.Project != nil {
+		if err := r.reconcileProject(ctx, cluster, equinixMetalCluster); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if equinixMetalCluster.Spec.ControlPlaneEndpoint.Host == "" {
+		reservation, err := r.ensureControlPlaneEndpoint(ctx, cluster, equinixMetalCluster)
+		if err != nil {
+			conditions.MarkFalse(equinixMetalCluster, infrav1.NetworkInfrastructureReadyCondition,
+				infrav1.ControlPlaneEndpointFailedReason, clusterv1.ConditionSeverityError, err.Error())
+
+			return ctrl.Result{}, err
+		}
+
+		port := int32(defaultAPIServerPort)
+		if cluster.Spec.ClusterNetwork != nil && cluster.Spec.ClusterNetwork.APIServerPort != nil {
+			port = *cluster.Spec.ClusterNetwork.APIServerPort
+		}
+
+		equinixMetalCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
+			Host: reservation.Address,
+			Port: port,
+		}
+	}
+
+	if err := r.reconcileSSHKeys(ctx, cluster, equinixMetalCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileCost(ctx, cluster, equinixMetalCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileRetainedHardwareReservations(ctx, cluster, equinixMetalCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	conditions.MarkTrue(equinixMetalCluster, infrav1.NetworkInfrastructureReadyCondition)
+	equinixMetalCluster.Status.Ready = true
+
+	return ctrl.Result{}, nil
+}
+
+// ensureControlPlaneEndpoint returns the IP reserved for the control plane endpoint of the cluster,
+// reserving a new one when there is none yet.
+func (r *EquinixMetalClusterReconciler) ensureControlPlaneEndpoint(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) (*metal.IPReservation, error) {
+	projectID := equinixMetalCluster.Spec.ProjectID
+	tags := []string{metal.ClusterTag(cluster.Namespace, cluster.Name), metal.ControlPlaneEndpointTag}
+
+	reservation, err := r.MetalClient.GetIPReservationByTags(ctx, projectID, tags...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up control plane endpoint: %w", err)
+	}
+
+	if r.ManagerID != "" {
+		tags = append(tags, metal.ManagerTag(r.ManagerID))
+	}
+
+	if reservation != nil {
+		return reservation, r.reconcileIPReservationTags(ctx, reservation, tags)
+	}
+
+	reservation, err = r.MetalClient.CreateIPReservation(ctx, projectID, &metal.IPReservationRequest{
+		Type:     metal.PublicIPv4,
+		Quantity: 1,
+		Metro:    equinixMetalCluster.Spec.Metro,
+		Facility: equinixMetalCluster.Spec.Facility,
+		Tags:     tags,
+		Comments: fmt.Sprintf("Control plane endpoint of cluster %s/%s", cluster.Namespace, cluster.Name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve control plane endpoint: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Reserved control plane endpoint", "address", reservation.Address)
+	r.Recorder.Eventf(equinixMetalCluster, corev1.EventTypeNormal, "SuccessfulReserveIP",
+		"Reserved control plane endpoint %s", reservation.Address)
+
+	return reservation, nil
+}
+
+// reconcileIPReservationTags replaces the ownership tags of the IP reservation with the current ones, for
+// instance after the cluster was moved to another management cluster, and keeps its other tags.
+func (r *EquinixMetalClusterReconciler) reconcileIPReservationTags(
+	ctx context.Context,
+	reservation *metal.IPReservation,
+	ownershipTags []string,
+) error {
+	tags := metal.ReplaceOwnershipTags(reservation.Tags, ownershipTags)
+	if metal.EqualTags(tags, reservation.Tags) {
+		return nil
+	}
+
+	if err := r.MetalClient.UpdateIPReservationTags(ctx, reservation.ID, tags); err != nil {
+		return fmt.Errorf("failed to update control plane endpoint tags: %w", err)
+	}
+
+	reservation.Tags = tags
+
+	return nil
+}
+
+func (r *EquinixMetalClusterReconciler) reconcileDelete(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) (ctrl.Result, error) {
+	if equinixMetalCluster.Spec.ProjectID == "" {
+		// The managed project was never created.
+		controllerutil.RemoveFinalizer(equinixMetalCluster, infrav1.ClusterFinalizer)
+
+		return ctrl.Result{}, nil
+	}
+
+	// The devices of the cluster may still use its resources, such as the control plane endpoint.
+	released, err := r.devicesReleased(ctx, cluster, equinixMetalCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !released {
+		return ctrl.Result{RequeueAfter: devicesReleaseRequeueInterval}, nil
+	}
+
+	reservation, err := r.MetalClient.GetIPReservationByTags(
+		ctx,
+		equinixMetalCluster.Spec.ProjectID,
+		metal.ClusterTag(cluster.Namespace, cluster.Name),
+		metal.ControlPlaneEndpointTag,
+	)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to look up control plane endpoint: %w", err)
+	}
+
+	if reservation != nil {
+		if err := r.MetalClient.DeleteIPReservation(ctx, reservation.ID); err != nil && !metal.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to release control plane endpoint: %w", err)
+		}
+
+		ctrl.LoggerFrom(ctx).Info("Released control plane endpoint", "address", reservation.Address)
+		r.Recorder.Eventf(equinixMetalCluster, corev1.EventTypeNormal, "SuccessfulReleaseIP",
+			"Released control plane endpoint %s", reservation.Address)
+	}
+
+	if err := r.releaseRetainedHardwareReservations(ctx, cluster, equinixMetalCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.deleteSSHKeys(ctx, cluster, equinixMetalCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if equinixMetalCluster.Spec.Project != nil {
+		deleted, err := r.deleteProject(ctx, cluster, equinixMetalCluster)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if !deleted {
+			return ctrl.Result{RequeueAfter: projectDeletionRequeueInterval}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(equinixMetalCluster, infrav1.ClusterFinalizer)
+
+	return ctrl.Result{}, nil
+}
+
+// devicesReleased returns true once all the EquinixMetalMachines of the cluster are gone and the project
+// has no devices of the cluster left, reporting the progress of the deletion on the
+// NetworkInfrastructureReady condition until then.
+func (r *EquinixMetalClusterReconciler) devicesReleased(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	machines := new(infrav1.EquinixMetalMachineList)
+	if err := r.Client.List(ctx, machines, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name}); err != nil {
+		return false, fmt.Errorf("failed to list EquinixMetalMachines: %w", err)
+	}
+
+	if len(machines.Items) > 0 {
+		log.Info("Waiting for the EquinixMetalMachines of the cluster to be deleted", "machines", len(machines.Items))
+		conditions.MarkFalse(equinixMetalCluster, infrav1.NetworkInfrastructureReadyCondition,
+			clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo,
+			"waiting for %d EquinixMetalMachines to release their devices", len(machines.Items))
+
+		return false, nil
+	}
+
+	devices, err := r.MetalClient.ListDevices(ctx, equinixMetalCluster.Spec.ProjectID)
+	if err != nil && !metal.IsNotFound(err) {
+		return false, fmt.Errorf("failed to list devices of project: %w", err)
+	}
+
+	remaining := 0
+
+	for i := range devices {
+		if metal.HasTags(devices[i].Tags, metal.ClusterTag(cluster.Namespace, cluster.Name)) {
+			remaining++
+		}
+	}
+
+	if remaining > 0 {
+		log.Info("Waiting for the devices of the cluster to be deprovisioned", "devices", remaining)
+		conditions.MarkFalse(equinixMetalCluster, infrav1.NetworkInfrastructureReadyCondition,
+			clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo,
+			"waiting for %d devices to be deprovisioned", remaining)
+
+		return false, nil
+	}
+
+	conditions.MarkFalse(equinixMetalCluster, infrav1.NetworkInfrastructureReadyCondition,
+		clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "releasing network infrastructure")
+
+	return true, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -89,8 +408,6 @@ func (r *EquinixMetalClusterReconciler) SetupWithManager(
 		For(new(infrav1.EquinixMetalCluster)).
 		// Filter out any paused or filtered resources
 		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(log, r.WatchFilterValue)).
-		// Filter out any externally managed resources
-		WithEventFilter(predicates.ResourceIsNotExternallyManaged(log)).
 		// Watch for changes in CAPI Cluster resources
 		Watches(
 			&source.Kind{Type: new(clusterv1.Cluster)},
@@ -98,6 +415,21 @@ func (r *EquinixMetalClusterReconciler) SetupWithManager(
 				util.ClusterToInfrastructureMapFunc(infrav1.GroupVersion.WithKind("EquinixMetalCluster")),
 			),
 			builder.WithPredicates(predicates.ClusterUnpaused(log)),
+		).
+		// Watch for changes in the costs of the EquinixMetalMachines of the cluster
+		Watches(
+			&source.Kind{Type: new(infrav1.EquinixMetalMachine)},
+			handler.EnqueueRequestsFromMapFunc(r.equinixMetalMachineToEquinixMetalCluster),
+			builder.WithPredicates(machineCostChanged()),
+		).
+		// Watch for changes in the SSH keys referenced by the EquinixMetalClusters
+		Watches(
+			&source.Kind{Type: new(corev1.Secret)},
+			handler.EnqueueRequestsFromMapFunc(r.secretOrConfigMapToEquinixMetalClusters),
+		).
+		Watches(
+			&source.Kind{Type: new(corev1.ConfigMap)},
+			handler.EnqueueRequestsFromMapFunc(r.secretOrConfigMapToEquinixMetalClusters),
 		)
 
 	if err := ctrlBuilder.Complete(r); err != nil {
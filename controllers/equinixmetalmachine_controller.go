@@ -18,28 +18,178 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/metrics"
 )
 
+const (
+	// deviceProvisioningRequeueInterval is how often a device is checked while it's being provisioned.
+	deviceProvisioningRequeueInterval = 30 * time.Second
+)
+
+// ErrMissingBootstrapData is returned when the bootstrap data secret has no value.
+var ErrMissingBootstrapData = errors.New("bootstrap data secret value key is missing")
+
 // EquinixMetalMachineReconciler reconciles a EquinixMetalMachine object.
 type EquinixMetalMachineReconciler struct {
 	client.Client
+	MetalClient      *metal.Client
 	Recorder         record.EventRecorder
 	WatchFilterValue string
+
+	// DeviceCache is used to look up the devices of the machines when set.
+	DeviceCache *DeviceCache
+	// DeviceEventSource requeues the machines on Equinix Metal device events when set.
+	DeviceEventSource *DeviceEventSource
+	// PlanCache is used to look up the prices of the devices, it is created with MetalClient when not set.
+	PlanCache *metal.PlanCache
+
+	// ProvisioningTimeout is how long devices can take to become active, unless overridden by the
+	// EquinixMetalMachines. There is no timeout when it is 0.
+	ProvisioningTimeout time.Duration
+	// ProvisioningMaxRetries is how many times a device that failed to provision is deleted and created again.
+	ProvisioningMaxRetries int
+	// ManagerID identifies the management cluster in the ownership tags of the devices when set.
+	ManagerID string
+}
+
+// machineScope holds the objects involved in the reconciliation of an EquinixMetalMachine.
+type machineScope struct {
+	cluster             *clusterv1.Cluster
+	machine             *clusterv1.Machine
+	equinixMetalCluster *infrav1.EquinixMetalCluster
+	equinixMetalMachine *infrav1.EquinixMetalMachine
+	managerID           string
+}
+
+// projectID returns the Equinix Metal project of the machine.
+func (s *machineScope) projectID() string {
+	return s.equinixMetalCluster.Spec.ProjectID
+}
+
+// metro returns the metro of the machine, defaulting to the one of the cluster.
+func (s *machineScope) metro() string {
+	if s.equinixMetalMachine.Spec.Metro != "" {
+		return s.equinixMetalMachine.Spec.Metro
+	}
+
+	return s.equinixMetalCluster.Spec.Metro
+}
+
+// facility returns the facility of the machine, defaulting to the failure domain
+// of the Machine and then to the facility of the cluster.
+func (s *machineScope) facility() string {
+	switch {
+	case s.equinixMetalMachine.Spec.Facility != "":
+		return s.equinixMetalMachine.Spec.Facility
+	case s.machine.Spec.FailureDomain != nil && *s.machine.Spec.FailureDomain != "":
+		return *s.machine.Spec.FailureDomain
+	}
+
+	return s.equinixMetalCluster.Spec.Facility
+}
+
+// placements returns the placement of the machine followed by its alternatives, ignoring the
+// alternatives in other locations when the Machine has a FailureDomain.
+func (s *machineScope) placements() []infrav1.Placement {
+	primary := infrav1.Placement{
+		Metro:       s.metro(),
+		Facility:    s.facility(),
+		MachineType: s.equinixMetalMachine.Spec.MachineType,
+	}
+
+	hasFailureDomain := s.machine.Spec.FailureDomain != nil && *s.machine.Spec.FailureDomain != ""
+	placements := []infrav1.Placement{primary}
+
+	for _, alternative := range s.equinixMetalMachine.Spec.Alternatives {
+		placement := primary
+
+		if alternative.Metro != "" && alternative.Metro != primary.Metro {
+			if hasFailureDomain {
+				continue
+			}
+
+			// Facilities belong to a metro, so the facility of the machine can't be kept.
+			placement.Metro = alternative.Metro
+			placement.Facility = ""
+		}
+
+		if alternative.Facility != "" && alternative.Facility != primary.Facility {
+			if hasFailureDomain {
+				continue
+			}
+
+			placement.Facility = alternative.Facility
+		}
+
+		if alternative.MachineType != "" {
+			placement.MachineType = alternative.MachineType
+		}
+
+		placements = append(placements, placement)
+	}
+
+	return placements
+}
+
+// ownershipTags returns the tags identifying the device of the machine.
+func (s *machineScope) ownershipTags() []string {
+	tags := []string{
+		metal.ClusterTag(s.cluster.Namespace, s.cluster.Name),
+		metal.MachineTag(s.equinixMetalMachine.UID),
+	}
+
+	if s.managerID != "" {
+		tags = append(tags, metal.ManagerTag(s.managerID))
+	}
+
+	return tags
+}
+
+// deviceTags returns the tags of the device of the machine: the tags of its spec followed by the
+// ownership tags, without duplicates.
+func (s *machineScope) deviceTags() []string {
+	var tags []string
+
+	seen := map[string]bool{}
+
+	for _, tag := range append(append([]string{}, s.equinixMetalMachine.Spec.Tags...), s.ownershipTags()...) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// setFailure records a terminal error on the EquinixMetalMachine.
+func (s *machineScope) setFailure(reason capierrors.MachineStatusError, message string) {
+	s.equinixMetalMachine.Status.FailureReason = &reason
+	s.equinixMetalMachine.Status.FailureMessage = &message
 }
 
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalmachines,verbs=get;list;watch;create;update;patch;delete
@@ -48,25 +198,716 @@ type EquinixMetalMachineReconciler struct {
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalclusters;equinixmetalclusters/status,verbs=get;list;watch
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=patch
 //+kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
 //+kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=create;update;patch;delete
+
+// Reconcile creates, updates and deletes the Equinix Metal device backing an EquinixMetalMachine.
+func (r *EquinixMetalMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	equinixMetalMachine := new(infrav1.EquinixMetalMachine)
+	if err := r.Client.Get(ctx, req.NamespacedName, equinixMetalMachine); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("failed to get EquinixMetalMachine: %w", err)
+	}
+
+	machine, err := util.GetOwnerMachine(ctx, r.Client, equinixMetalMachine.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get owner Machine: %w", err)
+	}
+
+	if machine == nil {
+		log.Info("Machine Controller has not yet set OwnerRef")
+
+		return ctrl.Result{}, nil
+	}
+
+	log = log.WithValues("machine", machine.Name)
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machine.ObjectMeta)
+	if err != nil {
+		log.Info("Machine is missing cluster label or cluster does not exist")
+
+		return ctrl.Result{}, nil
+	}
+
+	if annotations.IsPaused(cluster, equinixMetalMachine) {
+		log.Info("EquinixMetalMachine or linked Cluster is marked as paused. Won't reconcile")
+
+		return ctrl.Result{}, nil
+	}
+
+	log = log.WithValues("cluster", cluster.Name)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	if cluster.Spec.InfrastructureRef == nil {
+		log.Info("Cluster infrastructureRef is not available yet")
+
+		return ctrl.Result{}, nil
+	}
+
+	equinixMetalCluster := new(infrav1.EquinixMetalCluster)
+	equinixMetalClusterName := client.ObjectKey{
+		Namespace: equinixMetalMachine.Namespace,
+		Name:      cluster.Spec.InfrastructureRef.Name,
+	}
+
+	if err := r.Client.Get(ctx, equinixMetalClusterName, equinixMetalCluster); err != nil {
+		log.Info("EquinixMetalCluster is not available yet")
+
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(equinixMetalMachine, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to init patch helper: %w", err)
+	}
+
+	defer func() {
+		conditions.SetSummary(equinixMetalMachine, conditions.WithConditions(infrav1.DeviceReadyCondition))
+
+		if err := patchHelper.Patch(
+			ctx,
+			equinixMetalMachine,
+			patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+				clusterv1.ReadyCondition,
+				infrav1.DeviceReadyCondition,
+				infrav1.PowerActionSucceededCondition,
+				infrav1.RescueModeCondition,
+				infrav1.ReinstallSucceededCondition,
+			}},
+		); err != nil && reterr == nil {
+			reterr = fmt.Errorf("failed to patch EquinixMetalMachine: %w", err)
+		}
+	}()
+
+	scope := &machineScope{
+		cluster:             cluster,
+		machine:             machine,
+		equinixMetalCluster: equinixMetalCluster,
+		equinixMetalMachine: equinixMetalMachine,
+		managerID:           r.ManagerID,
+	}
+
+	if !equinixMetalMachine.DeletionTimestamp.IsZero() {
+		result, err := r.reconcileDelete(ctx, scope)
+
+		return requeueOnMetalAPIUnavailable(ctx, result, err)
+	}
+
+	result, err := r.reconcileNormal(ctx, scope)
+
+	return requeueOnMetalAPIUnavailable(ctx, result, err)
+}
+
+func (r *EquinixMetalMachineReconciler) reconcileNormal( //nolint:cyclop,funlen
+	ctx context.Context,
+	scope *machineScope,
+) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	if equinixMetalMachine.Status.FailureReason != nil || equinixMetalMachine.Status.FailureMessage != nil {
+		log.Info("EquinixMetalMachine has failed, will not reconcile")
+
+		return ctrl.Result{}, nil
+	}
+
+	controllerutil.AddFinalizer(equinixMetalMachine, infrav1.MachineFinalizer)
+
+	if !scope.cluster.Status.InfrastructureReady {
+		log.Info("Cluster infrastructure is not ready yet")
+		conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+			infrav1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+
+		return ctrl.Result{}, nil
+	}
+
+	if scope.machine.Spec.Bootstrap.DataSecretName == nil {
+		log.Info("Bootstrap data secret reference is not yet available")
+		conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+			infrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
+
+		return ctrl.Result{}, nil
+	}
+
+	device, err := r.findDevice(ctx, scope)
+
+	switch {
+	case metal.IsNotFound(err):
+		r.setDeviceFailure(ctx, scope, infrav1.InstanceNotFoundReason, capierrors.UpdateMachineError,
+			fmt.Sprintf("device %s was deleted outside of the cluster", *equinixMetalMachine.Spec.ProviderID))
+
+		return ctrl.Result{}, nil
+	case err != nil:
+		return ctrl.Result{}, err
+	}
+
+	if device == nil {
+		if device, err = r.createDevice(ctx, scope); err != nil || device == nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	observeProvisioningDuration(equinixMetalMachine.Status.InstanceStatus, device)
+
+	providerID := device.ProviderID()
+	equinixMetalMachine.Spec.ProviderID = &providerID
+	instanceStatus := infrav1.EquinixMetalResourceStatus(device.State)
+	equinixMetalMachine.Status.InstanceStatus = &instanceStatus
+	equinixMetalMachine.Status.Addresses = deviceAddresses(device)
+
+	switch device.State {
+	case metal.DeviceStateDeprovisioning:
+		r.setDeviceFailure(ctx, scope, infrav1.InstanceTerminatedReason, capierrors.UpdateMachineError,
+			fmt.Sprintf("device %s is being deprovisioned outside of the cluster", device.ID))
+
+		return ctrl.Result{}, nil
+	case metal.DeviceStateFailed:
+		return r.retryProvisioning(ctx, scope, device, fmt.Sprintf("device %s failed to provision", device.ID))
+	}
+
+	r.reconcileCost(ctx, scope, device)
+
+	if err := r.reconcileHardwareReservation(ctx, scope, device); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if r.provisioningTimedOut(scope, device) {
+		return r.retryProvisioning(ctx, scope, device, fmt.Sprintf("device %s did not become active within %s",
+			device.ID, r.provisioningTimeout(scope)))
+	}
+
+	if err := r.reconcileBootOptions(ctx, scope, device); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileTags(ctx, scope, device); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileDeviceLock(ctx, scope, device); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileDeletionHook(ctx, scope); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	performedAction, err := r.reconcilePowerAction(ctx, scope, device)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !performedAction {
+		if performedAction, err = r.reconcileReinstall(ctx, scope, device); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !performedAction {
+		if performedAction, err = r.reconcileRescueMode(ctx, scope, device); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if performedAction {
+		// Wait for the device to report the state resulting from the action.
+		return ctrl.Result{RequeueAfter: deviceProvisioningRequeueInterval}, nil
+	}
+
+	if device.State != metal.DeviceStateActive {
+		markDeviceNotReady(ctx, equinixMetalMachine, device.State)
+
+		return ctrl.Result{RequeueAfter: deviceProvisioningRequeueInterval}, nil
+	}
+
+	if err := r.reconcileSOSConsole(ctx, scope, device); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if util.IsControlPlaneMachine(scope.machine) {
+		if err := r.ensureControlPlaneEndpoint(ctx, scope, device); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	equinixMetalMachine.Status.Ready = true
+	conditions.MarkTrue(equinixMetalMachine, infrav1.DeviceReadyCondition)
+
+	return ctrl.Result{}, nil
+}
+
+// markDeviceNotReady sets the DeviceReadyCondition of a machine whose device is in the given
+// state other than active.
+func markDeviceNotReady(ctx context.Context, equinixMetalMachine *infrav1.EquinixMetalMachine, state string) {
+	log := ctrl.LoggerFrom(ctx)
+
+	switch state {
+	case metal.DeviceStateInactive:
+		log.Info("Device is powered off")
+		equinixMetalMachine.Status.Ready = false
+		conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+			infrav1.InstanceStoppedReason, clusterv1.ConditionSeverityWarning, "device is powered off")
+	case metal.DeviceStateQueued, metal.DeviceStateProvisioning, metal.DeviceStateReinstalling:
+		log.Info("Device is being provisioned", "state", state)
+		conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+			infrav1.InstanceNotReadyReason, clusterv1.ConditionSeverityInfo, "device is %s", state)
+	default:
+		log.Info("Device is not ready", "state", state)
+		equinixMetalMachine.Status.Ready = false
+		conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+			infrav1.InstanceNotReadyReason, clusterv1.ConditionSeverityWarning, "device is %s", state)
+	}
+}
+
+// findDevice returns the device of the machine, looking it up by its ownership tags when
+// the providerID has not been recorded yet, or nil if the device has not been created.
+func (r *EquinixMetalMachineReconciler) findDevice(ctx context.Context, scope *machineScope) (*metal.Device, error) {
+	if providerID := scope.equinixMetalMachine.Spec.ProviderID; providerID != nil && *providerID != "" {
+		deviceID := metal.DeviceIDFromProviderID(*providerID)
+
+		var (
+			device *metal.Device
+			err    error
+		)
+
+		if r.DeviceCache != nil {
+			device, err = r.DeviceCache.Device(ctx, scope.projectID(), deviceID)
+		} else {
+			device, err = r.MetalClient.GetDevice(ctx, deviceID)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get device: %w", err)
+		}
+
+		return device, nil
+	}
+
+	device, err := r.MetalClient.GetDeviceByTags(ctx, scope.projectID(), metal.MachineTag(scope.equinixMetalMachine.UID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up device: %w", err)
+	}
+
+	return device, nil
+}
+
+// setDeviceFailure records a device that can't be recovered, such as one deleted outside of the cluster,
+// as a terminal failure of the machine so that it gets remediated by a MachineHealthCheck.
+func (r *EquinixMetalMachineReconciler) setDeviceFailure(
+	ctx context.Context,
+	scope *machineScope,
+	reason string,
+	failureReason capierrors.MachineStatusError,
+	message string,
+) {
+	equinixMetalMachine := scope.equinixMetalMachine
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the EquinixMetalMachine object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
-func (r *EquinixMetalMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctrl.LoggerFrom(ctx).Info("Device failed", "reason", reason, "message", message)
+	scope.setFailure(failureReason, message)
+	equinixMetalMachine.Status.Ready = false
+	conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+		reason, clusterv1.ConditionSeverityError, message)
+	r.Recorder.Event(equinixMetalMachine, corev1.EventTypeWarning, reason, message)
+}
+
+// createDevice creates the device of the machine. It returns a nil device without error
+// when the machine can't be created because of a terminal error recorded in its status.
+func (r *EquinixMetalMachineReconciler) createDevice(
+	ctx context.Context,
+	scope *machineScope,
+) (*metal.Device, error) {
 	log := ctrl.LoggerFrom(ctx)
-	log.Info("In Reconcile")
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	bootstrapData, err := r.getBootstrapData(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := scope.deviceTags()
+
+	req := &metal.DeviceCreateRequest{ //nolint:exhaustivestruct
+		Hostname:              equinixMetalMachine.Name,
+		OperatingSystem:       equinixMetalMachine.Spec.OS,
+		BillingCycle:          equinixMetalMachine.Spec.BillingCycle,
+		UserData:              bootstrapData,
+		Tags:                  tags,
+		HardwareReservationID: equinixMetalMachine.Spec.HardwareReservationID,
+		Storage:               deviceStorage(equinixMetalMachine.Spec.Storage),
+		ProjectSSHKeys:        scope.equinixMetalCluster.Status.SSHKeyIDs,
+	}
+
+	retained, err := r.retainedHardwareReservation(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if retained != nil {
+		log.Info("Reusing retained hardware reservation", "hardwareReservation", retained.ID)
+		req.HardwareReservationID = retained.ID
+	}
+
+	if err := applyIPXE(req, scope, bootstrapData); err != nil {
+		log.Error(err, "Invalid iPXE configuration")
+		scope.setFailure(capierrors.InvalidConfigurationMachineError, err.Error())
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "InvalidIPXE", "Invalid iPXE configuration: %v", err)
+
+		return nil, nil //nolint:nilnil
+	}
+
+	device, placement, err := r.createDeviceWithFallback(ctx, scope, req)
+	if err != nil {
+		if pruneErr := r.pruneRetainedHardwareReservation(ctx, scope, retained, err); pruneErr != nil {
+			return nil, pruneErr
+		}
+
+		conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+			infrav1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityError, err.Error())
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "FailedCreate", "Failed to create device: %v", err)
+
+		return nil, fmt.Errorf("failed to create device: %w", err)
+	}
+
+	equinixMetalMachine.Status.Placement = placement
+	equinixMetalMachine.Status.BootstrapDataHash = bootstrapDataHash(bootstrapData)
+
+	log.Info("Created device", "device", device.ID, "metro", placement.Metro, "facility", placement.Facility,
+		"machineType", placement.MachineType)
+	r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeNormal, "SuccessfulCreate", "Created device %s", device.ID)
+	conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+		infrav1.InstanceProvisionStartedReason, clusterv1.ConditionSeverityInfo, "")
+
+	return device, nil
+}
+
+// createDeviceWithFallback creates the device in the first of the placements of the machine that has
+// capacity for it, and returns the device with the placement that was used.
+func (r *EquinixMetalMachineReconciler) createDeviceWithFallback(
+	ctx context.Context,
+	scope *machineScope,
+	req *metal.DeviceCreateRequest,
+) (*metal.Device, *infrav1.Placement, error) {
+	placements := scope.placements()
+
+	var err error
+
+	for i := range placements {
+		placement := &placements[i]
+
+		req.Plan = placement.MachineType
+		req.Metro = placement.Metro
+		req.Facility = nil
+
+		if placement.Facility != "" {
+			req.Facility = []string{placement.Facility}
+		}
+
+		var device *metal.Device
+
+		device, err = r.MetalClient.CreateDevice(ctx, scope.projectID(), req)
+		if err == nil {
+			return device, placement, nil
+		}
 
-	// TODO(user): your logic here
+		if !metal.IsCapacityError(err) || i == len(placements)-1 {
+			break
+		}
+
+		ctrl.LoggerFrom(ctx).Info("Out of capacity, trying next alternative", "metro", placement.Metro,
+			"facility", placement.Facility, "machineType", placement.MachineType)
+		r.Recorder.Eventf(scope.equinixMetalMachine, corev1.EventTypeNormal, "InsufficientCapacity",
+			"No capacity for %s in %s, trying next alternative", placement.MachineType,
+			placementLocation(placement))
+	}
+
+	return nil, nil, err
+}
+
+// placementLocation returns the facility of a placement, or its metro if it has no facility.
+func placementLocation(placement *infrav1.Placement) string {
+	if placement.Facility != "" {
+		return placement.Facility
+	}
+
+	return placement.Metro
+}
+
+func (r *EquinixMetalMachineReconciler) getBootstrapData(ctx context.Context, scope *machineScope) (string, error) {
+	secret, err := r.getBootstrapSecret(ctx, scope)
+	if err != nil {
+		return "", err
+	}
 
-	return ctrl.Result{}, ErrNotImplemented
+	return bootstrapSecretValue(secret)
+}
+
+// getBootstrapSecret returns the Secret holding the bootstrap data of the machine.
+func (r *EquinixMetalMachineReconciler) getBootstrapSecret(
+	ctx context.Context,
+	scope *machineScope,
+) (*corev1.Secret, error) {
+	secret := new(corev1.Secret)
+	key := client.ObjectKey{
+		Namespace: scope.machine.Namespace,
+		Name:      *scope.machine.Spec.Bootstrap.DataSecretName,
+	}
+
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("failed to retrieve bootstrap data secret %s: %w", key, err)
+	}
+
+	return secret, nil
+}
+
+// bootstrapSecretValue returns the bootstrap data held by a bootstrap data Secret.
+func bootstrapSecretValue(secret *corev1.Secret) (string, error) {
+	value, ok := secret.Data["value"]
+	if !ok {
+		return "", ErrMissingBootstrapData
+	}
+
+	return string(value), nil
+}
+
+// reconcileBootOptions keeps the mutable boot options of the device in sync with the spec. Devices
+// that are not installed with the custom iPXE OS, for instance after a reinstallation with another OS,
+// never boot from iPXE on every boot.
+func (r *EquinixMetalMachineReconciler) reconcileBootOptions(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) error {
+	alwaysPXE := scope.equinixMetalMachine.Spec.AlwaysPXE &&
+		deviceOS(scope.equinixMetalMachine, device) == infrav1.CustomIPXEOS
+	if device.AlwaysPXE == alwaysPXE {
+		return nil
+	}
+
+	if _, err := r.MetalClient.UpdateDevice(ctx, device.ID, &metal.DeviceUpdateRequest{ //nolint:exhaustivestruct
+		AlwaysPXE: &alwaysPXE,
+	}); err != nil {
+		return fmt.Errorf("failed to update always_pxe: %w", err)
+	}
+
+	r.invalidateDevice(scope, device)
+
+	device.AlwaysPXE = alwaysPXE
+
+	return nil
+}
+
+// reconcileTags updates the tags of the device when they differ from the tags of the machine. The
+// ownership tags of the device are replaced with the current ones, so that the tags of a previous
+// EquinixMetalMachine UID, cluster or management cluster, for instance from before a move, don't linger.
+func (r *EquinixMetalMachineReconciler) reconcileTags(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) error {
+	tags := scope.deviceTags()
+	if metal.EqualTags(tags, device.Tags) {
+		return nil
+	}
+
+	if _, err := r.MetalClient.UpdateDevice(ctx, device.ID, &metal.DeviceUpdateRequest{ //nolint:exhaustivestruct
+		Tags: &tags,
+	}); err != nil {
+		return fmt.Errorf("failed to update tags: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Updated device tags", "device", device.ID, "tags", tags)
+	r.invalidateDevice(scope, device)
+
+	device.Tags = tags
+
+	return nil
+}
+
+// ensureControlPlaneEndpoint assigns the control plane endpoint IP reserved by the cluster to the
+// device when it is not assigned to any other device yet.
+func (r *EquinixMetalMachineReconciler) ensureControlPlaneEndpoint(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) error {
+	reservation, err := r.MetalClient.GetIPReservationByTags(
+		ctx,
+		scope.projectID(),
+		metal.ClusterTag(scope.cluster.Namespace, scope.cluster.Name),
+		metal.ControlPlaneEndpointTag,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to look up control plane endpoint: %w", err)
+	}
+
+	if reservation == nil || len(reservation.Assignments) > 0 {
+		return nil
+	}
+
+	if err := r.MetalClient.AssignIP(ctx, device.ID, reservation.Address+"/32"); err != nil {
+		return fmt.Errorf("failed to assign control plane endpoint: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Assigned control plane endpoint to device", "address", reservation.Address)
+
+	return nil
+}
+
+func (r *EquinixMetalMachineReconciler) reconcileDelete(
+	ctx context.Context,
+	scope *machineScope,
+) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	device, err := r.findDevice(ctx, scope)
+
+	switch {
+	case metal.IsNotFound(err):
+		device = nil
+	case err != nil:
+		return ctrl.Result{}, err
+	}
+
+	if device != nil {
+		unlocked, err := r.unlockForDeletion(ctx, scope, device)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if !unlocked {
+			return ctrl.Result{RequeueAfter: deletionConfirmationRequeueInterval}, nil
+		}
+
+		retained, err := r.retainHardwareReservation(ctx, scope, device)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if !retained {
+			return ctrl.Result{RequeueAfter: deviceProvisioningRequeueInterval}, nil
+		}
+
+		if err := r.MetalClient.DeleteDevice(ctx, device.ID); err != nil && !metal.IsNotFound(err) {
+			r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "FailedDelete",
+				"Failed to delete device %s: %v", device.ID, err)
+
+			return ctrl.Result{}, fmt.Errorf("failed to delete device: %w", err)
+		}
+
+		r.invalidateDevice(scope, device)
+		log.Info("Deleted device", "device", device.ID)
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeNormal, "SuccessfulDelete", "Deleted device %s", device.ID)
+	}
+
+	controllerutil.RemoveFinalizer(equinixMetalMachine, infrav1.MachineFinalizer)
+
+	return ctrl.Result{}, nil
+}
+
+// invalidateDevice removes a modified device from the device cache, if any.
+func (r *EquinixMetalMachineReconciler) invalidateDevice(scope *machineScope, device *metal.Device) {
+	if r.DeviceCache != nil {
+		r.DeviceCache.Invalidate(scope.projectID(), device.ID)
+	}
+}
+
+// observeProvisioningDuration records the provisioning duration of a device the first time it is
+// seen active after having been seen queued or provisioning.
+func observeProvisioningDuration(previous *infrav1.EquinixMetalResourceStatus, device *metal.Device) {
+	if device.State != metal.DeviceStateActive || device.CreatedAt.IsZero() {
+		return
+	}
+
+	if previous != nil &&
+		*previous != infrav1.EquinixMetalResourceStatusNew &&
+		*previous != infrav1.EquinixMetalResourceStatusQueued &&
+		*previous != infrav1.EquinixMetalResourceStatusProvisioning {
+		return
+	}
+
+	var plan, metro string
+
+	if device.Plan != nil {
+		plan = device.Plan.Slug
+	}
+
+	if device.Metro != nil {
+		metro = device.Metro.Code
+	}
+
+	metrics.DeviceProvisioningDuration.WithLabelValues(plan, metro).Observe(time.Since(device.CreatedAt).Seconds())
+}
+
+// deviceStorage converts the storage layout of an EquinixMetalMachine to the Equinix Metal API format.
+func deviceStorage(layout *infrav1.StorageLayout) *metal.Storage {
+	if layout == nil {
+		return nil
+	}
+
+	storage := new(metal.Storage)
+
+	for _, disk := range layout.Disks {
+		d := metal.Disk{Device: disk.Device, WipeTable: disk.WipeTable, Partitions: nil}
+
+		for _, partition := range disk.Partitions {
+			d.Partitions = append(d.Partitions, metal.Partition{
+				Label:  partition.Label,
+				Number: partition.Number,
+				Size:   partition.Size,
+			})
+		}
+
+		storage.Disks = append(storage.Disks, d)
+	}
+
+	for _, raid := range layout.RAID {
+		storage.RAID = append(storage.RAID, metal.RAID{
+			Devices: append([]string{}, raid.Devices...),
+			Level:   raid.Level,
+			Name:    raid.Name,
+		})
+	}
+
+	for _, filesystem := range layout.Filesystems {
+		mount := metal.FilesystemMount{
+			Device: filesystem.Mount.Device,
+			Format: filesystem.Mount.Format,
+			Point:  filesystem.Mount.Point,
+			Create: nil,
+		}
+
+		if len(filesystem.Mount.Options) > 0 {
+			mount.Create = &metal.FilesystemCreate{Options: append([]string{}, filesystem.Mount.Options...)}
+		}
+
+		storage.Filesystems = append(storage.Filesystems, metal.Filesystem{Mount: mount})
+	}
+
+	return storage
+}
+
+// deviceAddresses returns the node addresses of a device.
+func deviceAddresses(device *metal.Device) []corev1.NodeAddress {
+	addresses := []corev1.NodeAddress{{Type: corev1.NodeHostName, Address: device.Hostname}}
+
+	for _, ip := range device.Network {
+		addressType := corev1.NodeInternalIP
+		if ip.Public {
+			addressType = corev1.NodeExternalIP
+		}
+
+		addresses = append(addresses, corev1.NodeAddress{Type: addressType, Address: ip.Address})
+	}
+
+	return addresses
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -85,6 +926,10 @@ func (r *EquinixMetalMachineReconciler) SetupWithManager(
 		r.Recorder = mgr.GetEventRecorderFor("equinixmetalmachine-controller")
 	}
 
+	if r.PlanCache == nil {
+		r.PlanCache = metal.NewPlanCache(r.MetalClient)
+	}
+
 	equinixMetalMachineMapper, err := util.ClusterToObjectsMapper(
 		r.Client,
 		new(infrav1.EquinixMetalMachineList),
@@ -118,6 +963,22 @@ func (r *EquinixMetalMachineReconciler) SetupWithManager(
 			builder.WithPredicates(predicates.ClusterUnpausedAndInfrastructureReady(log)),
 		)
 
+	if r.DeviceCache != nil {
+		// Requeue the machines whose device changed state in the device cache
+		ctrlBuilder = ctrlBuilder.Watches(
+			&source.Channel{Source: r.DeviceCache.Events()}, //nolint:exhaustivestruct
+			new(handler.EnqueueRequestForObject),
+		)
+	}
+
+	if r.DeviceEventSource != nil {
+		// Requeue the machines whose device had an Equinix Metal event
+		ctrlBuilder = ctrlBuilder.Watches(
+			&source.Channel{Source: r.DeviceEventSource.Events()}, //nolint:exhaustivestruct
+			new(handler.EnqueueRequestForObject),
+		)
+	}
+
 	if err := ctrlBuilder.Complete(r); err != nil {
 		return fmt.Errorf("failed to create EquinixMetalMachine controller: %w", err)
 	}
@@ -162,7 +1023,7 @@ func (r *EquinixMetalMachineReconciler) equinixMetalClusterToEquinixMetalMachine
 				continue
 			}
 
-			name := client.ObjectKey{Namespace: m.Namespace, Name: m.Name}
+			name := client.ObjectKey{Namespace: m.Namespace, Name: m.Spec.InfrastructureRef.Name}
 
 			result = append(result, ctrl.Request{NamespacedName: name})
 		}
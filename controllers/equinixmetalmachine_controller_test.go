@@ -0,0 +1,357 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+func TestPlacements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		failureDomain string
+		facility      string
+		alternatives  []infrav1.Placement
+		want          []infrav1.Placement
+	}{
+		{
+			name: "no alternatives",
+			want: []infrav1.Placement{{Metro: "da", Facility: "", MachineType: "c3.small.x86"}},
+		},
+		{
+			name:     "alternative machine type keeps the location",
+			facility: "da11",
+			alternatives: []infrav1.Placement{
+				{MachineType: "m3.small.x86"}, //nolint:exhaustivestruct
+			},
+			want: []infrav1.Placement{
+				{Metro: "da", Facility: "da11", MachineType: "c3.small.x86"},
+				{Metro: "da", Facility: "da11", MachineType: "m3.small.x86"},
+			},
+		},
+		{
+			name:     "alternative metro drops the facility",
+			facility: "da11",
+			alternatives: []infrav1.Placement{
+				{Metro: "sv"}, //nolint:exhaustivestruct
+			},
+			want: []infrav1.Placement{
+				{Metro: "da", Facility: "da11", MachineType: "c3.small.x86"},
+				{Metro: "sv", Facility: "", MachineType: "c3.small.x86"},
+			},
+		},
+		{
+			name: "alternative facility",
+			alternatives: []infrav1.Placement{
+				{Facility: "da6", MachineType: "m3.small.x86"}, //nolint:exhaustivestruct
+			},
+			want: []infrav1.Placement{
+				{Metro: "da", Facility: "", MachineType: "c3.small.x86"},
+				{Metro: "da", Facility: "da6", MachineType: "m3.small.x86"},
+			},
+		},
+		{
+			name:          "failure domain ignores alternatives in other locations",
+			failureDomain: "da11",
+			alternatives: []infrav1.Placement{
+				{Metro: "sv"},     //nolint:exhaustivestruct
+				{Facility: "da6"}, //nolint:exhaustivestruct
+				{Facility: "da11", MachineType: "m3.small.x86"}, //nolint:exhaustivestruct
+				{MachineType: "n3.xlarge.x86"},                  //nolint:exhaustivestruct
+			},
+			want: []infrav1.Placement{
+				{Metro: "da", Facility: "da11", MachineType: "c3.small.x86"},
+				{Metro: "da", Facility: "da11", MachineType: "m3.small.x86"},
+				{Metro: "da", Facility: "da11", MachineType: "n3.xlarge.x86"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scope := &machineScope{
+				cluster: &clusterv1.Cluster{}, //nolint:exhaustivestruct
+				machine: &clusterv1.Machine{}, //nolint:exhaustivestruct
+				equinixMetalCluster: &infrav1.EquinixMetalCluster{ //nolint:exhaustivestruct
+					Spec: infrav1.EquinixMetalClusterSpec{Metro: "da"}, //nolint:exhaustivestruct
+				},
+				equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+					Spec: infrav1.EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+						MachineType:  "c3.small.x86",
+						Facility:     tt.facility,
+						Alternatives: tt.alternatives,
+					},
+				},
+			}
+
+			if tt.failureDomain != "" {
+				scope.machine.Spec.FailureDomain = &tt.failureDomain
+			}
+
+			if got := scope.placements(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("placements() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileTags(t *testing.T) {
+	t.Parallel()
+
+	clusterTag := metal.ClusterTag("ns", "cluster")
+	machineTag := metal.MachineTag("uid")
+	managerTag := metal.ManagerTag("manager")
+
+	tests := []struct {
+		name       string
+		deviceTags []string
+		want       []string
+	}{
+		{
+			name:       "in sync",
+			deviceTags: []string{machineTag, "team:a", managerTag, clusterTag},
+		},
+		{
+			name:       "spec tags changed",
+			deviceTags: []string{"team:b", clusterTag, machineTag, managerTag},
+			want:       []string{"team:a", clusterTag, machineTag, managerTag},
+		},
+		{
+			name: "stale ownership tags",
+			deviceTags: []string{
+				"team:a", metal.ClusterTag("ns", "old"), metal.MachineTag("old-uid"), metal.ManagerTag("old"),
+			},
+			want: []string{"team:a", clusterTag, machineTag, managerTag},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req metal.DeviceUpdateRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Tags == nil {
+					t.Errorf("unexpected device update: %v", err)
+				} else {
+					got = *req.Tags
+				}
+
+				_, _ = w.Write([]byte("{}"))
+			}))
+			defer server.Close()
+
+			metalClient, err := metal.NewClient(server.URL, "token", server.Client())
+			if err != nil {
+				t.Fatalf("failed to create Metal client: %v", err)
+			}
+
+			r := &EquinixMetalMachineReconciler{MetalClient: metalClient} //nolint:exhaustivestruct
+			scope := &machineScope{
+				cluster: &clusterv1.Cluster{ //nolint:exhaustivestruct
+					ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"}, //nolint:exhaustivestruct
+				},
+				machine:             &clusterv1.Machine{},           //nolint:exhaustivestruct
+				equinixMetalCluster: &infrav1.EquinixMetalCluster{}, //nolint:exhaustivestruct
+				equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+					ObjectMeta: metav1.ObjectMeta{UID: "uid"},                             //nolint:exhaustivestruct
+					Spec:       infrav1.EquinixMetalMachineSpec{Tags: []string{"team:a"}}, //nolint:exhaustivestruct
+				},
+				managerID: "manager",
+			}
+			device := &metal.Device{ID: "device", Tags: tt.deviceTags} //nolint:exhaustivestruct
+
+			if err := r.reconcileTags(context.Background(), scope, device); err != nil {
+				t.Fatalf("reconcileTags() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reconcileTags() updated tags to %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileNormalDeviceGone(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		responses  map[string]interface{}
+		wantReason string
+	}{
+		{
+			name:       "deleted",
+			responses:  map[string]interface{}{},
+			wantReason: infrav1.InstanceNotFoundReason,
+		},
+		{
+			name: "deprovisioning",
+			responses: map[string]interface{}{
+				"/devices/device": map[string]interface{}{"id": "device", "state": metal.DeviceStateDeprovisioning},
+			},
+			wantReason: infrav1.InstanceTerminatedReason,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			providerID := metal.ProviderIDPrefix + "device"
+			dataSecretName := "bootstrap"
+			r := &EquinixMetalMachineReconciler{ //nolint:exhaustivestruct
+				MetalClient: newTestMetalClient(t, tt.responses),
+				Recorder:    record.NewFakeRecorder(10),
+			}
+			scope := &machineScope{ //nolint:exhaustivestruct
+				cluster: &clusterv1.Cluster{ //nolint:exhaustivestruct
+					Status: clusterv1.ClusterStatus{InfrastructureReady: true}, //nolint:exhaustivestruct
+				},
+				machine: &clusterv1.Machine{ //nolint:exhaustivestruct
+					Spec: clusterv1.MachineSpec{ //nolint:exhaustivestruct
+						Bootstrap: clusterv1.Bootstrap{DataSecretName: &dataSecretName}, //nolint:exhaustivestruct
+					},
+				},
+				equinixMetalCluster: &infrav1.EquinixMetalCluster{}, //nolint:exhaustivestruct
+				equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+					Spec:   infrav1.EquinixMetalMachineSpec{ProviderID: &providerID}, //nolint:exhaustivestruct
+					Status: infrav1.EquinixMetalMachineStatus{Ready: true},           //nolint:exhaustivestruct
+				},
+			}
+
+			if _, err := r.reconcileNormal(context.Background(), scope); err != nil {
+				t.Fatalf("reconcileNormal() error = %v", err)
+			}
+
+			status := scope.equinixMetalMachine.Status
+			if status.Ready {
+				t.Errorf("reconcileNormal() left the machine ready")
+			}
+
+			if status.FailureReason == nil || *status.FailureReason != capierrors.UpdateMachineError {
+				t.Errorf("reconcileNormal() failure reason = %v, want %s", status.FailureReason, capierrors.UpdateMachineError)
+			}
+
+			if status.FailureMessage == nil {
+				t.Errorf("reconcileNormal() did not set a failure message")
+			}
+
+			condition := conditions.Get(scope.equinixMetalMachine, infrav1.DeviceReadyCondition)
+			if condition == nil || condition.Reason != tt.wantReason ||
+				condition.Severity != clusterv1.ConditionSeverityError {
+				t.Errorf("reconcileNormal() condition = %+v, want reason %s with severity Error", condition, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestMarkDeviceNotReady(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		state        string
+		wantReady    bool
+		wantReason   string
+		wantSeverity clusterv1.ConditionSeverity
+	}{
+		{
+			state:        metal.DeviceStateInactive,
+			wantReason:   infrav1.InstanceStoppedReason,
+			wantSeverity: clusterv1.ConditionSeverityWarning,
+		},
+		{
+			state:        metal.DeviceStateQueued,
+			wantReady:    true,
+			wantReason:   infrav1.InstanceNotReadyReason,
+			wantSeverity: clusterv1.ConditionSeverityInfo,
+		},
+		{
+			state:        metal.DeviceStateProvisioning,
+			wantReady:    true,
+			wantReason:   infrav1.InstanceNotReadyReason,
+			wantSeverity: clusterv1.ConditionSeverityInfo,
+		},
+		{
+			state:        metal.DeviceStateReinstalling,
+			wantReady:    true,
+			wantReason:   infrav1.InstanceNotReadyReason,
+			wantSeverity: clusterv1.ConditionSeverityInfo,
+		},
+		{
+			state:        "powering_off",
+			wantReason:   infrav1.InstanceNotReadyReason,
+			wantSeverity: clusterv1.ConditionSeverityWarning,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.state, func(t *testing.T) {
+			t.Parallel()
+
+			equinixMetalMachine := &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+				Status: infrav1.EquinixMetalMachineStatus{Ready: true}, //nolint:exhaustivestruct
+			}
+
+			markDeviceNotReady(context.Background(), equinixMetalMachine, tt.state)
+
+			if equinixMetalMachine.Status.Ready != tt.wantReady {
+				t.Errorf("markDeviceNotReady() ready = %t, want %t", equinixMetalMachine.Status.Ready, tt.wantReady)
+			}
+
+			if equinixMetalMachine.Status.FailureReason != nil {
+				t.Errorf("markDeviceNotReady() set failure reason %s", *equinixMetalMachine.Status.FailureReason)
+			}
+
+			condition := conditions.Get(equinixMetalMachine, infrav1.DeviceReadyCondition)
+			if condition == nil || condition.Status != corev1.ConditionFalse || condition.Reason != tt.wantReason ||
+				condition.Severity != tt.wantSeverity {
+				t.Errorf("markDeviceNotReady() condition = %+v, want reason %s with severity %s",
+					condition, tt.wantReason, tt.wantSeverity)
+			}
+		})
+	}
+}
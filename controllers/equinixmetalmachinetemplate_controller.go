@@ -0,0 +1,300 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// planRefreshInterval is how often the capacity and node info of the templates are refreshed from the
+	// Equinix Metal plan catalog.
+	planRefreshInterval = time.Hour
+
+	// gpuResourceName is the extended resource advertised by the NVIDIA device plugin for the GPUs of a node.
+	gpuResourceName corev1.ResourceName = "nvidia.com/gpu"
+
+	// nodeOperatingSystem is the operating system of the nodes of all the machines.
+	nodeOperatingSystem = "linux"
+)
+
+// EquinixMetalMachineTemplateReconciler reconciles a EquinixMetalMachineTemplate object.
+type EquinixMetalMachineTemplateReconciler struct {
+	client.Client
+	MetalClient      *metal.Client
+	WatchFilterValue string
+
+	// CapacityCheckInterval is how often the capacity for the templates is checked. Capacity
+	// checks are disabled when it is 0.
+	CapacityCheckInterval time.Duration
+}
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalmachinetemplates,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalmachinetemplates/status,verbs=get;update;patch
+
+// Reconcile reports the status of an EquinixMetalMachineTemplate: the resources of its machines, and
+// whether there is capacity for them.
+func (r *EquinixMetalMachineTemplateReconciler) Reconcile(
+	ctx context.Context,
+	req ctrl.Request,
+) (_ ctrl.Result, reterr error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	template := new(infrav1.EquinixMetalMachineTemplate)
+	if err := r.Client.Get(ctx, req.NamespacedName, template); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("failed to get EquinixMetalMachineTemplate: %w", err)
+	}
+
+	if annotations.HasPausedAnnotation(template) {
+		log.Info("EquinixMetalMachineTemplate is marked as paused. Won't reconcile")
+
+		return ctrl.Result{}, nil
+	}
+
+	if !template.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(template, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to init patch helper: %w", err)
+	}
+
+	defer func() {
+		if err := patchHelper.Patch(
+			ctx,
+			template,
+			patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+				infrav1.CapacityAvailableCondition,
+			}},
+		); err != nil && reterr == nil {
+			reterr = fmt.Errorf("failed to patch EquinixMetalMachineTemplate: %w", err)
+		}
+	}()
+
+	// The node info and the capacity are independent, so that failing to reconcile one does not hold
+	// the other back.
+	nodeInfoResult, nodeInfoErr := r.reconcileNodeInfo(ctx, template)
+	nodeInfoResult, nodeInfoErr = requeueOnMetalAPIUnavailable(ctx, nodeInfoResult, nodeInfoErr)
+
+	capacityResult, capacityErr := r.reconcileCapacity(ctx, template)
+	capacityResult, capacityErr = requeueOnMetalAPIUnavailable(ctx, capacityResult, capacityErr)
+
+	result := util.LowestNonZeroResult(nodeInfoResult, capacityResult)
+
+	return result, kerrors.NewAggregate([]error{nodeInfoErr, capacityErr})
+}
+
+// reconcileNodeInfo records the resources and node info of the machines of the template, as described
+// by the Equinix Metal plan catalog for its machine type.
+func (r *EquinixMetalMachineTemplateReconciler) reconcileNodeInfo(
+	ctx context.Context,
+	template *infrav1.EquinixMetalMachineTemplate,
+) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	machineType := template.Spec.Template.Spec.MachineType
+
+	plan, err := r.MetalClient.GetPlan(ctx, machineType)
+	if err != nil {
+		return ctrl.Result{}, err //nolint:wrapcheck
+	}
+
+	if plan == nil {
+		log.Info("Machine type not found in the Equinix Metal plan catalog", "machineType", machineType)
+
+		template.Status.Capacity = nil
+		template.Status.NodeInfo = nil
+
+		return ctrl.Result{RequeueAfter: planRefreshInterval}, nil
+	}
+
+	capacity := corev1.ResourceList{}
+
+	if cores := plan.CPUCores(); cores > 0 {
+		capacity[corev1.ResourceCPU] = *resource.NewQuantity(int64(cores), resource.DecimalSI)
+	}
+
+	if memory := plan.MemoryBytes(); memory > 0 {
+		capacity[corev1.ResourceMemory] = *resource.NewQuantity(memory, resource.BinarySI)
+	}
+
+	if gpus := plan.GPUs(); gpus > 0 {
+		capacity[gpuResourceName] = *resource.NewQuantity(int64(gpus), resource.DecimalSI)
+	}
+
+	template.Status.Capacity = capacity
+	template.Status.NodeInfo = &infrav1.NodeInfo{
+		Architecture:    infrav1.Architecture(plan.Architecture()),
+		OperatingSystem: nodeOperatingSystem,
+	}
+
+	return ctrl.Result{RequeueAfter: planRefreshInterval}, nil
+}
+
+// reconcileCapacity checks whether Equinix Metal has capacity for the machines of the template.
+func (r *EquinixMetalMachineTemplateReconciler) reconcileCapacity(
+	ctx context.Context,
+	template *infrav1.EquinixMetalMachineTemplate,
+) (ctrl.Result, error) {
+	if r.CapacityCheckInterval <= 0 {
+		conditions.Delete(template, infrav1.CapacityAvailableCondition)
+
+		return ctrl.Result{}, nil
+	}
+
+	spec := template.Spec.Template.Spec
+	req := metal.ServerCapacity{Plan: spec.MachineType, Quantity: 1} //nolint:exhaustivestruct
+
+	if value, ok := template.Annotations[infrav1.CapacityCheckQuantityAnnotation]; ok {
+		quantity, err := strconv.Atoi(value)
+		if err != nil || quantity < 1 {
+			conditions.MarkFalse(template, infrav1.CapacityAvailableCondition, infrav1.CapacityCheckFailedReason,
+				clusterv1.ConditionSeverityWarning, "invalid %s annotation %q", infrav1.CapacityCheckQuantityAnnotation, value)
+
+			return ctrl.Result{}, nil
+		}
+
+		req.Quantity = quantity
+	}
+
+	metro, facility, err := r.location(ctx, template)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case facility != "":
+		req.Facility = facility
+	case metro != "":
+		req.Metro = metro
+	default:
+		conditions.MarkFalse(template, infrav1.CapacityAvailableCondition, infrav1.CapacityCheckFailedReason,
+			clusterv1.ConditionSeverityWarning, "the template has no metro or facility, and no EquinixMetalCluster")
+
+		return ctrl.Result{}, nil
+	}
+
+	available, err := r.MetalClient.CheckCapacity(ctx, req)
+	if err != nil {
+		conditions.MarkFalse(template, infrav1.CapacityAvailableCondition, infrav1.CapacityCheckFailedReason,
+			clusterv1.ConditionSeverityWarning, err.Error())
+
+		return ctrl.Result{}, err //nolint:wrapcheck
+	}
+
+	if available {
+		conditions.MarkTrue(template, infrav1.CapacityAvailableCondition)
+	} else {
+		location := placementLocation(&infrav1.Placement{Metro: req.Metro, Facility: req.Facility}) //nolint:exhaustivestruct
+		conditions.MarkFalse(template, infrav1.CapacityAvailableCondition, infrav1.InsufficientCapacityReason,
+			clusterv1.ConditionSeverityWarning, "no capacity for %d %s servers in %s", req.Quantity, req.Plan, location)
+	}
+
+	return ctrl.Result{RequeueAfter: r.CapacityCheckInterval}, nil
+}
+
+// location returns the metro and facility of the machines of the template, defaulting to the ones of the
+// EquinixMetalCluster of the cluster the template belongs to.
+func (r *EquinixMetalMachineTemplateReconciler) location(
+	ctx context.Context,
+	template *infrav1.EquinixMetalMachineTemplate,
+) (string, string, error) {
+	spec := template.Spec.Template.Spec
+	if spec.Metro != "" || spec.Facility != "" {
+		return spec.Metro, spec.Facility, nil
+	}
+
+	clusterName, ok := template.Labels[clusterv1.ClusterLabelName]
+	if !ok {
+		return "", "", nil
+	}
+
+	cluster := new(clusterv1.Cluster)
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: template.Namespace, Name: clusterName}, cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", "", nil
+		}
+
+		return "", "", fmt.Errorf("failed to get Cluster: %w", err)
+	}
+
+	if cluster.Spec.InfrastructureRef == nil {
+		return "", "", nil
+	}
+
+	equinixMetalCluster := new(infrav1.EquinixMetalCluster)
+	key := client.ObjectKey{Namespace: template.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+
+	if err := r.Client.Get(ctx, key, equinixMetalCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", "", nil
+		}
+
+		return "", "", fmt.Errorf("failed to get EquinixMetalCluster: %w", err)
+	}
+
+	return equinixMetalCluster.Spec.Metro, equinixMetalCluster.Spec.Facility, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *EquinixMetalMachineTemplateReconciler) SetupWithManager(
+	ctx context.Context,
+	mgr ctrl.Manager,
+	options controller.Options,
+) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	if r.Client == nil {
+		r.Client = mgr.GetClient()
+	}
+
+	ctrlBuilder := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(new(infrav1.EquinixMetalMachineTemplate)).
+		// Filter out any paused or filtered resources
+		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(log, r.WatchFilterValue))
+
+	if err := ctrlBuilder.Complete(r); err != nil {
+		return fmt.Errorf("failed to create EquinixMetalMachineTemplate controller: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// capacityCheck is a capacity check received by a test Metal API.
+type capacityCheck struct {
+	path   string
+	server metal.ServerCapacity
+}
+
+// newCapacityTestMetalClient returns a Metal client whose capacity checks are recorded and answered with
+// the given availability, or with an error if status is not 200.
+func newCapacityTestMetalClient(t *testing.T, status int, available bool) (*metal.Client, *[]capacityCheck) {
+	t.Helper()
+
+	checks := new([]capacityCheck)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Servers []metal.ServerCapacity `json:"servers"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Servers) != 1 {
+			t.Errorf("unexpected capacity check: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		*checks = append(*checks, capacityCheck{path: r.URL.Path, server: req.Servers[0]})
+
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+
+			return
+		}
+
+		req.Servers[0].Available = available
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(req)
+	}))
+	t.Cleanup(server.Close)
+
+	metalClient, err := metal.NewClient(server.URL, "token", server.Client())
+	if err != nil {
+		t.Fatalf("failed to create Metal client: %v", err)
+	}
+
+	return metalClient, checks
+}
+
+func TestReconcileCapacity(t *testing.T) {
+	t.Parallel()
+
+	cluster := &clusterv1.Cluster{ //nolint:exhaustivestruct
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"}, //nolint:exhaustivestruct
+		Spec: clusterv1.ClusterSpec{ //nolint:exhaustivestruct
+			InfrastructureRef: &corev1.ObjectReference{Name: "cluster"}, //nolint:exhaustivestruct
+		},
+	}
+	equinixMetalCluster := &infrav1.EquinixMetalCluster{ //nolint:exhaustivestruct
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"}, //nolint:exhaustivestruct
+		Spec:       infrav1.EquinixMetalClusterSpec{Metro: "am"},        //nolint:exhaustivestruct
+	}
+
+	tests := []struct {
+		name        string
+		interval    time.Duration
+		labels      map[string]string
+		annotations map[string]string
+		spec        infrav1.EquinixMetalMachineSpec
+		status      int
+		available   bool
+		wantChecks  []capacityCheck
+		wantStatus  corev1.ConditionStatus
+		wantReason  string
+		wantErr     bool
+	}{
+		{
+			name:     "disabled",
+			interval: 0,
+			spec:     infrav1.EquinixMetalMachineSpec{MachineType: "m3.small.x86", Metro: "da"}, //nolint:exhaustivestruct
+		},
+		{
+			name:      "available in a metro",
+			interval:  time.Hour,
+			spec:      infrav1.EquinixMetalMachineSpec{MachineType: "m3.small.x86", Metro: "da"}, //nolint:exhaustivestruct
+			available: true,
+			wantChecks: []capacityCheck{
+				{path: "/capacity/metros", server: metal.ServerCapacity{Metro: "da", Plan: "m3.small.x86", Quantity: 1}},
+			},
+			wantStatus: corev1.ConditionTrue,
+		},
+		{
+			name:     "unavailable in a facility",
+			interval: time.Hour,
+			spec:     infrav1.EquinixMetalMachineSpec{MachineType: "m3.small.x86", Facility: "da11"}, //nolint:exhaustivestruct
+			wantChecks: []capacityCheck{
+				{path: "/capacity", server: metal.ServerCapacity{Facility: "da11", Plan: "m3.small.x86", Quantity: 1}},
+			},
+			wantStatus: corev1.ConditionFalse,
+			wantReason: infrav1.InsufficientCapacityReason,
+		},
+		{
+			name:        "quantity",
+			interval:    time.Hour,
+			annotations: map[string]string{infrav1.CapacityCheckQuantityAnnotation: "3"},
+			spec:        infrav1.EquinixMetalMachineSpec{MachineType: "m3.small.x86", Metro: "da"}, //nolint:exhaustivestruct
+			available:   true,
+			wantChecks: []capacityCheck{
+				{path: "/capacity/metros", server: metal.ServerCapacity{Metro: "da", Plan: "m3.small.x86", Quantity: 3}},
+			},
+			wantStatus: corev1.ConditionTrue,
+		},
+		{
+			name:        "invalid quantity",
+			interval:    time.Hour,
+			annotations: map[string]string{infrav1.CapacityCheckQuantityAnnotation: "0"},
+			spec:        infrav1.EquinixMetalMachineSpec{MachineType: "m3.small.x86", Metro: "da"}, //nolint:exhaustivestruct
+			wantStatus:  corev1.ConditionFalse,
+			wantReason:  infrav1.CapacityCheckFailedReason,
+		},
+		{
+			name:      "location of the cluster",
+			interval:  time.Hour,
+			labels:    map[string]string{clusterv1.ClusterLabelName: "cluster"},
+			spec:      infrav1.EquinixMetalMachineSpec{MachineType: "m3.small.x86"}, //nolint:exhaustivestruct
+			available: true,
+			wantChecks: []capacityCheck{
+				{path: "/capacity/metros", server: metal.ServerCapacity{Metro: "am", Plan: "m3.small.x86", Quantity: 1}},
+			},
+			wantStatus: corev1.ConditionTrue,
+		},
+		{
+			name:       "no location",
+			interval:   time.Hour,
+			spec:       infrav1.EquinixMetalMachineSpec{MachineType: "m3.small.x86"}, //nolint:exhaustivestruct
+			wantStatus: corev1.ConditionFalse,
+			wantReason: infrav1.CapacityCheckFailedReason,
+		},
+		{
+			name:     "check failed",
+			interval: time.Hour,
+			spec:     infrav1.EquinixMetalMachineSpec{MachineType: "m3.small.x86", Metro: "da"}, //nolint:exhaustivestruct
+			status:   http.StatusInternalServerError,
+			wantChecks: []capacityCheck{
+				{path: "/capacity/metros", server: metal.ServerCapacity{Metro: "da", Plan: "m3.small.x86", Quantity: 1}},
+			},
+			wantStatus: corev1.ConditionFalse,
+			wantReason: infrav1.CapacityCheckFailedReason,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			status := tt.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			metalClient, checks := newCapacityTestMetalClient(t, status, tt.available)
+			r := &EquinixMetalMachineTemplateReconciler{ //nolint:exhaustivestruct
+				Client:                newTestClient(t, cluster.DeepCopy(), equinixMetalCluster.DeepCopy()),
+				MetalClient:           metalClient,
+				CapacityCheckInterval: tt.interval,
+			}
+			template := &infrav1.EquinixMetalMachineTemplate{ //nolint:exhaustivestruct
+				ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustivestruct
+					Namespace:   "ns",
+					Name:        "template",
+					Labels:      tt.labels,
+					Annotations: tt.annotations,
+				},
+				Spec: infrav1.EquinixMetalMachineTemplateSpec{
+					Template: infrav1.EquinixMetalMachineTemplateResource{Spec: tt.spec},
+				},
+			}
+			// A condition left from a previous check.
+			conditions.MarkTrue(template, infrav1.CapacityAvailableCondition)
+
+			_, err := r.reconcileCapacity(context.Background(), template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileCapacity() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(*checks, tt.wantChecks) {
+				t.Errorf("reconcileCapacity() checked %+v, want %+v", *checks, tt.wantChecks)
+			}
+
+			condition := conditions.Get(template, infrav1.CapacityAvailableCondition)
+
+			switch {
+			case tt.wantStatus == "":
+				if condition != nil {
+					t.Errorf("reconcileCapacity() condition = %+v, want none", condition)
+				}
+			case condition == nil || condition.Status != tt.wantStatus || condition.Reason != tt.wantReason:
+				t.Errorf("reconcileCapacity() condition = %+v, want status %s with reason %q",
+					condition, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
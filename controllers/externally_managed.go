@@ -0,0 +1,109 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// externallyManagedRequeueInterval is how often an externally managed cluster whose infrastructure is
+// not valid is checked again.
+const externallyManagedRequeueInterval = time.Minute
+
+// ErrInvalidControlPlaneEndpoint is returned when the control plane endpoint of an externally managed
+// cluster is missing or invalid.
+var ErrInvalidControlPlaneEndpoint = errors.New("invalid control plane endpoint")
+
+// reconcileExternallyManaged checks the infrastructure provided for an externally managed cluster and
+// marks the cluster ready once it is valid. No resource is created, so no finalizer is needed. A project
+// that does not exist or cannot be accessed with the API key is reported on the ProjectReady condition,
+// other errors are retried.
+func (r *EquinixMetalClusterReconciler) reconcileExternallyManaged(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	_, err := r.MetalClient.GetProject(ctx, equinixMetalCluster.Spec.ProjectID)
+	if err != nil && !metal.IsNotFound(err) && !metal.IsAccessDenied(err) {
+		return ctrl.Result{}, fmt.Errorf("failed to get project of externally managed cluster: %w", err)
+	}
+
+	if err != nil {
+		log.Error(err, "Project of externally managed cluster is not accessible")
+		conditions.MarkFalse(equinixMetalCluster, infrav1.ProjectReadyCondition,
+			infrav1.ProjectAccessFailedReason, clusterv1.ConditionSeverityError, err.Error())
+		equinixMetalCluster.Status.Ready = false
+
+		return ctrl.Result{RequeueAfter: externallyManagedRequeueInterval}, nil
+	}
+
+	conditions.MarkTrue(equinixMetalCluster, infrav1.ProjectReadyCondition)
+
+	if err := validateControlPlaneEndpoint(equinixMetalCluster.Spec.ControlPlaneEndpoint); err != nil {
+		log.Info("Control plane endpoint of externally managed cluster is invalid", "reason", err.Error())
+		conditions.MarkFalse(equinixMetalCluster, infrav1.NetworkInfrastructureReadyCondition,
+			infrav1.ControlPlaneEndpointInvalidReason, clusterv1.ConditionSeverityError, err.Error())
+		equinixMetalCluster.Status.Ready = false
+
+		return ctrl.Result{RequeueAfter: externallyManagedRequeueInterval}, nil
+	}
+
+	if err := r.reconcileCost(ctx, cluster, equinixMetalCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileRetainedHardwareReservations(ctx, cluster, equinixMetalCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	conditions.MarkTrue(equinixMetalCluster, infrav1.NetworkInfrastructureReadyCondition)
+	equinixMetalCluster.Status.Ready = true
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileExternallyManagedDelete lets an externally managed cluster be deleted without releasing any
+// resource. The finalizer is only present when the cluster was managed by the controller before.
+func reconcileExternallyManagedDelete(equinixMetalCluster *infrav1.EquinixMetalCluster) {
+	controllerutil.RemoveFinalizer(equinixMetalCluster, infrav1.ClusterFinalizer)
+}
+
+// validateControlPlaneEndpoint checks the control plane endpoint provided for an externally managed cluster.
+func validateControlPlaneEndpoint(endpoint clusterv1.APIEndpoint) error {
+	if endpoint.Host == "" {
+		return fmt.Errorf("%w: spec.controlPlaneEndpoint.host is not set", ErrInvalidControlPlaneEndpoint)
+	}
+
+	if endpoint.Port < 1 || endpoint.Port > infrav1.MaxPort {
+		return fmt.Errorf("%w: port %d is out of range", ErrInvalidControlPlaneEndpoint, endpoint.Port)
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+func TestReconcileExternallyManaged(t *testing.T) {
+	t.Parallel()
+
+	endpoint := clusterv1.APIEndpoint{Host: "192.0.2.1", Port: 6443}
+
+	tests := []struct {
+		name          string
+		projectStatus int
+		endpoint      clusterv1.APIEndpoint
+		wantErr       bool
+		wantCondition clusterv1.ConditionType
+		wantReason    string
+	}{
+		{
+			name:          "project not found",
+			projectStatus: http.StatusNotFound,
+			endpoint:      endpoint,
+			wantCondition: infrav1.ProjectReadyCondition,
+			wantReason:    infrav1.ProjectAccessFailedReason,
+		},
+		{
+			name:          "project not accessible",
+			projectStatus: http.StatusForbidden,
+			endpoint:      endpoint,
+			wantCondition: infrav1.ProjectReadyCondition,
+			wantReason:    infrav1.ProjectAccessFailedReason,
+		},
+		{
+			name:          "project lookup failed",
+			projectStatus: http.StatusBadRequest,
+			endpoint:      endpoint,
+			wantErr:       true,
+		},
+		{
+			name:          "missing endpoint",
+			projectStatus: http.StatusOK,
+			wantCondition: infrav1.NetworkInfrastructureReadyCondition,
+			wantReason:    infrav1.ControlPlaneEndpointInvalidReason,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/projects/project" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.projectStatus)
+				_, _ = w.Write([]byte(`{"id":"project"}`))
+			}))
+			defer server.Close()
+
+			metalClient, err := metal.NewClient(server.URL, "token", server.Client())
+			if err != nil {
+				t.Fatalf("failed to create Metal client: %v", err)
+			}
+
+			r := &EquinixMetalClusterReconciler{MetalClient: metalClient} //nolint:exhaustivestruct
+			equinixMetalCluster := &infrav1.EquinixMetalCluster{          //nolint:exhaustivestruct
+				Spec: infrav1.EquinixMetalClusterSpec{ //nolint:exhaustivestruct
+					ProjectID:            "project",
+					ControlPlaneEndpoint: tt.endpoint,
+				},
+				Status: infrav1.EquinixMetalClusterStatus{Ready: true}, //nolint:exhaustivestruct
+			}
+
+			cluster := &clusterv1.Cluster{} //nolint:exhaustivestruct
+
+			_, err = r.reconcileExternallyManaged(context.Background(), cluster, equinixMetalCluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileExternallyManaged() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if equinixMetalCluster.Status.Ready {
+				t.Errorf("reconcileExternallyManaged() marked the cluster ready")
+			}
+
+			condition := conditions.Get(equinixMetalCluster, tt.wantCondition)
+			if condition == nil || condition.Status != corev1.ConditionFalse || condition.Reason != tt.wantReason {
+				t.Errorf("reconcileExternallyManaged() %s condition = %+v, want reason %s",
+					tt.wantCondition, condition, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestValidateControlPlaneEndpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		endpoint clusterv1.APIEndpoint
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			endpoint: clusterv1.APIEndpoint{Host: "192.0.2.1", Port: 6443},
+		},
+		{
+			name:     "missing host",
+			endpoint: clusterv1.APIEndpoint{Port: 6443}, //nolint:exhaustivestruct
+			wantErr:  true,
+		},
+		{
+			name:     "missing port",
+			endpoint: clusterv1.APIEndpoint{Host: "192.0.2.1"}, //nolint:exhaustivestruct
+			wantErr:  true,
+		},
+		{
+			name:     "port out of range",
+			endpoint: clusterv1.APIEndpoint{Host: "192.0.2.1", Port: infrav1.MaxPort + 1},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateControlPlaneEndpoint(tt.endpoint)
+			if got := errors.Is(err, ErrInvalidControlPlaneEndpoint); got != tt.wantErr {
+				t.Errorf("validateControlPlaneEndpoint() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
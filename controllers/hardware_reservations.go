@@ -0,0 +1,374 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// controlPlaneHardwareReservationPool is the pool of the hardware reservations retained for control plane machines.
+const controlPlaneHardwareReservationPool = "control-plane"
+
+// hardwareReservationPool returns the pool of the hardware reservations retained for the machine, so that
+// the reservations of the machines of a MachineDeployment or control plane are reused by their replacements.
+func (s *machineScope) hardwareReservationPool() string {
+	if name, ok := s.machine.Labels[clusterv1.MachineDeploymentLabelName]; ok {
+		return name
+	}
+
+	if util.IsControlPlaneMachine(s.machine) {
+		return controlPlaneHardwareReservationPool
+	}
+
+	return ""
+}
+
+// hardwareReservationRetentionTags returns the tags retaining a hardware reservation for the pool of the machine.
+func (s *machineScope) hardwareReservationRetentionTags() []string {
+	return []string{
+		metal.ClusterTag(s.cluster.Namespace, s.cluster.Name),
+		metal.HardwareReservationPoolTag(s.hardwareReservationPool()),
+	}
+}
+
+// retainedHardwareReservation returns a provisionable hardware reservation of the project retained for the
+// pool and machine type of the machine, or nil if there is none. Only machines letting the Equinix Metal API
+// pick their hardware reservation reuse retained ones.
+func (r *EquinixMetalMachineReconciler) retainedHardwareReservation(
+	ctx context.Context,
+	scope *machineScope,
+) (*metal.HardwareReservation, error) {
+	if scope.equinixMetalMachine.Spec.HardwareReservationID != metal.NextAvailableHardwareReservation {
+		return nil, nil //nolint:nilnil
+	}
+
+	reservations, err := r.MetalClient.ListHardwareReservations(ctx, scope.projectID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up retained hardware reservations: %w", err)
+	}
+
+	tags := scope.hardwareReservationRetentionTags()
+
+	for i := range reservations {
+		reservation := &reservations[i]
+		if reservation.Provisionable && reservation.Plan != nil &&
+			reservation.Plan.Slug == scope.equinixMetalMachine.Spec.MachineType &&
+			metal.HasTags(reservation.Tags, tags...) {
+			return reservation, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil
+}
+
+// reconcileHardwareReservation records the hardware reservation of the device of the machine, and releases
+// it from retention once it is reused.
+func (r *EquinixMetalMachineReconciler) reconcileHardwareReservation(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) error {
+	if device.HardwareReservation == nil || device.HardwareReservation.ID == "" {
+		return nil
+	}
+
+	id := device.HardwareReservation.ID
+	if scope.equinixMetalMachine.Status.HardwareReservationID == id {
+		return nil
+	}
+
+	reservation, err := r.MetalClient.GetHardwareReservation(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to look up hardware reservation of device: %w", err)
+	}
+
+	if _, retained := metal.HardwareReservationPoolFromTags(reservation.Tags); retained {
+		if err := r.releaseHardwareReservation(ctx, reservation); err != nil {
+			return err
+		}
+
+		ctrl.LoggerFrom(ctx).Info("Reused retained hardware reservation", "hardwareReservation", id)
+	}
+
+	scope.equinixMetalMachine.Status.HardwareReservationID = id
+
+	return nil
+}
+
+// releaseHardwareReservation removes the tags retaining a hardware reservation.
+func (r *EquinixMetalMachineReconciler) releaseHardwareReservation(
+	ctx context.Context,
+	reservation *metal.HardwareReservation,
+) error {
+	tags := metal.ReplaceOwnershipTags(reservation.Tags, nil)
+	if err := r.MetalClient.UpdateHardwareReservationTags(ctx, reservation.ID, tags); err != nil {
+		return fmt.Errorf("failed to release retained hardware reservation: %w", err)
+	}
+
+	reservation.Tags = tags
+
+	return nil
+}
+
+// pruneRetainedHardwareReservation releases a retained hardware reservation the device could not be created
+// with, since it has likely been claimed by another device of the project. Errors of the Equinix Metal API
+// that may be transient do not release it.
+func (r *EquinixMetalMachineReconciler) pruneRetainedHardwareReservation(
+	ctx context.Context,
+	scope *machineScope,
+	reservation *metal.HardwareReservation,
+	createErr error,
+) error {
+	if reservation == nil {
+		return nil
+	}
+
+	var apiErr *metal.APIError
+	if _, retry := metal.RetryAfter(createErr); retry || !errors.As(createErr, &apiErr) {
+		return nil
+	}
+
+	if err := r.releaseHardwareReservation(ctx, reservation); err != nil {
+		return err
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Released retained hardware reservation the device could not be created with",
+		"hardwareReservation", reservation.ID, "error", createErr.Error())
+	r.Recorder.Eventf(scope.equinixMetalMachine, corev1.EventTypeWarning, "FailedReuseHardwareReservation",
+		"Failed to reuse retained hardware reservation %s: %v", reservation.ID, createErr)
+
+	return nil
+}
+
+// retainHardwareReservation applies the HardwareReservationDeletePolicy of a deleted machine before its
+// device is deleted, by tagging its hardware reservation for the pool of the machine. It returns false
+// while the disks of the device are being wiped.
+func (r *EquinixMetalMachineReconciler) retainHardwareReservation(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) (bool, error) {
+	if device.HardwareReservation == nil || device.HardwareReservation.ID == "" {
+		return true, nil
+	}
+
+	switch scope.equinixMetalMachine.Spec.HardwareReservationDeletePolicy {
+	case infrav1.HardwareReservationDeletePolicyKeepAndDeprovision:
+		wiped, err := r.wipeDevice(ctx, scope, device)
+		if err != nil || !wiped {
+			return false, err
+		}
+	case infrav1.HardwareReservationDeletePolicyKeep:
+	default:
+		return true, nil
+	}
+
+	reservation, err := r.MetalClient.GetHardwareReservation(ctx, device.HardwareReservation.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up hardware reservation of device: %w", err)
+	}
+
+	tags := metal.ReplaceOwnershipTags(reservation.Tags, scope.hardwareReservationRetentionTags())
+	if metal.EqualTags(tags, reservation.Tags) {
+		return true, nil
+	}
+
+	if err := r.MetalClient.UpdateHardwareReservationTags(ctx, reservation.ID, tags); err != nil {
+		return false, fmt.Errorf("failed to retain hardware reservation: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Retained hardware reservation", "hardwareReservation", reservation.ID)
+	r.Recorder.Eventf(scope.equinixMetalMachine, corev1.EventTypeNormal, "SuccessfulRetainHardwareReservation",
+		"Retained hardware reservation %s", reservation.ID)
+
+	return true, nil
+}
+
+// wipeDevice reinstalls the device without preserving any data and without its userdata, so that the
+// next device using its hardware reservation does not find the data of the machine. The wipe is tracked
+// on the status of the machine, and is done once the device has been seen reinstalling and is active again.
+// It returns true once the wipe is done.
+func (r *EquinixMetalMachineReconciler) wipeDevice(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	if wipe := equinixMetalMachine.Status.DeviceWipe; wipe != nil {
+		switch {
+		case device.State == metal.DeviceStateReinstalling:
+			wipe.Reinstalling = true
+		case wipe.Reinstalling && device.State == metal.DeviceStateActive:
+			log.Info("Wiped device", "device", device.ID)
+
+			return true, nil
+		default:
+			log.Info("Waiting for the device to be wiped", "device", device.ID, "state", device.State)
+		}
+
+		return false, nil
+	}
+
+	if device.State == metal.DeviceStateReinstalling {
+		return false, nil
+	}
+
+	if device.State != metal.DeviceStateActive && device.State != metal.DeviceStateInactive {
+		// Devices that never finished provisioning hold no data of the machine.
+		return true, nil
+	}
+
+	userData := ""
+	update := &metal.DeviceUpdateRequest{UserData: &userData} //nolint:exhaustivestruct
+
+	if _, err := r.MetalClient.UpdateDevice(ctx, device.ID, update); err != nil {
+		return false, fmt.Errorf("failed to clear userdata before wiping device: %w", err)
+	}
+
+	action := &metal.DeviceActionRequest{ //nolint:exhaustivestruct
+		Type:            metal.DeviceActionReinstall,
+		OperatingSystem: equinixMetalMachine.Spec.OS,
+	}
+
+	if err := r.MetalClient.PerformDeviceAction(ctx, device.ID, action); err != nil {
+		return false, fmt.Errorf("failed to wipe device: %w", err)
+	}
+
+	r.invalidateDevice(scope, device)
+
+	equinixMetalMachine.Status.DeviceWipe = &infrav1.DeviceWipeStatus{
+		PreviousState: device.State,
+		StartTime:     metav1.Now(),
+		Reinstalling:  false,
+	}
+
+	log.Info("Wiping device before retaining its hardware reservation", "device", device.ID)
+	conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+		infrav1.DeviceWipeStartedReason, clusterv1.ConditionSeverityInfo, "")
+	r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeNormal, "SuccessfulWipe", "Wiping device %s", device.ID)
+
+	return false, nil
+}
+
+// reconcileRetainedHardwareReservations reports the provisionable hardware reservations of the project
+// retained for the machines of the cluster.
+func (r *EquinixMetalClusterReconciler) reconcileRetainedHardwareReservations(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) error {
+	reservations, err := r.retainedHardwareReservations(ctx, cluster, equinixMetalCluster)
+	if err != nil {
+		return err
+	}
+
+	var retained []infrav1.RetainedHardwareReservation
+
+	for _, reservation := range reservations {
+		if !reservation.Provisionable {
+			continue
+		}
+
+		pool, _ := metal.HardwareReservationPoolFromTags(reservation.Tags)
+		status := infrav1.RetainedHardwareReservation{ID: reservation.ID, Pool: pool} //nolint:exhaustivestruct
+
+		if reservation.Plan != nil {
+			status.MachineType = reservation.Plan.Slug
+		}
+
+		retained = append(retained, status)
+	}
+
+	equinixMetalCluster.Status.RetainedHardwareReservations = retained
+
+	if len(retained) == 0 {
+		conditions.Delete(equinixMetalCluster, infrav1.HardwareReservationsRetainedCondition)
+
+		return nil
+	}
+
+	conditions.Set(equinixMetalCluster, &clusterv1.Condition{ //nolint:exhaustivestruct
+		Type:    infrav1.HardwareReservationsRetainedCondition,
+		Status:  corev1.ConditionTrue,
+		Message: fmt.Sprintf("%d hardware reservations retained for reuse", len(retained)),
+	})
+
+	return nil
+}
+
+// releaseRetainedHardwareReservations removes the tags retaining hardware reservations for the machines of
+// a deleted cluster, so that they are not reused by a new cluster with the same name.
+func (r *EquinixMetalClusterReconciler) releaseRetainedHardwareReservations(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) error {
+	reservations, err := r.retainedHardwareReservations(ctx, cluster, equinixMetalCluster)
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		tags := metal.ReplaceOwnershipTags(reservation.Tags, nil)
+		if err := r.MetalClient.UpdateHardwareReservationTags(ctx, reservation.ID, tags); err != nil {
+			return fmt.Errorf("failed to release retained hardware reservation: %w", err)
+		}
+
+		ctrl.LoggerFrom(ctx).Info("Released retained hardware reservation", "hardwareReservation", reservation.ID)
+	}
+
+	return nil
+}
+
+// retainedHardwareReservations returns the hardware reservations of the project retained for the machines
+// of the cluster, whether or not a device runs on them.
+func (r *EquinixMetalClusterReconciler) retainedHardwareReservations(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) ([]metal.HardwareReservation, error) {
+	reservations, err := r.MetalClient.ListHardwareReservations(ctx, equinixMetalCluster.Spec.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up retained hardware reservations: %w", err)
+	}
+
+	clusterTag := metal.ClusterTag(cluster.Namespace, cluster.Name)
+	retained := make([]metal.HardwareReservation, 0, len(reservations))
+
+	for _, reservation := range reservations {
+		if _, ok := metal.HardwareReservationPoolFromTags(reservation.Tags); ok &&
+			metal.HasTags(reservation.Tags, clusterTag) {
+			retained = append(retained, reservation)
+		}
+	}
+
+	return retained, nil
+}
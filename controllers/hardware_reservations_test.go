@@ -0,0 +1,242 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+func newHardwareReservationTestScope(labels map[string]string, spec infrav1.EquinixMetalMachineSpec) *machineScope {
+	return &machineScope{
+		cluster: &clusterv1.Cluster{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"}, //nolint:exhaustivestruct
+		},
+		machine: &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Labels: labels}}, //nolint:exhaustivestruct
+		equinixMetalCluster: &infrav1.EquinixMetalCluster{ //nolint:exhaustivestruct
+			Spec: infrav1.EquinixMetalClusterSpec{ProjectID: "project"}, //nolint:exhaustivestruct
+		},
+		equinixMetalMachine: &infrav1.EquinixMetalMachine{Spec: spec}, //nolint:exhaustivestruct
+	}
+}
+
+func TestRetainedHardwareReservation(t *testing.T) {
+	t.Parallel()
+
+	clusterTag := metal.ClusterTag("ns", "cluster")
+	reservation := func(id, machineType string, provisionable bool, tags ...string) metal.HardwareReservation {
+		return metal.HardwareReservation{
+			ID:            id,
+			Provisionable: provisionable,
+			Plan:          &metal.Plan{Slug: machineType}, //nolint:exhaustivestruct
+			Tags:          tags,
+		}
+	}
+
+	reservations := []metal.HardwareReservation{
+		reservation("other-cluster", "c3.small.x86", true,
+			metal.ClusterTag("ns", "other"), metal.HardwareReservationPoolTag("md")),
+		reservation("other-pool", "c3.small.x86", true, clusterTag, metal.HardwareReservationPoolTag("other")),
+		reservation("in-use", "c3.small.x86", false, clusterTag, metal.HardwareReservationPoolTag("md")),
+		reservation("not-retained", "c3.small.x86", true, clusterTag),
+		reservation("md", "c3.small.x86", true, clusterTag, metal.HardwareReservationPoolTag("md")),
+		reservation("md-m3", "m3.large.x86", true, clusterTag, metal.HardwareReservationPoolTag("md")),
+		reservation("control-plane", "c3.small.x86", true,
+			clusterTag, metal.HardwareReservationPoolTag(controlPlaneHardwareReservationPool)),
+	}
+
+	metalClient := newTestMetalClient(t, map[string]interface{}{
+		"/projects/project/hardware-reservations": map[string]interface{}{"hardware_reservations": reservations},
+	})
+
+	mdLabels := map[string]string{clusterv1.MachineDeploymentLabelName: "md"}
+	controlPlaneLabels := map[string]string{clusterv1.MachineControlPlaneLabelName: ""}
+
+	tests := []struct {
+		name                  string
+		labels                map[string]string
+		machineType           string
+		hardwareReservationID string
+		want                  string
+	}{
+		{
+			name:                  "machine deployment",
+			labels:                mdLabels,
+			machineType:           "c3.small.x86",
+			hardwareReservationID: metal.NextAvailableHardwareReservation,
+			want:                  "md",
+		},
+		{
+			name:                  "other machine type",
+			labels:                mdLabels,
+			machineType:           "m3.large.x86",
+			hardwareReservationID: metal.NextAvailableHardwareReservation,
+			want:                  "md-m3",
+		},
+		{
+			name:                  "control plane",
+			labels:                controlPlaneLabels,
+			machineType:           "c3.small.x86",
+			hardwareReservationID: metal.NextAvailableHardwareReservation,
+			want:                  "control-plane",
+		},
+		{
+			name:                  "no reservation for the machine type",
+			labels:                controlPlaneLabels,
+			machineType:           "m3.large.x86",
+			hardwareReservationID: metal.NextAvailableHardwareReservation,
+			want:                  "",
+		},
+		{
+			name:                  "no reservation for the pool",
+			machineType:           "c3.small.x86",
+			hardwareReservationID: metal.NextAvailableHardwareReservation,
+			want:                  "",
+		},
+		{
+			name:                  "explicit hardware reservation",
+			labels:                mdLabels,
+			machineType:           "c3.small.x86",
+			hardwareReservationID: "explicit",
+			want:                  "",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &EquinixMetalMachineReconciler{MetalClient: metalClient}                        //nolint:exhaustivestruct
+			scope := newHardwareReservationTestScope(tt.labels, infrav1.EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				MachineType:           tt.machineType,
+				HardwareReservationID: tt.hardwareReservationID,
+			})
+
+			got, err := r.retainedHardwareReservation(context.Background(), scope)
+			if err != nil {
+				t.Fatalf("retainedHardwareReservation() error = %v", err)
+			}
+
+			gotID := ""
+			if got != nil {
+				gotID = got.ID
+			}
+
+			if gotID != tt.want {
+				t.Errorf("retainedHardwareReservation() = %q, want %q", gotID, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneRetainedHardwareReservation(t *testing.T) {
+	t.Parallel()
+
+	retained := &metal.HardwareReservation{ //nolint:exhaustivestruct
+		ID:   "retained",
+		Tags: []string{"user", metal.ClusterTag("ns", "cluster"), metal.HardwareReservationPoolTag("md")},
+	}
+
+	tests := []struct {
+		name        string
+		reservation *metal.HardwareReservation
+		createErr   error
+		want        []string
+		wantTags    []string
+	}{
+		{
+			name:        "rejected",
+			reservation: retained,
+			createErr:   &metal.APIError{StatusCode: http.StatusUnprocessableEntity}, //nolint:exhaustivestruct
+			want:        []string{"PATCH /hardware-reservations/retained"},
+			wantTags:    []string{"user"},
+		},
+		{
+			name:        "rate limited",
+			reservation: retained,
+			createErr:   &metal.APIError{StatusCode: http.StatusTooManyRequests}, //nolint:exhaustivestruct
+			want:        []string{},
+			wantTags:    retained.Tags,
+		},
+		{
+			name:        "server error",
+			reservation: retained,
+			createErr:   &metal.APIError{StatusCode: http.StatusServiceUnavailable}, //nolint:exhaustivestruct
+			want:        []string{},
+			wantTags:    retained.Tags,
+		},
+		{
+			name:        "network error",
+			reservation: retained,
+			createErr:   errors.New("connection refused"), //nolint:goerr113
+			want:        []string{},
+			wantTags:    retained.Tags,
+		},
+		{
+			name:      "no retained reservation",
+			createErr: &metal.APIError{StatusCode: http.StatusUnprocessableEntity}, //nolint:exhaustivestruct
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			metalClient, requests := newRecordingMetalClient(t)
+			r := &EquinixMetalMachineReconciler{ //nolint:exhaustivestruct
+				MetalClient: metalClient,
+				Recorder:    record.NewFakeRecorder(10),
+			}
+
+			var reservation *metal.HardwareReservation
+			if tt.reservation != nil {
+				copied := *tt.reservation
+				reservation = &copied
+			}
+
+			scope := newHardwareReservationTestScope(nil, infrav1.EquinixMetalMachineSpec{}) //nolint:exhaustivestruct
+
+			err := r.pruneRetainedHardwareReservation(context.Background(), scope, reservation, tt.createErr)
+			if err != nil {
+				t.Fatalf("pruneRetainedHardwareReservation() error = %v", err)
+			}
+
+			if got := requests.list(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pruneRetainedHardwareReservation() requests = %v, want %v", got, tt.want)
+			}
+
+			if reservation != nil && !reflect.DeepEqual(reservation.Tags, tt.wantTags) {
+				t.Errorf("pruneRetainedHardwareReservation() tags = %v, want %v", reservation.Tags, tt.wantTags)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// userDataURL is the metadata service endpoint serving the userdata of a device.
+	userDataURL = "https://metadata.platformequinix.com/userdata"
+	// metadataURL is the metadata service endpoint serving the metadata, including customdata, of a device.
+	metadataURL = "https://metadata.platformequinix.com/metadata"
+	// bootstrapDataCustomDataKey is the customdata key holding the bootstrap data when an inline iPXE script is used.
+	bootstrapDataCustomDataKey = "bootstrapData"
+)
+
+// applyIPXE sets the iPXE options of the device create request from the EquinixMetalMachine spec when
+// the device is installed with the custom iPXE OS. The OS of the request is the effective OS of the
+// device, which differs from the OS of the spec when the device is reinstalled with another OS.
+func applyIPXE(req *metal.DeviceCreateRequest, scope *machineScope, bootstrapData string) error {
+	spec := scope.equinixMetalMachine.Spec
+	if req.OperatingSystem != infrav1.CustomIPXEOS {
+		return nil
+	}
+
+	data := ipxeTemplateData(scope, spec.IPXEScript != "")
+
+	req.AlwaysPXE = spec.AlwaysPXE
+
+	if spec.IPXEUrl != "" {
+		ipxeURL, err := renderIPXETemplate("ipxeURL", spec.IPXEUrl, data)
+		if err != nil {
+			return err
+		}
+
+		req.IPXEScriptURL = ipxeURL
+
+		return nil
+	}
+
+	script, err := renderIPXETemplate("ipxeScript", spec.IPXEScript, data)
+	if err != nil {
+		return err
+	}
+
+	// Inline scripts are read from the userdata, so the bootstrap data is moved to the customdata.
+	req.UserData = script
+	req.CustomData = map[string]interface{}{bootstrapDataCustomDataKey: bootstrapData}
+
+	return nil
+}
+
+// ipxeTemplateData returns the variables available to the IPXEUrl and IPXEScript templates of the
+// machine. BootstrapURL is not available to inline scripts, as their bootstrap data is only served as
+// part of the metadata of the device.
+func ipxeTemplateData(scope *machineScope, inlineScript bool) map[string]string {
+	data := map[string]string{
+		"MachineName": scope.machine.Name,
+		"ClusterName": scope.cluster.Name,
+		"Namespace":   scope.equinixMetalMachine.Namespace,
+		"MetadataURL": metadataURL,
+	}
+
+	if !inlineScript {
+		data["BootstrapURL"] = userDataURL
+	}
+
+	return data
+}
+
+// deviceOS returns the effective operating system of the device: the OS of its last in-place
+// reinstallation, or the OS of the spec it was created with.
+func deviceOS(equinixMetalMachine *infrav1.EquinixMetalMachine, device *metal.Device) string {
+	if last := equinixMetalMachine.Status.LastReinstall; last != nil && last.Time.After(device.CreatedAt) {
+		return last.OS
+	}
+
+	return equinixMetalMachine.Spec.OS
+}
+
+func renderIPXETemplate(name, text string, data map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+func newIPXETestScope(spec infrav1.EquinixMetalMachineSpec) *machineScope {
+	return &machineScope{
+		cluster:             &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}, //nolint:exhaustivestruct
+		machine:             &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine"}}, //nolint:exhaustivestruct
+		equinixMetalCluster: &infrav1.EquinixMetalCluster{},                                     //nolint:exhaustivestruct
+		equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns"}, //nolint:exhaustivestruct
+			Spec:       spec,
+		},
+	}
+}
+
+func TestRenderIPXETemplate(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]string{"MachineName": "machine", "ClusterName": "cluster"}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", text: "https://boot.example.com/ipxe", want: "https://boot.example.com/ipxe"},
+		{
+			name: "variables",
+			text: "https://boot.example.com/{{ .ClusterName }}/{{ .MachineName }}",
+			want: "https://boot.example.com/cluster/machine",
+		},
+		{name: "unknown variable", text: "{{ .BootstrapURL }}", wantErr: true},
+		{name: "parse error", text: "{{ .MachineName ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := renderIPXETemplate("ipxe", tt.text, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderIPXETemplate(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("renderIPXETemplate(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyIPXE(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		os      string
+		spec    infrav1.EquinixMetalMachineSpec
+		want    *metal.DeviceCreateRequest
+		wantErr bool
+	}{
+		{
+			name: "other OS",
+			os:   "ubuntu_20_04",
+			spec: infrav1.EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				IPXEUrl:   "https://boot.example.com",
+				AlwaysPXE: true,
+			},
+			want: &metal.DeviceCreateRequest{OperatingSystem: "ubuntu_20_04", UserData: "data"}, //nolint:exhaustivestruct
+		},
+		{
+			name: "URL",
+			os:   infrav1.CustomIPXEOS,
+			spec: infrav1.EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				IPXEUrl:   "https://boot.example.com/{{ .Namespace }}/{{ .MachineName }}?data={{ .BootstrapURL }}",
+				AlwaysPXE: true,
+			},
+			want: &metal.DeviceCreateRequest{ //nolint:exhaustivestruct
+				OperatingSystem: infrav1.CustomIPXEOS,
+				UserData:        "data",
+				IPXEScriptURL:   "https://boot.example.com/ns/machine?data=" + userDataURL,
+				AlwaysPXE:       true,
+			},
+		},
+		{
+			name: "inline script",
+			os:   infrav1.CustomIPXEOS,
+			spec: infrav1.EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+				IPXEScript: "#!ipxe\nchain https://boot.example.com/{{ .ClusterName }}?metadata={{ .MetadataURL }}",
+			},
+			want: &metal.DeviceCreateRequest{ //nolint:exhaustivestruct
+				OperatingSystem: infrav1.CustomIPXEOS,
+				UserData:        "#!ipxe\nchain https://boot.example.com/cluster?metadata=" + metadataURL,
+				CustomData:      map[string]interface{}{bootstrapDataCustomDataKey: "data"},
+			},
+		},
+		{
+			name:    "bootstrap URL in inline script",
+			os:      infrav1.CustomIPXEOS,
+			spec:    infrav1.EquinixMetalMachineSpec{IPXEScript: "#!ipxe\nchain {{ .BootstrapURL }}"}, //nolint:exhaustivestruct
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := &metal.DeviceCreateRequest{OperatingSystem: tt.os, UserData: "data"} //nolint:exhaustivestruct
+
+			err := applyIPXE(req, newIPXETestScope(tt.spec), "data")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyIPXE() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(req, tt.want) {
+				t.Errorf("applyIPXE() = %+v, want %+v", req, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceOS(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		lastReinstall *infrav1.ReinstallStatus
+		want          string
+	}{
+		{name: "not reinstalled", want: infrav1.CustomIPXEOS},
+		{
+			name: "reinstalled",
+			lastReinstall: &infrav1.ReinstallStatus{ //nolint:exhaustivestruct
+				OS:   "ubuntu_20_04",
+				Time: metav1.NewTime(createdAt.Add(time.Hour)),
+			},
+			want: "ubuntu_20_04",
+		},
+		{
+			name: "reinstalled before the device was created",
+			lastReinstall: &infrav1.ReinstallStatus{ //nolint:exhaustivestruct
+				OS:   "ubuntu_20_04",
+				Time: metav1.NewTime(createdAt.Add(-time.Hour)),
+			},
+			want: infrav1.CustomIPXEOS,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			equinixMetalMachine := &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+				Spec:   infrav1.EquinixMetalMachineSpec{OS: infrav1.CustomIPXEOS},          //nolint:exhaustivestruct
+				Status: infrav1.EquinixMetalMachineStatus{LastReinstall: tt.lastReinstall}, //nolint:exhaustivestruct
+			}
+			device := &metal.Device{CreatedAt: createdAt} //nolint:exhaustivestruct
+
+			if got := deviceOS(equinixMetalMachine, device); got != tt.want {
+				t.Errorf("deviceOS() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
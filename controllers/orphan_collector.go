@@ -0,0 +1,397 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// OrphanDetectedReason is the event reason used when an orphaned Equinix Metal resource is found.
+	OrphanDetectedReason = "OrphanDetected"
+	// OrphanDeletedReason is the event reason used when an orphaned Equinix Metal resource is deleted.
+	OrphanDeletedReason = "OrphanDeleted"
+	// OrphanDeleteFailedReason is the event reason used when an orphaned Equinix Metal resource can't be deleted.
+	OrphanDeleteFailedReason = "OrphanDeleteFailed"
+)
+
+// OrphanCollector periodically looks for Equinix Metal resources carrying this provider's
+// ownership tags that no longer have a matching EquinixMetalMachine or Cluster, and
+// reports or deletes them once they have been orphaned for longer than GracePeriod.
+//
+// Only the resources tagged with the ManagerID of this management cluster are collected, so that
+// management clusters sharing a project don't collect each other's resources. Devices are owned by
+// the EquinixMetalMachines referencing them through their providerID, which is kept when the machines
+// are moved to another management cluster, whose reconcilers then replace the ownership tags.
+//
+// The IPs and VLANs of a cluster are only orphaned once the cluster is gone, along with the
+// EquinixMetalCluster referencing its project, so they are only found in ProjectIDs.
+// The time a resource was first found orphaned is kept in memory, so the grace period
+// starts over whenever the collector restarts, for example after a leader change.
+type OrphanCollector struct {
+	client.Client
+	MetalClient *metal.Client
+	Recorder    record.EventRecorder
+
+	// Interval is how often the project resources are listed.
+	Interval time.Duration
+	// GracePeriod is how long a resource has to be orphaned before it is acted upon.
+	GracePeriod time.Duration
+	// DryRun only reports orphaned resources instead of deleting them.
+	DryRun bool
+	// ProjectIDs are scanned in addition to the projects referenced by EquinixMetalClusters.
+	// They must include the projects of deleted clusters for their IPs and VLANs to be collected.
+	ProjectIDs []string
+	// Namespace restricts the collection to resources owned by objects in this namespace.
+	Namespace string
+	// ManagerID identifies the management cluster in the ownership tags of the resources to collect.
+	ManagerID string
+
+	firstSeen map[string]time.Time
+}
+
+// orphan is an Equinix Metal resource that has lost its owning object.
+type orphan struct {
+	kind    string
+	id      string
+	name    string
+	cluster types.NamespacedName
+	delete  func(ctx context.Context) error
+}
+
+// deviceOwners holds what identifies the devices of the EquinixMetalMachines: the device IDs of their
+// providerIDs, and their UIDs for the devices being created, which don't have a providerID yet.
+type deviceOwners struct {
+	deviceIDs sets.String
+	uids      map[types.UID]bool
+}
+
+// owns returns true if the device belongs to one of the EquinixMetalMachines.
+func (o deviceOwners) owns(device *metal.Device) bool {
+	if o.deviceIDs.Has(device.ID) {
+		return true
+	}
+
+	for _, uid := range metal.MachineUIDsFromTags(device.Tags) {
+		if o.uids[uid] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetupWithManager adds the OrphanCollector to the Manager.
+func (c *OrphanCollector) SetupWithManager(mgr ctrl.Manager) error {
+	if c.Client == nil {
+		c.Client = mgr.GetClient()
+	}
+
+	if c.Recorder == nil {
+		c.Recorder = mgr.GetEventRecorderFor("equinixmetal-orphan-collector")
+	}
+
+	if err := mgr.Add(c); err != nil {
+		return fmt.Errorf("failed to add orphan collector to manager: %w", err)
+	}
+
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable so only the leader deletes resources.
+func (c *OrphanCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable and blocks until the context is cancelled.
+func (c *OrphanCollector) Start(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx).WithName("orphan-collector")
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	c.firstSeen = map[string]time.Time{}
+
+	log.Info("Starting orphan collector", "interval", c.Interval, "grace-period", c.GracePeriod, "dry-run", c.DryRun)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.collect(ctx); err != nil {
+			log.Error(err, "Orphan collection failed")
+		}
+	}, c.Interval)
+
+	return nil
+}
+
+func (c *OrphanCollector) collect(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	projectIDs, err := c.projectIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	owners, err := c.deviceOwners(ctx)
+	if err != nil {
+		return err
+	}
+
+	seen := sets.NewString()
+
+	for _, projectID := range projectIDs.List() {
+		orphans, err := c.findOrphans(ctx, projectID, owners)
+		if err != nil {
+			log.Error(err, "Failed to look for orphaned resources", "project", projectID)
+
+			continue
+		}
+
+		for _, o := range orphans {
+			key := o.kind + "/" + o.id
+			seen.Insert(key)
+
+			if _, ok := c.firstSeen[key]; !ok {
+				c.firstSeen[key] = time.Now()
+			}
+
+			if time.Since(c.firstSeen[key]) < c.GracePeriod {
+				continue
+			}
+
+			c.handleOrphan(ctx, o)
+		}
+	}
+
+	// Forget about resources that have been deleted or adopted in the meantime.
+	for key := range c.firstSeen {
+		if !seen.Has(key) {
+			delete(c.firstSeen, key)
+		}
+	}
+
+	return nil
+}
+
+func (c *OrphanCollector) projectIDs(ctx context.Context) (sets.String, error) {
+	projectIDs := sets.NewString(c.ProjectIDs...)
+
+	clusters := new(infrav1.EquinixMetalClusterList)
+	if err := c.Client.List(ctx, clusters, client.InNamespace(c.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list EquinixMetalClusters: %w", err)
+	}
+
+	for _, cluster := range clusters.Items {
+		if cluster.Spec.ProjectID != "" {
+			projectIDs.Insert(cluster.Spec.ProjectID)
+		}
+	}
+
+	return projectIDs, nil
+}
+
+func (c *OrphanCollector) deviceOwners(ctx context.Context) (deviceOwners, error) {
+	machines := new(infrav1.EquinixMetalMachineList)
+	if err := c.Client.List(ctx, machines, client.InNamespace(c.Namespace)); err != nil {
+		return deviceOwners{}, fmt.Errorf("failed to list EquinixMetalMachines: %w", err)
+	}
+
+	owners := deviceOwners{deviceIDs: sets.NewString(), uids: make(map[types.UID]bool, len(machines.Items))}
+
+	for _, m := range machines.Items {
+		owners.uids[m.UID] = true
+
+		if m.Spec.ProviderID != nil && *m.Spec.ProviderID != "" {
+			owners.deviceIDs.Insert(metal.DeviceIDFromProviderID(*m.Spec.ProviderID))
+		}
+	}
+
+	return owners, nil
+}
+
+func (c *OrphanCollector) findOrphans( //nolint:cyclop
+	ctx context.Context,
+	projectID string,
+	owners deviceOwners,
+) ([]orphan, error) {
+	var orphans []orphan
+
+	devices, err := c.MetalClient.ListDevices(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	for i := range devices {
+		device := devices[i]
+
+		if owners.owns(&device) {
+			continue
+		}
+
+		cluster, ok := c.owningCluster(device.Tags)
+		if !ok {
+			continue
+		}
+
+		orphans = append(orphans, orphan{
+			kind:    "device",
+			id:      device.ID,
+			name:    device.Hostname,
+			cluster: cluster,
+			delete:  func(ctx context.Context) error { return c.MetalClient.DeleteDevice(ctx, device.ID) },
+		})
+	}
+
+	ips, err := c.MetalClient.ListIPReservations(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ip reservations: %w", err)
+	}
+
+	for i := range ips {
+		ip := ips[i]
+
+		cluster, ok := c.owningCluster(ip.Tags)
+		if !ok {
+			continue
+		}
+
+		exists, err := c.clusterExists(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			continue
+		}
+
+		orphans = append(orphans, orphan{
+			kind:    "ip",
+			id:      ip.ID,
+			name:    ip.Address,
+			cluster: cluster,
+			delete:  func(ctx context.Context) error { return c.MetalClient.DeleteIPReservation(ctx, ip.ID) },
+		})
+	}
+
+	vlans, err := c.MetalClient.ListVirtualNetworks(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list virtual networks: %w", err)
+	}
+
+	for i := range vlans {
+		vlan := vlans[i]
+
+		cluster, ok := c.owningCluster(vlan.Tags)
+		if !ok {
+			continue
+		}
+
+		exists, err := c.clusterExists(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			continue
+		}
+
+		orphans = append(orphans, orphan{
+			kind:    "vlan",
+			id:      vlan.ID,
+			name:    fmt.Sprintf("%d", vlan.VXLAN),
+			cluster: cluster,
+			delete:  func(ctx context.Context) error { return c.MetalClient.DeleteVirtualNetwork(ctx, vlan.ID) },
+		})
+	}
+
+	return orphans, nil
+}
+
+// owningCluster returns the Cluster referenced by the tags, ignoring resources of other management clusters
+// and clusters outside of the watched namespace since the matching objects would not be visible to this manager.
+func (c *OrphanCollector) owningCluster(tags []string) (types.NamespacedName, bool) {
+	if !metal.HasTags(tags, metal.ManagerTag(c.ManagerID)) {
+		return types.NamespacedName{}, false
+	}
+
+	cluster, ok := metal.ClusterFromTags(tags)
+	if !ok || (c.Namespace != "" && cluster.Namespace != c.Namespace) {
+		return types.NamespacedName{}, false
+	}
+
+	return cluster, true
+}
+
+func (c *OrphanCollector) clusterExists(ctx context.Context, name types.NamespacedName) (bool, error) {
+	err := c.Client.Get(ctx, name, new(clusterv1.Cluster))
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to get Cluster %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+func (c *OrphanCollector) handleOrphan(ctx context.Context, o orphan) {
+	log := ctrl.LoggerFrom(ctx).WithValues(o.kind, o.id, "name", o.name, "cluster", o.cluster.String())
+	ref := clusterReference(o.cluster)
+
+	if c.DryRun {
+		log.Info("Found orphaned resource, skipping deletion in dry-run mode")
+		c.Recorder.Eventf(ref, corev1.EventTypeWarning, OrphanDetectedReason,
+			"Orphaned %s %s (%s) would be deleted", o.kind, o.name, o.id)
+
+		return
+	}
+
+	if err := o.delete(ctx); err != nil && !metal.IsNotFound(err) {
+		log.Error(err, "Failed to delete orphaned resource")
+		c.Recorder.Eventf(ref, corev1.EventTypeWarning, OrphanDeleteFailedReason,
+			"Failed to delete orphaned %s %s (%s): %v", o.kind, o.name, o.id, err)
+
+		return
+	}
+
+	log.Info("Deleted orphaned resource")
+	c.Recorder.Eventf(ref, corev1.EventTypeNormal, OrphanDeletedReason,
+		"Deleted orphaned %s %s (%s)", o.kind, o.name, o.id)
+}
+
+// clusterReference returns a reference to the Cluster that owned a resource, which might not exist anymore.
+func clusterReference(name types.NamespacedName) *corev1.ObjectReference {
+	return &corev1.ObjectReference{ //nolint:exhaustivestruct
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "Cluster",
+		Namespace:  name.Namespace,
+		Name:       name.Name,
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// newTestMetalClient returns a Metal client sending its requests to a test server serving the
+// given JSON responses by path.
+func newTestMetalClient(t *testing.T, responses map[string]interface{}) *metal.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	metalClient, err := metal.NewClient(server.URL, "token", server.Client())
+	if err != nil {
+		t.Fatalf("failed to create Metal client: %v", err)
+	}
+
+	return metalClient
+}
+
+// newTestClient returns a fake client holding the given objects.
+func newTestClient(t *testing.T, objects ...client.Object) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to create scheme: %v", err)
+	}
+
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to create scheme: %v", err)
+	}
+
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to create scheme: %v", err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestFindOrphans(t *testing.T) {
+	t.Parallel()
+
+	managerTag := metal.ManagerTag("manager")
+	clusterTag := metal.ClusterTag("ns", "cluster")
+	goneClusterTag := metal.ClusterTag("ns", "gone")
+
+	providerID := "equinixmetal://moved"
+	objects := []client.Object{
+		&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"}}, //nolint:exhaustivestruct
+		&infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "moved", UID: "new-uid"}, //nolint:exhaustivestruct
+			Spec:       infrav1.EquinixMetalMachineSpec{ProviderID: &providerID},          //nolint:exhaustivestruct
+		},
+		&infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "creating", UID: "creating-uid"}, //nolint:exhaustivestruct
+		},
+	}
+
+	devices := []metal.Device{
+		{ID: "moved", Tags: []string{clusterTag, metal.MachineTag("old-uid"), managerTag}},         //nolint:exhaustivestruct
+		{ID: "creating", Tags: []string{clusterTag, metal.MachineTag("creating-uid"), managerTag}}, //nolint:exhaustivestruct
+		{ID: "orphan", Tags: []string{clusterTag, metal.MachineTag("deleted-uid"), managerTag}},    //nolint:exhaustivestruct
+		{ID: "stale-uid", Tags: []string{ //nolint:exhaustivestruct
+			clusterTag, metal.MachineTag("deleted-uid"), metal.MachineTag("creating-uid"), managerTag,
+		}},
+		{ID: "other-manager", Tags: []string{ //nolint:exhaustivestruct
+			clusterTag, metal.MachineTag("deleted-uid"), metal.ManagerTag("other"),
+		}},
+		{ID: "no-manager", Tags: []string{clusterTag, metal.MachineTag("deleted-uid")}}, //nolint:exhaustivestruct
+		{ID: "untagged"}, //nolint:exhaustivestruct
+	}
+
+	ips := []metal.IPReservation{
+		{ID: "ip-cluster", Tags: []string{clusterTag, managerTag}},                          //nolint:exhaustivestruct
+		{ID: "ip-orphan", Tags: []string{goneClusterTag, managerTag}},                       //nolint:exhaustivestruct
+		{ID: "ip-other-manager", Tags: []string{goneClusterTag, metal.ManagerTag("other")}}, //nolint:exhaustivestruct
+	}
+
+	vlans := []metal.VirtualNetwork{
+		{ID: "vlan-orphan", Tags: []string{goneClusterTag, managerTag}}, //nolint:exhaustivestruct
+		{ID: "vlan-no-manager", Tags: []string{goneClusterTag}},         //nolint:exhaustivestruct
+	}
+
+	collector := &OrphanCollector{ //nolint:exhaustivestruct
+		Client: newTestClient(t, objects...),
+		MetalClient: newTestMetalClient(t, map[string]interface{}{
+			"/projects/project/devices":          map[string]interface{}{"devices": devices},
+			"/projects/project/ips":              map[string]interface{}{"ip_addresses": ips},
+			"/projects/project/virtual-networks": map[string]interface{}{"virtual_networks": vlans},
+		}),
+		ManagerID: "manager",
+	}
+
+	ctx := context.Background()
+
+	owners, err := collector.deviceOwners(ctx)
+	if err != nil {
+		t.Fatalf("deviceOwners() error = %v", err)
+	}
+
+	orphans, err := collector.findOrphans(ctx, "project", owners)
+	if err != nil {
+		t.Fatalf("findOrphans() error = %v", err)
+	}
+
+	got := make([]string, 0, len(orphans))
+	for _, o := range orphans {
+		got = append(got, o.kind+"/"+o.id)
+
+		if o.kind == "device" && o.cluster != (types.NamespacedName{Namespace: "ns", Name: "cluster"}) {
+			t.Errorf("cluster of %s = %s, want ns/cluster", o.id, o.cluster)
+		}
+	}
+
+	sort.Strings(got)
+
+	want := []string{"device/orphan", "ip/ip-orphan", "vlan/vlan-orphan"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findOrphans() = %v, want %v", got, want)
+	}
+}
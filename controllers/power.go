@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// powerActionRequest is a power action requested through an annotation.
+type powerActionRequest struct {
+	action      infrav1.PowerAction
+	requestedAt metav1.Time
+}
+
+// pendingPowerAction returns the most recent power action requested through the annotations of the
+// EquinixMetalMachine that has not been carried out yet, or nil if there is none. Older requests are
+// superseded by the most recent one.
+func pendingPowerAction(equinixMetalMachine *infrav1.EquinixMetalMachine) *powerActionRequest {
+	annotations := []struct {
+		name   string
+		action infrav1.PowerAction
+	}{
+		{infrav1.RebootRequestedAnnotation, infrav1.PowerActionReboot},
+		{infrav1.PowerOffRequestedAnnotation, infrav1.PowerActionOff},
+		{infrav1.PowerOnRequestedAnnotation, infrav1.PowerActionOn},
+	}
+
+	var pending *powerActionRequest
+
+	for _, annotation := range annotations {
+		value, ok := equinixMetalMachine.Annotations[annotation.name]
+		if !ok {
+			continue
+		}
+
+		requestedAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			conditions.MarkFalse(equinixMetalMachine, infrav1.PowerActionSucceededCondition,
+				infrav1.PowerActionFailedReason, clusterv1.ConditionSeverityWarning,
+				"invalid %s annotation, expected an RFC 3339 time: %v", annotation.name, err)
+
+			continue
+		}
+
+		if last := equinixMetalMachine.Status.LastPowerAction; last != nil && !requestedAt.After(last.RequestedAt.Time) {
+			continue
+		}
+
+		if pending == nil || requestedAt.After(pending.requestedAt.Time) {
+			pending = &powerActionRequest{action: annotation.action, requestedAt: metav1.NewTime(requestedAt)}
+		}
+	}
+
+	return pending
+}
+
+// reconcilePowerAction carries out the pending power action of the machine, if any, once the device
+// has been provisioned. It returns true if an action was requested to the Equinix Metal API.
+func (r *EquinixMetalMachineReconciler) reconcilePowerAction(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	request := pendingPowerAction(equinixMetalMachine)
+	if request == nil {
+		return false, nil
+	}
+
+	if device.State != metal.DeviceStateActive && device.State != metal.DeviceStateInactive {
+		log.Info("Waiting for the device to be provisioned to carry out power action", "action", request.action)
+
+		return false, nil
+	}
+
+	err := r.MetalClient.PerformDeviceAction(ctx, device.ID, &metal.DeviceActionRequest{Type: string(request.action)})
+	if _, retry := metal.RetryAfter(err); retry {
+		return false, err
+	}
+
+	// The action is recorded even when it failed, so that it is not repeated on requeue.
+	equinixMetalMachine.Status.LastPowerAction = &infrav1.PowerActionStatus{
+		Action:      request.action,
+		RequestedAt: request.requestedAt,
+		Time:        metav1.Now(),
+	}
+
+	if err != nil {
+		log.Error(err, "Failed to carry out power action", "action", request.action)
+		conditions.MarkFalse(equinixMetalMachine, infrav1.PowerActionSucceededCondition,
+			infrav1.PowerActionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "FailedPowerAction",
+			"Failed to %s device %s: %v", request.action, device.ID, err)
+
+		return false, nil
+	}
+
+	log.Info("Carried out power action", "action", request.action, "device", device.ID)
+	conditions.MarkTrue(equinixMetalMachine, infrav1.PowerActionSucceededCondition)
+	r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeNormal, "SuccessfulPowerAction",
+		"Requested %s of device %s", request.action, device.ID)
+	r.invalidateDevice(scope, device)
+
+	return true, nil
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+func TestPendingPowerAction(t *testing.T) {
+	t.Parallel()
+
+	earlier := "2022-01-01T10:00:00Z"
+	later := "2022-01-01T11:00:00Z"
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		last        *infrav1.PowerActionStatus
+		want        *powerActionRequest
+		wantInvalid bool
+	}{
+		{
+			name: "no request",
+		},
+		{
+			name:        "single request",
+			annotations: map[string]string{infrav1.PowerOffRequestedAnnotation: earlier},
+			want:        &powerActionRequest{action: infrav1.PowerActionOff, requestedAt: mustParseTime(t, earlier)},
+		},
+		{
+			name: "most recent request wins",
+			annotations: map[string]string{
+				infrav1.PowerOffRequestedAnnotation: earlier,
+				infrav1.PowerOnRequestedAnnotation:  later,
+			},
+			want: &powerActionRequest{action: infrav1.PowerActionOn, requestedAt: mustParseTime(t, later)},
+		},
+		{
+			name: "most recent request wins regardless of the annotation",
+			annotations: map[string]string{
+				infrav1.RebootRequestedAnnotation:  later,
+				infrav1.PowerOnRequestedAnnotation: earlier,
+			},
+			want: &powerActionRequest{action: infrav1.PowerActionReboot, requestedAt: mustParseTime(t, later)},
+		},
+		{
+			name:        "request already carried out",
+			annotations: map[string]string{infrav1.PowerOffRequestedAnnotation: earlier},
+			last: &infrav1.PowerActionStatus{ //nolint:exhaustivestruct
+				Action:      infrav1.PowerActionOff,
+				RequestedAt: mustParseTime(t, earlier),
+			},
+		},
+		{
+			name: "request newer than the last action",
+			annotations: map[string]string{
+				infrav1.PowerOffRequestedAnnotation: earlier,
+				infrav1.PowerOnRequestedAnnotation:  later,
+			},
+			last: &infrav1.PowerActionStatus{ //nolint:exhaustivestruct
+				Action:      infrav1.PowerActionOff,
+				RequestedAt: mustParseTime(t, earlier),
+			},
+			want: &powerActionRequest{action: infrav1.PowerActionOn, requestedAt: mustParseTime(t, later)},
+		},
+		{
+			name: "invalid request",
+			annotations: map[string]string{
+				infrav1.RebootRequestedAnnotation:   "now",
+				infrav1.PowerOffRequestedAnnotation: earlier,
+			},
+			want:        &powerActionRequest{action: infrav1.PowerActionOff, requestedAt: mustParseTime(t, earlier)},
+			wantInvalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			equinixMetalMachine := &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},              //nolint:exhaustivestruct
+				Status:     infrav1.EquinixMetalMachineStatus{LastPowerAction: tt.last}, //nolint:exhaustivestruct
+			}
+
+			got := pendingPowerAction(equinixMetalMachine)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pendingPowerAction() = %+v, want %+v", got, tt.want)
+			}
+
+			invalid := conditions.IsFalse(equinixMetalMachine, infrav1.PowerActionSucceededCondition)
+			if invalid != tt.wantInvalid {
+				t.Errorf("pendingPowerAction() marked the request invalid = %t, want %t", invalid, tt.wantInvalid)
+			}
+		})
+	}
+}
+
+func TestReconcilePowerAction(t *testing.T) {
+	t.Parallel()
+
+	requestedAt := "2022-01-01T10:00:00Z"
+
+	tests := []struct {
+		name          string
+		state         string
+		wantPerformed bool
+		wantRequests  []string
+	}{
+		{
+			name:          "active device",
+			state:         metal.DeviceStateActive,
+			wantPerformed: true,
+			wantRequests:  []string{"POST /devices/device/actions"},
+		},
+		{
+			name:          "inactive device",
+			state:         metal.DeviceStateInactive,
+			wantPerformed: true,
+			wantRequests:  []string{"POST /devices/device/actions"},
+		},
+		{
+			name:         "device being provisioned",
+			state:        metal.DeviceStateProvisioning,
+			wantRequests: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			metalClient, requests := newRecordingMetalClient(t)
+			r := &EquinixMetalMachineReconciler{ //nolint:exhaustivestruct
+				MetalClient: metalClient,
+				Recorder:    record.NewFakeRecorder(10),
+			}
+			scope := &machineScope{ //nolint:exhaustivestruct
+				cluster:             &clusterv1.Cluster{},           //nolint:exhaustivestruct
+				equinixMetalCluster: &infrav1.EquinixMetalCluster{}, //nolint:exhaustivestruct
+				equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+					ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustivestruct
+						Annotations: map[string]string{infrav1.PowerOnRequestedAnnotation: requestedAt},
+					},
+				},
+			}
+			device := &metal.Device{ID: "device", State: tt.state} //nolint:exhaustivestruct
+
+			performed, err := r.reconcilePowerAction(context.Background(), scope, device)
+			if err != nil {
+				t.Fatalf("reconcilePowerAction() error = %v", err)
+			}
+
+			if performed != tt.wantPerformed {
+				t.Errorf("reconcilePowerAction() = %t, want %t", performed, tt.wantPerformed)
+			}
+
+			// A second reconciliation must not repeat the action.
+			if _, err := r.reconcilePowerAction(context.Background(), scope, device); err != nil {
+				t.Fatalf("reconcilePowerAction() error = %v", err)
+			}
+
+			if got := requests.list(); !reflect.DeepEqual(got, tt.wantRequests) {
+				t.Errorf("reconcilePowerAction() sent %v, want %v", got, tt.wantRequests)
+			}
+
+			last := scope.equinixMetalMachine.Status.LastPowerAction
+			if recorded := last != nil; recorded != tt.wantPerformed {
+				t.Errorf("reconcilePowerAction() recorded the action = %t, want %t", recorded, tt.wantPerformed)
+			}
+
+			want := mustParseTime(t, requestedAt)
+			if last != nil && (last.Action != infrav1.PowerActionOn || !last.RequestedAt.Equal(&want)) {
+				t.Errorf("reconcilePowerAction() recorded %+v", last)
+			}
+		})
+	}
+}
+
+func mustParseTime(t *testing.T, value string) metav1.Time {
+	t.Helper()
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("failed to parse time: %v", err)
+	}
+
+	return metav1.NewTime(parsed)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// projectDeletionRequeueInterval is how often a managed project waiting for its devices to be deleted is checked.
+const projectDeletionRequeueInterval = 30 * time.Second
+
+// ErrProjectNameConflict is returned when the organization has a project with the name of the managed
+// project that was not created for the cluster.
+var ErrProjectNameConflict = errors.New("a project that was not created for the cluster already has the name")
+
+// projectName returns the name of the managed project of a cluster.
+func projectName(cluster *clusterv1.Cluster, project *infrav1.ManagedProject) string {
+	if project.Name != "" {
+		return project.Name
+	}
+
+	return cluster.Namespace + "-" + cluster.Name
+}
+
+// reconcileProject creates the managed project of the cluster and records its ID. The project is marked
+// as created for the cluster with its custom data, so that a project of the organization with the same
+// name is only used instead of creating another one when it was created for the cluster and recording its
+// ID failed. Any other project with the same name is reported as a conflict.
+func (r *EquinixMetalClusterReconciler) reconcileProject(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) error {
+	if equinixMetalCluster.Spec.ProjectID != "" {
+		conditions.MarkTrue(equinixMetalCluster, infrav1.ProjectReadyCondition)
+
+		return nil
+	}
+
+	spec := equinixMetalCluster.Spec.Project
+	name := projectName(cluster, spec)
+
+	project, err := r.MetalClient.GetOrganizationProjectByName(ctx, spec.OrganizationID, name)
+	if err == nil && project != nil && !project.IsOwnedBy(cluster.Namespace, cluster.Name) {
+		err = fmt.Errorf("%w: %s (%s)", ErrProjectNameConflict, name, project.ID)
+	}
+
+	if err == nil && project == nil {
+		project, err = r.MetalClient.CreateProject(ctx, spec.OrganizationID, &metal.ProjectCreateRequest{
+			Name:       name,
+			CustomData: metal.ProjectOwnerCustomData(cluster.Namespace, cluster.Name),
+		})
+		if err == nil {
+			ctrl.LoggerFrom(ctx).Info("Created project", "project", project.ID, "name", name)
+			r.Recorder.Eventf(equinixMetalCluster, corev1.EventTypeNormal, "SuccessfulCreateProject",
+				"Created project %s (%s)", name, project.ID)
+		}
+	}
+
+	if err != nil {
+		conditions.MarkFalse(equinixMetalCluster, infrav1.ProjectReadyCondition,
+			infrav1.ProjectCreationFailedReason, clusterv1.ConditionSeverityError, err.Error())
+
+		return fmt.Errorf("failed to create project: %w", err)
+	}
+
+	equinixMetalCluster.Spec.ProjectID = project.ID
+	conditions.MarkTrue(equinixMetalCluster, infrav1.ProjectReadyCondition)
+
+	return nil
+}
+
+// deleteProject deletes the managed project of the cluster once all its devices are gone. It returns
+// false while the project still has devices. Projects that were not created for the cluster are left alone.
+func (r *EquinixMetalClusterReconciler) deleteProject(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	projectID := equinixMetalCluster.Spec.ProjectID
+
+	if projectID == "" {
+		return true, nil
+	}
+
+	project, err := r.MetalClient.GetProject(ctx, projectID)
+	if metal.IsNotFound(err) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	if !project.IsOwnedBy(cluster.Namespace, cluster.Name) {
+		log.Info("Not deleting project that was not created for the cluster", "project", projectID)
+		r.Recorder.Eventf(equinixMetalCluster, corev1.EventTypeWarning, "SkippedDeleteProject",
+			"Not deleting project %s, it was not created for the cluster", projectID)
+
+		return true, nil
+	}
+
+	devices, err := r.MetalClient.ListDevices(ctx, projectID)
+	if err != nil && !metal.IsNotFound(err) {
+		return false, fmt.Errorf("failed to list devices of project: %w", err)
+	}
+
+	if len(devices) > 0 {
+		log.Info("Waiting for the devices of the project to be deleted", "project", projectID, "devices", len(devices))
+		conditions.MarkFalse(equinixMetalCluster, infrav1.ProjectReadyCondition,
+			infrav1.WaitingForDevicesDeletionReason, clusterv1.ConditionSeverityInfo,
+			"waiting for %d devices to be deleted", len(devices))
+
+		return false, nil
+	}
+
+	if err := r.MetalClient.DeleteProject(ctx, projectID); err != nil && !metal.IsNotFound(err) {
+		r.Recorder.Eventf(equinixMetalCluster, corev1.EventTypeWarning, "FailedDeleteProject",
+			"Failed to delete project %s: %v", projectID, err)
+
+		return false, fmt.Errorf("failed to delete project: %w", err)
+	}
+
+	log.Info("Deleted project", "project", projectID)
+	r.Recorder.Eventf(equinixMetalCluster, corev1.EventTypeNormal, "SuccessfulDeleteProject",
+		"Deleted project %s", projectID)
+
+	return true, nil
+}
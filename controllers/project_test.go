@@ -0,0 +1,260 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// newProjectTestMetalClient returns a Metal client whose requests are recorded and answered with the
+// given JSON responses by method and path. The projects created are appended to created.
+func newProjectTestMetalClient(
+	t *testing.T,
+	responses map[string]interface{},
+	created *[]metal.ProjectCreateRequest,
+) (*metal.Client, *metalRequests) {
+	t.Helper()
+
+	requests := new(metalRequests)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.add(r)
+
+		if r.Method == http.MethodPost {
+			var req metal.ProjectCreateRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected project creation: %v", err)
+			}
+
+			*created = append(*created, req)
+		}
+
+		response, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	metalClient, err := metal.NewClient(server.URL, "token", server.Client())
+	if err != nil {
+		t.Fatalf("failed to create Metal client: %v", err)
+	}
+
+	return metalClient, requests
+}
+
+func newProjectTestCluster() *clusterv1.Cluster {
+	return &clusterv1.Cluster{ //nolint:exhaustivestruct
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"}, //nolint:exhaustivestruct
+	}
+}
+
+func TestReconcileProject(t *testing.T) {
+	t.Parallel()
+
+	owned := metal.Project{ID: "owned", Name: "ns-cluster", CustomData: metal.ProjectOwnerCustomData("ns", "cluster")}
+	otherCluster := metal.Project{ID: "other", Name: "ns-cluster", CustomData: metal.ProjectOwnerCustomData("ns", "other")}
+	unmarked := metal.Project{ID: "unmarked", Name: "ns-cluster"} //nolint:exhaustivestruct
+
+	tests := []struct {
+		name          string
+		projectID     string
+		projects      []metal.Project
+		wantProjectID string
+		wantCreated   []metal.ProjectCreateRequest
+		wantErr       error
+	}{
+		{
+			name:          "project already recorded",
+			projectID:     "recorded",
+			wantProjectID: "recorded",
+		},
+		{
+			name:          "no project",
+			projects:      []metal.Project{{ID: "unrelated", Name: "ns-other"}}, //nolint:exhaustivestruct
+			wantProjectID: "created",
+			wantCreated: []metal.ProjectCreateRequest{
+				{Name: "ns-cluster", CustomData: metal.ProjectOwnerCustomData("ns", "cluster")},
+			},
+		},
+		{
+			name:          "project created for the cluster",
+			projects:      []metal.Project{owned},
+			wantProjectID: "owned",
+		},
+		{
+			name:     "project created for another cluster",
+			projects: []metal.Project{otherCluster},
+			wantErr:  ErrProjectNameConflict,
+		},
+		{
+			name:     "project not created for a cluster",
+			projects: []metal.Project{unmarked},
+			wantErr:  ErrProjectNameConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var created []metal.ProjectCreateRequest
+
+			metalClient, _ := newProjectTestMetalClient(t, map[string]interface{}{
+				"GET /organizations/org/projects":  map[string]interface{}{"projects": tt.projects},
+				"POST /organizations/org/projects": metal.Project{ID: "created", Name: "ns-cluster"}, //nolint:exhaustivestruct
+			}, &created)
+			r := &EquinixMetalClusterReconciler{ //nolint:exhaustivestruct
+				MetalClient: metalClient,
+				Recorder:    record.NewFakeRecorder(10),
+			}
+			equinixMetalCluster := &infrav1.EquinixMetalCluster{ //nolint:exhaustivestruct
+				Spec: infrav1.EquinixMetalClusterSpec{ //nolint:exhaustivestruct
+					ProjectID: tt.projectID,
+					Project:   &infrav1.ManagedProject{OrganizationID: "org"}, //nolint:exhaustivestruct
+				},
+			}
+
+			err := r.reconcileProject(context.Background(), newProjectTestCluster(), equinixMetalCluster)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("reconcileProject() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := equinixMetalCluster.Spec.ProjectID; got != tt.wantProjectID {
+				t.Errorf("reconcileProject() project ID = %q, want %q", got, tt.wantProjectID)
+			}
+
+			if !reflect.DeepEqual(created, tt.wantCreated) {
+				t.Errorf("reconcileProject() created %+v, want %+v", created, tt.wantCreated)
+			}
+
+			wantReady := corev1.ConditionTrue
+			if tt.wantErr != nil {
+				wantReady = corev1.ConditionFalse
+			}
+
+			if got := conditions.Get(equinixMetalCluster, infrav1.ProjectReadyCondition); got == nil || got.Status != wantReady {
+				t.Errorf("reconcileProject() condition = %+v, want status %s", got, wantReady)
+			}
+		})
+	}
+}
+
+func TestDeleteProject(t *testing.T) {
+	t.Parallel()
+
+	owned := metal.Project{ID: "project", Name: "ns-cluster", CustomData: metal.ProjectOwnerCustomData("ns", "cluster")}
+	unmarked := metal.Project{ID: "project", Name: "ns-cluster"} //nolint:exhaustivestruct
+
+	tests := []struct {
+		name         string
+		project      *metal.Project
+		devices      []metal.Device
+		wantDeleted  bool
+		wantRequests []string
+	}{
+		{
+			name:         "project gone",
+			wantDeleted:  true,
+			wantRequests: []string{"GET /projects/project"},
+		},
+		{
+			name:         "project not created for the cluster",
+			project:      &unmarked,
+			wantDeleted:  true,
+			wantRequests: []string{"GET /projects/project"},
+		},
+		{
+			name:         "project with devices",
+			project:      &owned,
+			devices:      []metal.Device{{ID: "device"}}, //nolint:exhaustivestruct
+			wantRequests: []string{"GET /projects/project", "GET /projects/project/devices"},
+		},
+		{
+			name:        "project without devices",
+			project:     &owned,
+			wantDeleted: true,
+			wantRequests: []string{
+				"GET /projects/project", "GET /projects/project/devices", "DELETE /projects/project",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			responses := map[string]interface{}{
+				"GET /projects/project/devices": map[string]interface{}{"devices": tt.devices},
+				"DELETE /projects/project":      map[string]interface{}{},
+			}
+			if tt.project != nil {
+				responses["GET /projects/project"] = tt.project
+			}
+
+			metalClient, requests := newProjectTestMetalClient(t, responses, nil)
+			r := &EquinixMetalClusterReconciler{ //nolint:exhaustivestruct
+				MetalClient: metalClient,
+				Recorder:    record.NewFakeRecorder(10),
+			}
+			equinixMetalCluster := &infrav1.EquinixMetalCluster{ //nolint:exhaustivestruct
+				Spec: infrav1.EquinixMetalClusterSpec{ //nolint:exhaustivestruct
+					ProjectID: "project",
+					Project:   &infrav1.ManagedProject{OrganizationID: "org"}, //nolint:exhaustivestruct
+				},
+			}
+
+			deleted, err := r.deleteProject(context.Background(), newProjectTestCluster(), equinixMetalCluster)
+			if err != nil {
+				t.Fatalf("deleteProject() error = %v", err)
+			}
+
+			if deleted != tt.wantDeleted {
+				t.Errorf("deleteProject() = %t, want %t", deleted, tt.wantDeleted)
+			}
+
+			if got := requests.list(); !reflect.DeepEqual(got, tt.wantRequests) {
+				t.Errorf("deleteProject() sent %v, want %v", got, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// provisioningTimeout returns how long the device of the machine can take to become active,
+// or 0 if there is no timeout.
+func (r *EquinixMetalMachineReconciler) provisioningTimeout(scope *machineScope) time.Duration {
+	if timeout := scope.equinixMetalMachine.Spec.ProvisioningTimeout; timeout != nil {
+		return timeout.Duration
+	}
+
+	return r.ProvisioningTimeout
+}
+
+// provisioningTimedOut returns true if the device has been queued or provisioning for longer than
+// the provisioning timeout of the machine.
+func (r *EquinixMetalMachineReconciler) provisioningTimedOut(scope *machineScope, device *metal.Device) bool {
+	if device.State != metal.DeviceStateQueued && device.State != metal.DeviceStateProvisioning {
+		return false
+	}
+
+	timeout := r.provisioningTimeout(scope)
+
+	return timeout > 0 && !device.CreatedAt.IsZero() && time.Since(device.CreatedAt) > timeout
+}
+
+// retryProvisioning deletes a device that failed to provision so that a new one is created, as long
+// as the machine has retries left. Otherwise the failure is recorded as a terminal failure.
+func (r *EquinixMetalMachineReconciler) retryProvisioning(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+	message string,
+) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	if int(equinixMetalMachine.Status.ProvisioningRetries) >= r.ProvisioningMaxRetries {
+		r.setDeviceFailure(ctx, scope, infrav1.InstanceProvisionFailedReason, capierrors.CreateMachineError, message)
+
+		return ctrl.Result{}, nil
+	}
+
+	// Devices that failed to provision are not protected from deletion, they never became usable.
+	if device.Locked {
+		if err := r.setDeviceLock(ctx, scope, device, false); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.MetalClient.DeleteDevice(ctx, device.ID); err != nil && !metal.IsNotFound(err) {
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "FailedDelete",
+			"Failed to delete device %s: %v", device.ID, err)
+
+		return ctrl.Result{}, fmt.Errorf("failed to delete device: %w", err)
+	}
+
+	r.invalidateDevice(scope, device)
+
+	equinixMetalMachine.Status.ProvisioningRetries++
+	equinixMetalMachine.Spec.ProviderID = nil
+	equinixMetalMachine.Status.InstanceStatus = nil
+	equinixMetalMachine.Status.Addresses = nil
+
+	log.Info("Deleted device that failed to provision, retrying", "device", device.ID, "reason", message,
+		"retry", equinixMetalMachine.Status.ProvisioningRetries)
+	conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+		infrav1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityWarning,
+		"%s, retrying (%d/%d)", message, equinixMetalMachine.Status.ProvisioningRetries, r.ProvisioningMaxRetries)
+	r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, infrav1.InstanceProvisionFailedReason,
+		"%s, deleted it to retry (%d/%d)", message, equinixMetalMachine.Status.ProvisioningRetries, r.ProvisioningMaxRetries)
+
+	return ctrl.Result{RequeueAfter: deviceProvisioningRequeueInterval}, nil
+}
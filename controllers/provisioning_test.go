@@ -0,0 +1,201 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+func TestProvisioningTimedOut(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		state       string
+		age         time.Duration
+		timeout     time.Duration
+		specTimeout *metav1.Duration
+		want        bool
+	}{
+		{
+			name:    "provisioning within the timeout",
+			state:   metal.DeviceStateProvisioning,
+			age:     time.Minute,
+			timeout: time.Hour,
+		},
+		{
+			name:    "provisioning past the timeout",
+			state:   metal.DeviceStateProvisioning,
+			age:     2 * time.Hour,
+			timeout: time.Hour,
+			want:    true,
+		},
+		{
+			name:    "queued past the timeout",
+			state:   metal.DeviceStateQueued,
+			age:     2 * time.Hour,
+			timeout: time.Hour,
+			want:    true,
+		},
+		{
+			name:    "active past the timeout",
+			state:   metal.DeviceStateActive,
+			age:     2 * time.Hour,
+			timeout: time.Hour,
+		},
+		{
+			name:  "no timeout",
+			state: metal.DeviceStateProvisioning,
+			age:   2 * time.Hour,
+		},
+		{
+			name:        "past the timeout of the machine",
+			state:       metal.DeviceStateProvisioning,
+			age:         2 * time.Hour,
+			timeout:     3 * time.Hour,
+			specTimeout: &metav1.Duration{Duration: time.Hour},
+			want:        true,
+		},
+		{
+			name:        "timeout disabled on the machine",
+			state:       metal.DeviceStateProvisioning,
+			age:         2 * time.Hour,
+			timeout:     time.Hour,
+			specTimeout: &metav1.Duration{Duration: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &EquinixMetalMachineReconciler{ProvisioningTimeout: tt.timeout} //nolint:exhaustivestruct
+			scope := &machineScope{                                              //nolint:exhaustivestruct
+				equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+					Spec: infrav1.EquinixMetalMachineSpec{ProvisioningTimeout: tt.specTimeout}, //nolint:exhaustivestruct
+				},
+			}
+			device := &metal.Device{State: tt.state, CreatedAt: time.Now().Add(-tt.age)} //nolint:exhaustivestruct
+
+			if got := r.provisioningTimedOut(scope, device); got != tt.want {
+				t.Errorf("provisioningTimedOut() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryProvisioning(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		retries      int32
+		locked       bool
+		wantRetries  int32
+		wantFailed   bool
+		wantRequests []string
+	}{
+		{
+			name:         "first retry",
+			wantRetries:  1,
+			wantRequests: []string{"DELETE /devices/device"},
+		},
+		{
+			name:         "last retry",
+			retries:      1,
+			wantRetries:  2,
+			wantRequests: []string{"DELETE /devices/device"},
+		},
+		{
+			name:         "locked device",
+			locked:       true,
+			wantRetries:  1,
+			wantRequests: []string{"PUT /devices/device", "DELETE /devices/device"},
+		},
+		{
+			name:         "no retries left",
+			retries:      2,
+			wantRetries:  2,
+			wantFailed:   true,
+			wantRequests: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			providerID := metal.ProviderIDPrefix + "device"
+			metalClient, requests := newRecordingMetalClient(t)
+			r := &EquinixMetalMachineReconciler{ //nolint:exhaustivestruct
+				MetalClient:            metalClient,
+				Recorder:               record.NewFakeRecorder(10),
+				ProvisioningMaxRetries: 2,
+			}
+			scope := &machineScope{ //nolint:exhaustivestruct
+				cluster:             &clusterv1.Cluster{},           //nolint:exhaustivestruct
+				equinixMetalCluster: &infrav1.EquinixMetalCluster{}, //nolint:exhaustivestruct
+				equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+					Spec:   infrav1.EquinixMetalMachineSpec{ProviderID: &providerID},           //nolint:exhaustivestruct
+					Status: infrav1.EquinixMetalMachineStatus{ProvisioningRetries: tt.retries}, //nolint:exhaustivestruct
+				},
+			}
+			device := &metal.Device{ID: "device", Locked: tt.locked} //nolint:exhaustivestruct
+
+			if _, err := r.retryProvisioning(context.Background(), scope, device, "device failed"); err != nil {
+				t.Fatalf("retryProvisioning() error = %v", err)
+			}
+
+			if got := requests.list(); !reflect.DeepEqual(got, tt.wantRequests) {
+				t.Errorf("retryProvisioning() sent %v, want %v", got, tt.wantRequests)
+			}
+
+			equinixMetalMachine := scope.equinixMetalMachine
+			if got := equinixMetalMachine.Status.ProvisioningRetries; got != tt.wantRetries {
+				t.Errorf("retryProvisioning() retries = %d, want %d", got, tt.wantRetries)
+			}
+
+			failureReason := equinixMetalMachine.Status.FailureReason
+			if failed := failureReason != nil; failed != tt.wantFailed {
+				t.Errorf("retryProvisioning() failed the machine = %t, want %t", failed, tt.wantFailed)
+			}
+
+			if failureReason != nil && *failureReason != capierrors.CreateMachineError {
+				t.Errorf("retryProvisioning() failure reason = %s, want %s", *failureReason, capierrors.CreateMachineError)
+			}
+
+			if cleared := equinixMetalMachine.Spec.ProviderID == nil; cleared == tt.wantFailed {
+				t.Errorf("retryProvisioning() cleared the provider ID = %t, want %t", cleared, !tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// reconcileReinstall reinstalls the device of the machine in place when requested through the
+// ReinstallRequestedAnnotation, so that a corrupted device can be re-imaged without releasing its
+// hardware reservation. The userdata of the device is refreshed from the current bootstrap data
+// before the reinstallation. The join credentials of bootstrap data usually expire shortly after the
+// device is provisioned, so the request is rejected while the bootstrap data is the one the device was
+// last provisioned with. It returns true if a reinstallation was started.
+func (r *EquinixMetalMachineReconciler) reconcileReinstall( //nolint:cyclop,funlen
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	value, ok := equinixMetalMachine.Annotations[infrav1.ReinstallRequestedAnnotation]
+	if !ok {
+		// A blocked request is withdrawn by removing the annotation.
+		if conditions.GetReason(equinixMetalMachine, infrav1.ReinstallSucceededCondition) == infrav1.ReinstallBlockedReason {
+			conditions.Delete(equinixMetalMachine, infrav1.ReinstallSucceededCondition)
+		}
+
+		return false, nil
+	}
+
+	requestedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Info("Ignoring invalid reinstall request", "annotation", infrav1.ReinstallRequestedAnnotation, "value", value)
+
+		return false, nil
+	}
+
+	if last := equinixMetalMachine.Status.LastReinstall; last != nil && !requestedAt.After(last.RequestedAt.Time) {
+		return false, nil
+	}
+
+	if device.State != metal.DeviceStateActive && device.State != metal.DeviceStateInactive {
+		log.Info("Waiting for the device to be provisioned to reinstall it")
+
+		return false, nil
+	}
+
+	bootstrapData, err := r.getBootstrapData(ctx, scope)
+	if err != nil {
+		return false, err
+	}
+
+	// The request is evaluated again on every reconciliation, so that it is carried out once the bootstrap
+	// data is updated.
+	if hash := equinixMetalMachine.Status.BootstrapDataHash; hash != "" && hash == bootstrapDataHash(bootstrapData) {
+		if conditions.GetReason(equinixMetalMachine, infrav1.ReinstallSucceededCondition) != infrav1.ReinstallBlockedReason {
+			log.Info("Rejecting reinstall request with the bootstrap data the device was provisioned with")
+			r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "BlockedReinstall",
+				"Not reinstalling device %s with the bootstrap data it was provisioned with", device.ID)
+		}
+
+		conditions.MarkFalse(equinixMetalMachine, infrav1.ReinstallSucceededCondition,
+			infrav1.ReinstallBlockedReason, clusterv1.ConditionSeverityWarning,
+			"the bootstrap data has not changed since the device was provisioned and its join credentials have "+
+				"likely expired: update the bootstrap data secret %s to reinstall the device",
+			*scope.machine.Spec.Bootstrap.DataSecretName)
+
+		return false, nil
+	}
+
+	action := &metal.DeviceActionRequest{ //nolint:exhaustivestruct
+		Type:            metal.DeviceActionReinstall,
+		OperatingSystem: equinixMetalMachine.Spec.OS,
+	}
+
+	if opts := equinixMetalMachine.Spec.Reinstall; opts != nil {
+		if opts.OS != "" {
+			action.OperatingSystem = opts.OS
+		}
+
+		action.PreserveData = opts.PreserveData
+		action.DeprovisionFast = opts.DeprovisionFast
+	}
+
+	update, err := reinstallUserData(scope, action.OperatingSystem, bootstrapData)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = r.MetalClient.UpdateDevice(ctx, device.ID, update); err == nil {
+		err = r.MetalClient.PerformDeviceAction(ctx, device.ID, action)
+	}
+
+	if _, retry := metal.RetryAfter(err); retry {
+		return false, err
+	}
+
+	// The reinstallation is recorded even when it failed, so that it is not repeated on requeue.
+	equinixMetalMachine.Status.LastReinstall = &infrav1.ReinstallStatus{
+		OS:          action.OperatingSystem,
+		RequestedAt: metav1.NewTime(requestedAt),
+		Time:        metav1.Now(),
+	}
+
+	r.invalidateDevice(scope, device)
+
+	if err != nil {
+		log.Error(err, "Failed to reinstall device", "device", device.ID)
+		conditions.MarkFalse(equinixMetalMachine, infrav1.ReinstallSucceededCondition,
+			infrav1.ReinstallFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "FailedReinstall",
+			"Failed to reinstall device %s: %v", device.ID, err)
+
+		return false, nil
+	}
+
+	log.Info("Reinstalling device", "device", device.ID, "os", action.OperatingSystem)
+	equinixMetalMachine.Status.Ready = false
+	equinixMetalMachine.Status.BootstrapDataHash = bootstrapDataHash(bootstrapData)
+	conditions.MarkTrue(equinixMetalMachine, infrav1.ReinstallSucceededCondition)
+	conditions.MarkFalse(equinixMetalMachine, infrav1.DeviceReadyCondition,
+		infrav1.InstanceReinstallStartedReason, clusterv1.ConditionSeverityInfo, "")
+	r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeNormal, "SuccessfulReinstall",
+		"Reinstalling device %s with %s", device.ID, action.OperatingSystem)
+
+	return true, nil
+}
+
+// reinstallUserData returns the update setting the userdata of the device from the current bootstrap data.
+func reinstallUserData(
+	scope *machineScope,
+	operatingSystem string,
+	bootstrapData string,
+) (*metal.DeviceUpdateRequest, error) {
+	req := &metal.DeviceCreateRequest{ //nolint:exhaustivestruct
+		OperatingSystem: operatingSystem,
+		UserData:        bootstrapData,
+	}
+
+	if err := applyIPXE(req, scope, bootstrapData); err != nil {
+		return nil, fmt.Errorf("invalid iPXE configuration: %w", err)
+	}
+
+	update := &metal.DeviceUpdateRequest{ //nolint:exhaustivestruct
+		UserData:   &req.UserData,
+		CustomData: req.CustomData,
+	}
+
+	if req.IPXEScriptURL != "" {
+		update.IPXEScriptURL = &req.IPXEScriptURL
+	}
+
+	return update, nil
+}
+
+// bootstrapDataHash returns the hash recorded for the bootstrap data a device is provisioned with.
+func bootstrapDataHash(bootstrapData string) string {
+	sum := sha256.Sum256([]byte(bootstrapData))
+
+	return hex.EncodeToString(sum[:])
+}
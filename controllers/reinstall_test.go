@@ -0,0 +1,202 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// metalRequests records the requests sent to a test Metal API.
+type metalRequests struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (m *metalRequests) add(r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, r.Method+" "+r.URL.Path)
+}
+
+func (m *metalRequests) list() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string{}, m.requests...)
+}
+
+// newRecordingMetalClient returns a Metal client whose requests are recorded and answered with an
+// empty JSON object.
+func newRecordingMetalClient(t *testing.T) (*metal.Client, *metalRequests) {
+	t.Helper()
+
+	requests := new(metalRequests)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.add(r)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+
+	metalClient, err := metal.NewClient(server.URL, "token", server.Client())
+	if err != nil {
+		t.Fatalf("failed to create Metal client: %v", err)
+	}
+
+	return metalClient, requests
+}
+
+func TestReconcileReinstall(t *testing.T) {
+	t.Parallel()
+
+	requestedAt := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		annotation    string
+		lastReinstall *infrav1.ReinstallStatus
+		hash          string
+		want          bool
+		wantRequests  []string
+		wantReason    string
+	}{
+		{
+			name:         "no request",
+			wantRequests: []string{},
+		},
+		{
+			name:       "already reinstalled",
+			annotation: requestedAt.Format(time.RFC3339),
+			lastReinstall: &infrav1.ReinstallStatus{ //nolint:exhaustivestruct
+				RequestedAt: metav1.NewTime(requestedAt),
+			},
+			wantRequests: []string{},
+		},
+		{
+			name:         "same bootstrap data",
+			annotation:   requestedAt.Format(time.RFC3339),
+			hash:         bootstrapDataHash("old"),
+			wantRequests: []string{},
+			wantReason:   infrav1.ReinstallBlockedReason,
+		},
+		{
+			name:         "new bootstrap data",
+			annotation:   requestedAt.Format(time.RFC3339),
+			hash:         bootstrapDataHash("previous"),
+			want:         true,
+			wantRequests: []string{"PUT /devices/device", "POST /devices/device/actions"},
+		},
+		{
+			name:         "unknown bootstrap data",
+			annotation:   requestedAt.Format(time.RFC3339),
+			want:         true,
+			wantRequests: []string{"PUT /devices/device", "POST /devices/device/actions"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			secretName := "bootstrap"
+			secret := &corev1.Secret{ //nolint:exhaustivestruct
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: secretName}, //nolint:exhaustivestruct
+				Data:       map[string][]byte{"value": []byte("old")},
+			}
+
+			metalClient, requests := newRecordingMetalClient(t)
+			r := &EquinixMetalMachineReconciler{ //nolint:exhaustivestruct
+				Client:      newTestClient(t, secret),
+				MetalClient: metalClient,
+				Recorder:    record.NewFakeRecorder(10),
+			}
+
+			equinixMetalMachine := &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "machine"}, //nolint:exhaustivestruct
+				Spec:       infrav1.EquinixMetalMachineSpec{OS: "ubuntu_20_04"}, //nolint:exhaustivestruct
+				Status: infrav1.EquinixMetalMachineStatus{ //nolint:exhaustivestruct
+					LastReinstall:     tt.lastReinstall,
+					BootstrapDataHash: tt.hash,
+				},
+			}
+			if tt.annotation != "" {
+				equinixMetalMachine.Annotations = map[string]string{infrav1.ReinstallRequestedAnnotation: tt.annotation}
+			}
+
+			conditions.MarkTrue(equinixMetalMachine, infrav1.DeviceReadyCondition)
+
+			scope := &machineScope{ //nolint:exhaustivestruct
+				cluster: &clusterv1.Cluster{}, //nolint:exhaustivestruct
+				machine: &clusterv1.Machine{ //nolint:exhaustivestruct
+					ObjectMeta: metav1.ObjectMeta{Namespace: "ns"}, //nolint:exhaustivestruct
+					Spec: clusterv1.MachineSpec{ //nolint:exhaustivestruct
+						Bootstrap: clusterv1.Bootstrap{DataSecretName: &secretName}, //nolint:exhaustivestruct
+					},
+				},
+				equinixMetalCluster: &infrav1.EquinixMetalCluster{}, //nolint:exhaustivestruct
+				equinixMetalMachine: equinixMetalMachine,
+			}
+			device := &metal.Device{ID: "device", State: metal.DeviceStateActive} //nolint:exhaustivestruct
+
+			got, err := r.reconcileReinstall(context.Background(), scope, device)
+			if err != nil {
+				t.Fatalf("reconcileReinstall() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("reconcileReinstall() = %v, want %v", got, tt.want)
+			}
+
+			if got := requests.list(); !reflect.DeepEqual(got, tt.wantRequests) {
+				t.Errorf("requests = %v, want %v", got, tt.wantRequests)
+			}
+
+			reason := conditions.GetReason(equinixMetalMachine, infrav1.ReinstallSucceededCondition)
+			if reason != tt.wantReason {
+				t.Errorf("ReinstallSucceeded reason = %q, want %q", reason, tt.wantReason)
+			}
+
+			if !tt.want && !conditions.IsTrue(equinixMetalMachine, infrav1.DeviceReadyCondition) {
+				t.Errorf("DeviceReady changed without a reinstallation")
+			}
+
+			if tt.want && equinixMetalMachine.Status.BootstrapDataHash != bootstrapDataHash("old") {
+				t.Errorf("BootstrapDataHash was not updated to the hash of the reinstalled bootstrap data")
+			}
+		})
+	}
+}
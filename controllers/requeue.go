@@ -0,0 +1,52 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// metalAPIUnavailableRequeueInterval is how long to wait before reconciling again when the
+// Equinix Metal API throttled the requests or was unavailable, and did not say for how long.
+const metalAPIUnavailableRequeueInterval = time.Minute
+
+// requeueOnMetalAPIUnavailable turns the errors caused by the Equinix Metal API throttling
+// requests or being unavailable into a delayed requeue, so the reconcilers do not add to the
+// load with the short initial backoff of the controller work queue.
+func requeueOnMetalAPIUnavailable(ctx context.Context, result ctrl.Result, err error) (ctrl.Result, error) {
+	if err == nil {
+		return result, nil
+	}
+
+	retryAfter, ok := metal.RetryAfter(err)
+	if !ok {
+		return result, err
+	}
+
+	if retryAfter < metalAPIUnavailableRequeueInterval {
+		retryAfter = metalAPIUnavailableRequeueInterval
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Equinix Metal API is unavailable, requeuing", "after", retryAfter, "reason", err.Error())
+
+	return ctrl.Result{RequeueAfter: retryAfter}, nil
+}
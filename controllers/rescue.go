@@ -0,0 +1,177 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// reconcileRescueMode boots the device of the machine into the rescue OS while the RescueAnnotation is
+// present, and back into its installed OS once it is removed. MachineHealthCheck remediation of the
+// Machine is suppressed while the device is in rescue mode. It returns true if the device was rebooted.
+func (r *EquinixMetalMachineReconciler) reconcileRescueMode(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) (bool, error) {
+	equinixMetalMachine := scope.equinixMetalMachine
+	_, requested := equinixMetalMachine.Annotations[infrav1.RescueAnnotation]
+
+	switch {
+	case requested && conditions.Has(equinixMetalMachine, infrav1.RescueModeCondition):
+		// Already in rescue mode, or failed to enter it until the annotation is removed and added again.
+		return false, nil
+	case requested:
+		return r.enterRescueMode(ctx, scope, device)
+	case conditions.IsTrue(equinixMetalMachine, infrav1.RescueModeCondition):
+		return r.exitRescueMode(ctx, scope, device)
+	case conditions.Has(equinixMetalMachine, infrav1.RescueModeCondition):
+		if err := r.setSkipRemediation(ctx, scope, false); err != nil {
+			return false, err
+		}
+
+		conditions.Delete(equinixMetalMachine, infrav1.RescueModeCondition)
+	}
+
+	return false, nil
+}
+
+func (r *EquinixMetalMachineReconciler) enterRescueMode(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	if device.State != metal.DeviceStateActive && device.State != metal.DeviceStateInactive {
+		log.Info("Waiting for the device to be provisioned to enter rescue mode")
+
+		return false, nil
+	}
+
+	// Remediation is suppressed before rebooting, so that the Node going down does not get the Machine deleted.
+	if err := r.setSkipRemediation(ctx, scope, true); err != nil {
+		return false, err
+	}
+
+	err := r.MetalClient.PerformDeviceAction(ctx, device.ID, &metal.DeviceActionRequest{ //nolint:exhaustivestruct
+		Type: metal.DeviceActionRescue,
+	})
+	if _, retry := metal.RetryAfter(err); retry {
+		return false, err
+	}
+
+	if err != nil {
+		log.Error(err, "Failed to enter rescue mode", "device", device.ID)
+		conditions.MarkFalse(equinixMetalMachine, infrav1.RescueModeCondition,
+			infrav1.RescueModeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "FailedRescueMode",
+			"Failed to boot device %s into rescue mode: %v", device.ID, err)
+
+		return false, nil
+	}
+
+	log.Info("Booting device into rescue mode", "device", device.ID)
+	conditions.MarkTrue(equinixMetalMachine, infrav1.RescueModeCondition)
+	r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeNormal, "RescueMode",
+		"Booting device %s into rescue mode", device.ID)
+	r.invalidateDevice(scope, device)
+
+	return true, nil
+}
+
+func (r *EquinixMetalMachineReconciler) exitRescueMode(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	if err := r.MetalClient.PerformDeviceAction(ctx, device.ID, &metal.DeviceActionRequest{ //nolint:exhaustivestruct
+		Type: metal.DeviceActionReboot,
+	}); err != nil {
+		r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeWarning, "FailedRescueMode",
+			"Failed to reboot device %s out of rescue mode: %v", device.ID, err)
+
+		return false, fmt.Errorf("failed to exit rescue mode: %w", err)
+	}
+
+	log.Info("Rebooting device out of rescue mode", "device", device.ID)
+	r.Recorder.Eventf(equinixMetalMachine, corev1.EventTypeNormal, "RescueMode",
+		"Rebooting device %s out of rescue mode", device.ID)
+	r.invalidateDevice(scope, device)
+
+	if err := r.setSkipRemediation(ctx, scope, false); err != nil {
+		return true, err
+	}
+
+	conditions.Delete(equinixMetalMachine, infrav1.RescueModeCondition)
+
+	return true, nil
+}
+
+// setSkipRemediation adds or removes the annotation excluding the Machine from MachineHealthCheck remediation.
+// The annotation is only removed when it was added by rescue mode, as recorded on the status of the machine,
+// so that an annotation set by the user is kept.
+func (r *EquinixMetalMachineReconciler) setSkipRemediation(ctx context.Context, scope *machineScope, skip bool) error {
+	machine := scope.machine
+	status := &scope.equinixMetalMachine.Status
+
+	if _, ok := machine.Annotations[clusterv1.MachineSkipRemediationAnnotation]; ok == skip {
+		if !skip {
+			status.RescueSkipsRemediation = false
+		}
+
+		return nil
+	}
+
+	if !skip && !status.RescueSkipsRemediation {
+		return nil
+	}
+
+	patch := client.MergeFrom(machine.DeepCopy())
+
+	if skip {
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+
+		machine.Annotations[clusterv1.MachineSkipRemediationAnnotation] = ""
+	} else {
+		delete(machine.Annotations, clusterv1.MachineSkipRemediationAnnotation)
+	}
+
+	if err := r.Client.Patch(ctx, machine, patch); err != nil {
+		return fmt.Errorf("failed to patch Machine %s: %w", machine.Name, err)
+	}
+
+	status.RescueSkipsRemediation = skip
+
+	return nil
+}
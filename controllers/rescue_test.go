@@ -0,0 +1,200 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+// newRescueTestMachine returns a Machine, optionally excluded from remediation.
+func newRescueTestMachine(skipRemediation bool) *clusterv1.Machine {
+	machine := &clusterv1.Machine{ //nolint:exhaustivestruct
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "machine"}, //nolint:exhaustivestruct
+	}
+
+	if skipRemediation {
+		machine.Annotations = map[string]string{clusterv1.MachineSkipRemediationAnnotation: ""}
+	}
+
+	return machine
+}
+
+func TestSetSkipRemediation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                   string
+		annotated              bool
+		rescueSkipsRemediation bool
+		skip                   bool
+		wantAnnotation         bool
+		wantRescueSkips        bool
+	}{
+		{
+			name:            "skip",
+			skip:            true,
+			wantAnnotation:  true,
+			wantRescueSkips: true,
+		},
+		{
+			name:           "skip already set by the user",
+			annotated:      true,
+			skip:           true,
+			wantAnnotation: true,
+		},
+		{
+			name:                   "unskip set by rescue mode",
+			annotated:              true,
+			rescueSkipsRemediation: true,
+		},
+		{
+			name:           "unskip set by the user",
+			annotated:      true,
+			wantAnnotation: true,
+		},
+		{
+			name:                   "unskip removed by the user",
+			rescueSkipsRemediation: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			machine := newRescueTestMachine(tt.annotated)
+
+			r := &EquinixMetalMachineReconciler{Client: newTestClient(t, machine)} //nolint:exhaustivestruct
+			scope := &machineScope{                                                //nolint:exhaustivestruct
+				machine: machine,
+				equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+					Status: infrav1.EquinixMetalMachineStatus{ //nolint:exhaustivestruct
+						RescueSkipsRemediation: tt.rescueSkipsRemediation,
+					},
+				},
+			}
+
+			if err := r.setSkipRemediation(context.Background(), scope, tt.skip); err != nil {
+				t.Fatalf("setSkipRemediation() error = %v", err)
+			}
+
+			got := &clusterv1.Machine{} //nolint:exhaustivestruct
+			if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(machine), got); err != nil {
+				t.Fatalf("failed to get Machine: %v", err)
+			}
+
+			if _, ok := got.Annotations[clusterv1.MachineSkipRemediationAnnotation]; ok != tt.wantAnnotation {
+				t.Errorf("setSkipRemediation() left skip remediation annotation = %t, want %t", ok, tt.wantAnnotation)
+			}
+
+			if rescueSkips := scope.equinixMetalMachine.Status.RescueSkipsRemediation; rescueSkips != tt.wantRescueSkips {
+				t.Errorf("setSkipRemediation() recorded rescue skips remediation = %t, want %t",
+					rescueSkips, tt.wantRescueSkips)
+			}
+		})
+	}
+}
+
+func TestReconcileRescueMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                 string
+		userSkipsRemediation bool
+	}{
+		{
+			name: "remediation enabled",
+		},
+		{
+			name:                 "remediation skipped by the user",
+			userSkipsRemediation: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			machine := newRescueTestMachine(tt.userSkipsRemediation)
+			metalClient, requests := newRecordingMetalClient(t)
+			r := &EquinixMetalMachineReconciler{ //nolint:exhaustivestruct
+				Client:      newTestClient(t, machine),
+				MetalClient: metalClient,
+				Recorder:    record.NewFakeRecorder(10),
+			}
+			scope := &machineScope{ //nolint:exhaustivestruct
+				cluster:             &clusterv1.Cluster{},           //nolint:exhaustivestruct
+				equinixMetalCluster: &infrav1.EquinixMetalCluster{}, //nolint:exhaustivestruct
+				machine:             machine,
+				equinixMetalMachine: &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+					ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustivestruct
+						Annotations: map[string]string{infrav1.RescueAnnotation: ""},
+					},
+				},
+			}
+			device := &metal.Device{ID: "device", State: metal.DeviceStateActive} //nolint:exhaustivestruct
+
+			if rebooted, err := r.reconcileRescueMode(ctx, scope, device); err != nil || !rebooted {
+				t.Fatalf("reconcileRescueMode() = %t, %v, want the device to enter rescue mode", rebooted, err)
+			}
+
+			if _, ok := machine.Annotations[clusterv1.MachineSkipRemediationAnnotation]; !ok {
+				t.Errorf("reconcileRescueMode() did not skip remediation in rescue mode")
+			}
+
+			if rebooted, err := r.reconcileRescueMode(ctx, scope, device); err != nil || rebooted {
+				t.Fatalf("reconcileRescueMode() = %t, %v, want the device to stay in rescue mode", rebooted, err)
+			}
+
+			delete(scope.equinixMetalMachine.Annotations, infrav1.RescueAnnotation)
+
+			if rebooted, err := r.reconcileRescueMode(ctx, scope, device); err != nil || !rebooted {
+				t.Fatalf("reconcileRescueMode() = %t, %v, want the device to exit rescue mode", rebooted, err)
+			}
+
+			if conditions.Has(scope.equinixMetalMachine, infrav1.RescueModeCondition) {
+				t.Errorf("reconcileRescueMode() kept the rescue mode condition")
+			}
+
+			if _, ok := machine.Annotations[clusterv1.MachineSkipRemediationAnnotation]; ok != tt.userSkipsRemediation {
+				t.Errorf("reconcileRescueMode() left skip remediation annotation = %t, want %t",
+					ok, tt.userSkipsRemediation)
+			}
+
+			want := []string{"POST /devices/device/actions", "POST /devices/device/actions"}
+			if got := requests.list(); !reflect.DeepEqual(got, want) {
+				t.Errorf("reconcileRescueMode() sent %v, want %v", got, want)
+			}
+		})
+	}
+}
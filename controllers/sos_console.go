@@ -0,0 +1,136 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/feature"
+)
+
+// rootPasswordSecretKey is the key of the Secret holding the root password of a device.
+const rootPasswordSecretKey = "password"
+
+// reconcileSOSConsole records how to connect to the serial over SSH console of the device on the
+// machine status, and writes the root password of the device to a Secret owned by the machine
+// while the Equinix Metal API still returns it. The status and the Secret are removed when the
+// SOSConsole feature gate is disabled.
+func (r *EquinixMetalMachineReconciler) reconcileSOSConsole(
+	ctx context.Context,
+	scope *machineScope,
+	device *metal.Device,
+) error {
+	equinixMetalMachine := scope.equinixMetalMachine
+
+	if !feature.Enabled(feature.SOSConsole) {
+		if err := r.deleteRootPasswordSecret(ctx, equinixMetalMachine); err != nil {
+			return err
+		}
+
+		equinixMetalMachine.Status.SOSConsole = nil
+
+		return nil
+	}
+
+	host := device.SOSConsoleHost()
+	if host == "" {
+		return nil
+	}
+
+	status := equinixMetalMachine.Status.SOSConsole
+	if status == nil {
+		status = new(infrav1.SOSConsoleStatus)
+	}
+
+	status.Host = host
+	status.Username = device.ID
+	equinixMetalMachine.Status.SOSConsole = status
+
+	if device.RootPassword == "" {
+		return nil
+	}
+
+	secret := &corev1.Secret{ //nolint:exhaustivestruct
+		ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustivestruct
+			Name:      rootPasswordSecretName(equinixMetalMachine),
+			Namespace: equinixMetalMachine.Namespace,
+		},
+	}
+
+	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, secret, func() error {
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+
+		secret.Labels[clusterv1.ClusterLabelName] = scope.cluster.Name
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = map[string][]byte{rootPasswordSecretKey: []byte(device.RootPassword)}
+
+		return controllerutil.SetControllerReference(equinixMetalMachine, secret, r.Client.Scheme())
+	}); err != nil {
+		return fmt.Errorf("failed to write root password secret: %w", err)
+	}
+
+	status.RootPasswordSecretRef = &corev1.LocalObjectReference{Name: secret.Name}
+
+	return nil
+}
+
+// deleteRootPasswordSecret deletes the Secret holding the root password of the device of the machine, if any.
+func (r *EquinixMetalMachineReconciler) deleteRootPasswordSecret(
+	ctx context.Context,
+	equinixMetalMachine *infrav1.EquinixMetalMachine,
+) error {
+	secret := new(corev1.Secret)
+	key := client.ObjectKey{Namespace: equinixMetalMachine.Namespace, Name: rootPasswordSecretName(equinixMetalMachine)}
+
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to get root password secret: %w", err)
+	}
+
+	if !metav1.IsControlledBy(secret, equinixMetalMachine) {
+		return nil
+	}
+
+	if err := r.Client.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete root password secret: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Deleted root password secret", "secret", secret.Name)
+
+	return nil
+}
+
+// rootPasswordSecretName returns the name of the Secret holding the root password of the device of the machine.
+func rootPasswordSecretName(equinixMetalMachine *infrav1.EquinixMetalMachine) string {
+	return equinixMetalMachine.Name + "-root-password"
+}
@@ -0,0 +1,397 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// sshKeyPairSecretSuffix is appended to the name of an EquinixMetalCluster to name the Secret of its
+	// generated SSH keypair.
+	sshKeyPairSecretSuffix = "-ssh-keypair"
+
+	// sshPublicKeySecretKey is the key of the public key in the Secret of a generated SSH keypair.
+	sshPublicKeySecretKey = "ssh-publickey"
+
+	// sshKeyBits is the size of the generated RSA SSH keys.
+	sshKeyBits = 4096
+)
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// reconcileSSHKeys registers the SSH keys of the cluster in its project, removes the keys of the cluster
+// that are no longer wanted, and records the IDs of the keys to attach to its devices.
+func (r *EquinixMetalClusterReconciler) reconcileSSHKeys(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) error {
+	if equinixMetalCluster.Spec.SSHKeys == nil && len(equinixMetalCluster.Status.SSHKeyIDs) == 0 {
+		conditions.Delete(equinixMetalCluster, infrav1.SSHKeysReadyCondition)
+
+		return nil
+	}
+
+	desired, err := r.desiredSSHKeys(ctx, cluster, equinixMetalCluster)
+	if err != nil {
+		conditions.MarkFalse(equinixMetalCluster, infrav1.SSHKeysReadyCondition,
+			infrav1.SSHKeysFailedReason, clusterv1.ConditionSeverityError, err.Error())
+
+		return err
+	}
+
+	ids, err := r.syncSSHKeys(ctx, cluster, equinixMetalCluster, desired)
+	if err != nil {
+		conditions.MarkFalse(equinixMetalCluster, infrav1.SSHKeysReadyCondition,
+			infrav1.SSHKeysFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+
+		return err
+	}
+
+	equinixMetalCluster.Status.SSHKeyIDs = ids
+
+	if equinixMetalCluster.Spec.SSHKeys == nil {
+		conditions.Delete(equinixMetalCluster, infrav1.SSHKeysReadyCondition)
+	} else {
+		conditions.MarkTrue(equinixMetalCluster, infrav1.SSHKeysReadyCondition)
+	}
+
+	return nil
+}
+
+// syncSSHKeys makes the SSH keys of the project tagged for the cluster match the desired keys, indexed by
+// their normalized form. It returns the IDs of the keys.
+func (r *EquinixMetalClusterReconciler) syncSSHKeys(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+	desired map[string]string,
+) ([]string, error) {
+	log := ctrl.LoggerFrom(ctx)
+	projectID := equinixMetalCluster.Spec.ProjectID
+	tag := metal.ClusterTag(cluster.Namespace, cluster.Name)
+
+	existing, err := r.MetalClient.ListProjectSSHKeys(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project SSH keys: %w", err)
+	}
+
+	ids := []string{}
+	registered := map[string]bool{}
+
+	for _, key := range existing {
+		if !metal.HasTags(key.Tags, tag) {
+			continue
+		}
+
+		// Keys that can't be parsed are not wanted, and are removed.
+		normalized, _ := normalizeSSHKey(key.Key)
+		if _, ok := desired[normalized]; ok && !registered[normalized] {
+			registered[normalized] = true
+			ids = append(ids, key.ID)
+
+			continue
+		}
+
+		if err := r.MetalClient.DeleteSSHKey(ctx, key.ID); err != nil && !metal.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to remove SSH key: %w", err)
+		}
+
+		log.Info("Removed SSH key", "label", key.Label, "id", key.ID)
+		r.Recorder.Eventf(equinixMetalCluster, corev1.EventTypeNormal, "SuccessfulDeleteSSHKey",
+			"Removed SSH key %s", key.Label)
+	}
+
+	keys := make([]string, 0, len(desired))
+	for normalized := range desired {
+		keys = append(keys, normalized)
+	}
+
+	sort.Strings(keys)
+
+	for _, normalized := range keys {
+		if registered[normalized] {
+			continue
+		}
+
+		key, err := r.MetalClient.CreateProjectSSHKey(ctx, projectID, &metal.SSHKeyCreateRequest{
+			Label: desired[normalized],
+			Key:   normalized,
+			Tags:  []string{tag},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to register SSH key: %w", err)
+		}
+
+		log.Info("Registered SSH key", "label", key.Label, "id", key.ID)
+		r.Recorder.Eventf(equinixMetalCluster, corev1.EventTypeNormal, "SuccessfulCreateSSHKey",
+			"Registered SSH key %s", key.Label)
+
+		ids = append(ids, key.ID)
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// deleteSSHKeys removes the SSH keys of the cluster from its project.
+func (r *EquinixMetalClusterReconciler) deleteSSHKeys(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) error {
+	if _, err := r.syncSSHKeys(ctx, cluster, equinixMetalCluster, nil); err != nil {
+		return err
+	}
+
+	equinixMetalCluster.Status.SSHKeyIDs = nil
+
+	return nil
+}
+
+// desiredSSHKeys returns the public SSH keys of the cluster indexed by their normalized form, with the
+// label to register them with.
+func (r *EquinixMetalClusterReconciler) desiredSSHKeys(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) (map[string]string, error) {
+	desired := map[string]string{}
+
+	sources := equinixMetalCluster.Spec.SSHKeys
+	if sources == nil {
+		return desired, nil
+	}
+
+	prefix := cluster.Namespace + "/" + cluster.Name + " "
+
+	for _, ref := range sources.SecretRefs {
+		secret := new(corev1.Secret)
+		key := client.ObjectKey{Namespace: equinixMetalCluster.Namespace, Name: ref.Name}
+
+		if err := r.Client.Get(ctx, key, secret); err != nil {
+			return nil, fmt.Errorf("failed to get SSH keys Secret %s: %w", ref.Name, err)
+		}
+
+		for dataKey, value := range secret.Data {
+			if err := addSSHKeys(desired, string(value), prefix+"secret/"+ref.Name+"/"+dataKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, ref := range sources.ConfigMapRefs {
+		configMap := new(corev1.ConfigMap)
+		key := client.ObjectKey{Namespace: equinixMetalCluster.Namespace, Name: ref.Name}
+
+		if err := r.Client.Get(ctx, key, configMap); err != nil {
+			return nil, fmt.Errorf("failed to get SSH keys ConfigMap %s: %w", ref.Name, err)
+		}
+
+		for dataKey, value := range configMap.Data {
+			if err := addSSHKeys(desired, value, prefix+"configmap/"+ref.Name+"/"+dataKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if sources.Generate {
+		publicKey, err := r.ensureSSHKeyPair(ctx, cluster, equinixMetalCluster)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := addSSHKeys(desired, publicKey, prefix+"generated"); err != nil {
+			return nil, err
+		}
+	}
+
+	return desired, nil
+}
+
+// ensureSSHKeyPair returns the public key of the generated SSH keypair of the cluster, generating it
+// and storing it in a Secret owned by the EquinixMetalCluster when there is none yet.
+func (r *EquinixMetalClusterReconciler) ensureSSHKeyPair(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	equinixMetalCluster *infrav1.EquinixMetalCluster,
+) (string, error) {
+	secret := new(corev1.Secret)
+	key := client.ObjectKey{
+		Namespace: equinixMetalCluster.Namespace,
+		Name:      equinixMetalCluster.Name + sshKeyPairSecretSuffix,
+	}
+
+	err := r.Client.Get(ctx, key, secret)
+	if err == nil {
+		return string(secret.Data[sshPublicKeySecretKey]), nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get SSH keypair Secret: %w", err)
+	}
+
+	privateKey, publicKey, err := generateSSHKeyPair()
+	if err != nil {
+		return "", err
+	}
+
+	secret = &corev1.Secret{ //nolint:exhaustivestruct
+		ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustivestruct
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels:    map[string]string{clusterv1.ClusterLabelName: cluster.Name},
+		},
+		Type: corev1.SecretTypeSSHAuth,
+		Data: map[string][]byte{
+			corev1.SSHAuthPrivateKey: privateKey,
+			sshPublicKeySecretKey:    []byte(publicKey),
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(equinixMetalCluster, secret, r.Client.Scheme()); err != nil {
+		return "", fmt.Errorf("failed to set owner of SSH keypair Secret: %w", err)
+	}
+
+	if err := r.Client.Create(ctx, secret); err != nil {
+		return "", fmt.Errorf("failed to create SSH keypair Secret: %w", err)
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Generated SSH keypair", "secret", secret.Name)
+
+	return publicKey, nil
+}
+
+// secretOrConfigMapToEquinixMetalClusters maps a Secret or ConfigMap to the EquinixMetalClusters of its
+// namespace that take their SSH keys from it.
+func (r *EquinixMetalClusterReconciler) secretOrConfigMapToEquinixMetalClusters(o client.Object) []reconcile.Request {
+	clusters := new(infrav1.EquinixMetalClusterList)
+	if err := r.Client.List(context.Background(), clusters, client.InNamespace(o.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var refs func(*infrav1.SSHKeySources) []corev1.LocalObjectReference
+
+	switch o.(type) {
+	case *corev1.Secret:
+		refs = func(s *infrav1.SSHKeySources) []corev1.LocalObjectReference { return s.SecretRefs }
+	case *corev1.ConfigMap:
+		refs = func(s *infrav1.SSHKeySources) []corev1.LocalObjectReference { return s.ConfigMapRefs }
+	default:
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for i := range clusters.Items {
+		sources := clusters.Items[i].Spec.SSHKeys
+		if sources == nil {
+			continue
+		}
+
+		for _, ref := range refs(sources) {
+			if ref.Name == o.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&clusters.Items[i])})
+
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// addSSHKeys adds the public keys of an authorized_keys formatted value to keys, skipping blank lines
+// and comments. It fails on lines that are not valid public keys.
+func addSSHKeys(keys map[string]string, value, label string) error {
+	scanner := bufio.NewScanner(strings.NewReader(value))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		normalized, err := normalizeSSHKey(text)
+		if err != nil {
+			return fmt.Errorf("invalid SSH key on line %d of %s: %w", line, label, err)
+		}
+
+		if _, ok := keys[normalized]; !ok {
+			keys[normalized] = label
+		}
+	}
+
+	return nil
+}
+
+// normalizeSSHKey returns the type and data of a public key in the authorized_keys format, without its
+// options and comment.
+func normalizeSSHKey(key string) (string, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse SSH key: %w", err)
+	}
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))), nil
+}
+
+// generateSSHKeyPair returns a new RSA private key in PEM format, and its public key in the
+// authorized_keys format.
+func generateSSHKeyPair() ([]byte, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, sshKeyBits)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate SSH key: %w", err)
+	}
+
+	privateKey := pem.EncodeToMemory(&pem.Block{ //nolint:exhaustivestruct
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode SSH public key: %w", err)
+	}
+
+	return privateKey, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))), nil
+}
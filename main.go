@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"math/rand"
@@ -41,6 +42,9 @@ import (
 
 	infrav1beta1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-equinixmetal/controllers"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/budget"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/metrics"
 )
 
 const (
@@ -48,6 +52,22 @@ const (
 	defaultEquinixMetalMachineConcurrency = 10
 	defaultWebhookPort                    = 9443
 	defaultSyncPeriod                     = 10 * time.Minute
+	defaultOrphanCollectionGracePeriod    = time.Hour
+	defaultDeviceCacheInterval            = time.Minute
+	defaultCapacityCheckInterval          = 10 * time.Minute
+)
+
+var (
+	errOrphanCollectionProjectsRequired = errors.New(
+		"--orphan-collection-projects must be set when --orphan-collection-interval is set",
+	)
+	errManagerIDRequired = errors.New(
+		"--manager-id must be set when --orphan-collection-interval is set",
+	)
+	errDeviceEventsReceiverTokenRequired = errors.New(
+		"--device-events-receiver-token must be set when --device-events-receiver-addr is set",
+	)
+	errMetalAPIBurstTooLow = errors.New("--metal-api-burst must be at least 1 when --metal-api-qps is positive")
 )
 
 type config struct {
@@ -63,6 +83,19 @@ type config struct {
 	webhookPort                    int
 	webhookCertDir                 string
 	healthAddr                     string
+	orphanCollectionInterval       time.Duration
+	orphanCollectionGracePeriod    time.Duration
+	orphanCollectionDryRun         bool
+	orphanCollectionProjectIDs     []string
+	managerID                      string
+	metalAPIRateLimit              metal.RateLimitOptions
+	deviceCacheInterval            time.Duration
+	deviceEventsPollInterval       time.Duration
+	deviceEventsReceiverAddr       string
+	deviceEventsReceiverToken      string
+	provisioningTimeout            time.Duration
+	provisioningMaxRetries         int
+	capacityCheckInterval          time.Duration
 }
 
 func main() { //nolint:funlen
@@ -83,6 +116,11 @@ func main() { //nolint:funlen
 	ctrl.SetLogger(klogr.New())
 	setupLog := ctrl.Log.WithName("setup")
 
+	if err := validateConfig(config); err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+
 	if config.watchNamespace != "" {
 		setupLog.Info("Watching cluster-api objects only in namespace for reconciliation", "namespace", config.watchNamespace)
 	}
@@ -123,16 +161,36 @@ func main() { //nolint:funlen
 
 	setupLog.V(1).Info(fmt.Sprintf("feature gates: %+v\n", feature.Gates))
 
-	if err := setupControllers(ctx, mgr, config); err != nil {
+	// Every controller and webhook calls the Equinix Metal API, so the manager can't run without credentials.
+	metalClient, err := metal.NewClientFromEnv(nil, metal.WithRateLimit(config.metalAPIRateLimit))
+	if err != nil {
+		setupLog.Error(err, "unable to create Equinix Metal client")
+		os.Exit(1)
+	}
+
+	// The machine controller and the budget webhook price the same machine types.
+	plans := metal.NewPlanCache(metalClient)
+
+	if err := setupControllers(ctx, mgr, metalClient, plans, config); err != nil {
 		setupLog.Error(err, "failed to configure controllers")
 		os.Exit(1)
 	}
 
-	if err := setupWebhooks(mgr); err != nil {
+	if err := setupWebhooks(mgr, plans); err != nil {
 		setupLog.Error(err, "failed to configure controllers")
 		os.Exit(1)
 	}
 
+	if err := metrics.RegisterDeviceStateCollector(mgr.GetClient()); err != nil {
+		setupLog.Error(err, "failed to configure metrics")
+		os.Exit(1)
+	}
+
+	if err := metrics.RegisterCostCollector(mgr.GetClient()); err != nil {
+		setupLog.Error(err, "failed to configure metrics")
+		os.Exit(1)
+	}
+
 	if err := setupChecks(mgr); err != nil {
 		setupLog.Error(err, "failed to configure health and readiness checks")
 		os.Exit(1)
@@ -146,6 +204,27 @@ func main() { //nolint:funlen
 	}
 }
 
+// validateConfig checks the combinations of flags that can't be validated individually.
+func validateConfig(config *config) error {
+	if config.orphanCollectionInterval > 0 && len(config.orphanCollectionProjectIDs) == 0 {
+		return errOrphanCollectionProjectsRequired
+	}
+
+	if config.orphanCollectionInterval > 0 && config.managerID == "" {
+		return errManagerIDRequired
+	}
+
+	if config.deviceEventsReceiverAddr != "" && config.deviceEventsReceiverToken == "" {
+		return errDeviceEventsReceiverTokenRequired
+	}
+
+	if config.metalAPIRateLimit.QPS > 0 && config.metalAPIRateLimit.Burst < 1 {
+		return errMetalAPIBurstTooLow
+	}
+
+	return nil
+}
+
 func configureFlags(flagset *pflag.FlagSet, config *config) { //nolint:funlen
 	flagset.StringVar(
 		&config.metricsBindAddr,
@@ -229,12 +308,140 @@ func configureFlags(flagset *pflag.FlagSet, config *config) { //nolint:funlen
 		),
 	)
 
+	flagset.DurationVar(&config.orphanCollectionInterval,
+		"orphan-collection-interval",
+		0,
+		"The interval at which Equinix Metal projects are scanned for orphaned devices, IPs and VLANs. "+
+			"If unspecified, orphaned resources are not collected.",
+	)
+
+	flagset.DurationVar(&config.orphanCollectionGracePeriod,
+		"orphan-collection-grace-period",
+		defaultOrphanCollectionGracePeriod,
+		"How long a resource has to be orphaned before it is reported or deleted",
+	)
+
+	flagset.BoolVar(&config.orphanCollectionDryRun,
+		"orphan-collection-dry-run",
+		false,
+		"Only report orphaned resources through events instead of deleting them",
+	)
+
+	flagset.StringSliceVar(&config.orphanCollectionProjectIDs,
+		"orphan-collection-projects",
+		nil,
+		"Equinix Metal project IDs to scan for orphaned resources "+
+			"in addition to the projects referenced by EquinixMetalClusters. "+
+			"Required when orphaned resources are collected, since the project of a deleted cluster "+
+			"is not referenced by any EquinixMetalCluster anymore.",
+	)
+
+	flagset.StringVar(&config.managerID,
+		"manager-id",
+		"",
+		"Identifies this management cluster in the ownership tags of the Equinix Metal resources it manages, "+
+			"so that management clusters sharing a project only collect their own orphaned resources. "+
+			"Must be unique per management cluster. Required when orphaned resources are collected.",
+	)
+
+	flagset.Float64Var(&config.metalAPIRateLimit.QPS,
+		"metal-api-qps",
+		metal.DefaultQPS,
+		"Maximum sustained number of Equinix Metal API requests per second, shared by all controllers. "+
+			"Set to 0 to disable client-side rate limiting.",
+	)
+
+	flagset.IntVar(&config.metalAPIRateLimit.Burst,
+		"metal-api-burst",
+		metal.DefaultBurst,
+		"Maximum number of Equinix Metal API requests sent at once. Must be at least 1 when rate limiting is enabled.",
+	)
+
+	flagset.IntVar(&config.metalAPIRateLimit.MaxRetries,
+		"metal-api-max-retries",
+		metal.DefaultMaxRetries,
+		"Number of times an Equinix Metal API request is retried when it is throttled or the API is unavailable",
+	)
+
+	flagset.DurationVar(&config.metalAPIRateLimit.MinBackoff,
+		"metal-api-min-backoff",
+		metal.DefaultMinBackoff,
+		"Delay before the first retry of an Equinix Metal API request, doubled on every retry",
+	)
+
+	flagset.DurationVar(&config.metalAPIRateLimit.MaxBackoff,
+		"metal-api-max-backoff",
+		metal.DefaultMaxBackoff,
+		"Maximum delay between two retries of an Equinix Metal API request",
+	)
+
+	flagset.DurationVar(&config.deviceCacheInterval,
+		"device-cache-interval",
+		defaultDeviceCacheInterval,
+		"The interval at which the devices of the Equinix Metal projects are listed to refresh the device cache. "+
+			"Set to 0 to get every device from the Equinix Metal API on each reconciliation.",
+	)
+
+	flagset.DurationVar(&config.deviceEventsPollInterval,
+		"device-events-poll-interval",
+		0,
+		"The interval at which the events of the Equinix Metal projects are polled to requeue the "+
+			"EquinixMetalMachines of the devices that changed. If unspecified, events are not polled.",
+	)
+
+	flagset.StringVar(&config.deviceEventsReceiverAddr,
+		"device-events-receiver-addr",
+		"",
+		fmt.Sprintf(
+			"The address the HTTP endpoint receiving Equinix Metal events on %s binds to. "+
+				"If unspecified, events are not received.",
+			controllers.DeviceEventsPath,
+		),
+	)
+
+	flagset.StringVar(&config.deviceEventsReceiverToken,
+		"device-events-receiver-token",
+		os.Getenv("DEVICE_EVENTS_RECEIVER_TOKEN"),
+		"The bearer token senders of Equinix Metal events must authenticate with. "+
+			"Required when --device-events-receiver-addr is set. "+
+			"Defaults to the DEVICE_EVENTS_RECEIVER_TOKEN environment variable.",
+	)
+
+	flagset.DurationVar(&config.provisioningTimeout,
+		"provisioning-timeout",
+		0,
+		"How long devices can take to become active before their provisioning is considered failed. "+
+			"Can be overridden by EquinixMetalMachines. If unspecified, there is no timeout.",
+	)
+
+	flagset.IntVar(&config.provisioningMaxRetries,
+		"provisioning-max-retries",
+		0,
+		"How many times a device that failed to provision or timed out is deleted and created again "+
+			"before the EquinixMetalMachine is marked as failed",
+	)
+
+	flagset.DurationVar(&config.capacityCheckInterval,
+		"capacity-check-interval",
+		defaultCapacityCheckInterval,
+		"The interval at which the capacity for the machines of EquinixMetalMachineTemplates is checked. "+
+			"Set to 0 to disable capacity checks.",
+	)
+
 	feature.MutableGates.AddFlag(flagset)
 }
 
-func setupControllers(ctx context.Context, mgr ctrl.Manager, config *config) error {
+func setupControllers( //nolint:funlen
+	ctx context.Context,
+	mgr ctrl.Manager,
+	metalClient *metal.Client,
+	plans *metal.PlanCache,
+	config *config,
+) error {
 	if err := (&controllers.EquinixMetalClusterReconciler{ //nolint:exhaustivestruct
+		MetalClient:      metalClient,
 		WatchFilterValue: config.watchFilterValue,
+		ManagerID:        config.managerID,
 	}).SetupWithManager(
 		ctx,
 		mgr,
@@ -246,8 +453,38 @@ func setupControllers(ctx context.Context, mgr ctrl.Manager, config *config) err
 		return fmt.Errorf("unable to create EquinixMetalCluster controller: %w", err)
 	}
 
+	var deviceCache *controllers.DeviceCache
+
+	if config.deviceCacheInterval > 0 {
+		deviceCache = controllers.NewDeviceCache(metalClient, config.deviceCacheInterval)
+		if err := deviceCache.SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create device cache: %w", err)
+		}
+	}
+
+	var deviceEventSource *controllers.DeviceEventSource
+
+	if config.deviceEventsPollInterval > 0 || config.deviceEventsReceiverAddr != "" {
+		deviceEventSource = controllers.NewDeviceEventSource(metalClient)
+		deviceEventSource.PollInterval = config.deviceEventsPollInterval
+		deviceEventSource.ReceiverAddr = config.deviceEventsReceiverAddr
+		deviceEventSource.ReceiverToken = config.deviceEventsReceiverToken
+		deviceEventSource.DeviceCache = deviceCache
+
+		if err := deviceEventSource.SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create device event source: %w", err)
+		}
+	}
+
 	if err := (&controllers.EquinixMetalMachineReconciler{ //nolint:exhaustivestruct
-		WatchFilterValue: config.watchFilterValue,
+		MetalClient:            metalClient,
+		WatchFilterValue:       config.watchFilterValue,
+		DeviceCache:            deviceCache,
+		DeviceEventSource:      deviceEventSource,
+		PlanCache:              plans,
+		ProvisioningTimeout:    config.provisioningTimeout,
+		ProvisioningMaxRetries: config.provisioningMaxRetries,
+		ManagerID:              config.managerID,
 	}).SetupWithManager(
 		ctx,
 		mgr,
@@ -259,10 +496,38 @@ func setupControllers(ctx context.Context, mgr ctrl.Manager, config *config) err
 		return fmt.Errorf("unable to create EquinixMetalMachine controller: %w", err)
 	}
 
+	if err := (&controllers.EquinixMetalMachineTemplateReconciler{ //nolint:exhaustivestruct
+		MetalClient:           metalClient,
+		WatchFilterValue:      config.watchFilterValue,
+		CapacityCheckInterval: config.capacityCheckInterval,
+	}).SetupWithManager(
+		ctx,
+		mgr,
+		controller.Options{ //nolint:exhaustivestruct
+			RecoverPanic: true,
+		},
+	); err != nil {
+		return fmt.Errorf("unable to create EquinixMetalMachineTemplate controller: %w", err)
+	}
+
+	if config.orphanCollectionInterval > 0 {
+		if err := (&controllers.OrphanCollector{ //nolint:exhaustivestruct
+			MetalClient: metalClient,
+			Interval:    config.orphanCollectionInterval,
+			GracePeriod: config.orphanCollectionGracePeriod,
+			DryRun:      config.orphanCollectionDryRun,
+			ProjectIDs:  config.orphanCollectionProjectIDs,
+			Namespace:   config.watchNamespace,
+			ManagerID:   config.managerID,
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create orphan collector: %w", err)
+		}
+	}
+
 	return nil
 }
 
-func setupWebhooks(mgr ctrl.Manager) error {
+func setupWebhooks(mgr ctrl.Manager, plans *metal.PlanCache) error {
 	if err := new(infrav1beta1.EquinixMetalCluster).SetupWebhookWithManager(mgr); err != nil {
 		return fmt.Errorf("unable to create EquinixMetalCluster webhook: %w", err)
 	}
@@ -275,6 +540,10 @@ func setupWebhooks(mgr ctrl.Manager) error {
 		return fmt.Errorf("unable to create EquinixMetalMachineTemplate webhook: %w", err)
 	}
 
+	if err := budget.NewValidator(plans).SetupWebhookWithManager(mgr); err != nil {
+		return fmt.Errorf("unable to create budget webhook: %w", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,279 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package budget enforces the spending limits set on Clusters and Namespaces with the
+// MaxHourlySpendAnnotation when EquinixMetalMachines and EquinixMetalMachineTemplates are created.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+const (
+	// WebhookPath is the path the budget webhook is served at.
+	WebhookPath = "/validate-infrastructure-cluster-x-k8s-io-v1beta1-budget"
+)
+
+//+kubebuilder:webhook:verbs=create,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-budget,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=equinixmetalmachines;equinixmetalmachinetemplates,versions=v1beta1,name=budget.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// Validator rejects the creation of EquinixMetalMachines and EquinixMetalMachineTemplates that would push
+// the projected hourly spend of their Cluster or Namespace over the budget set with the
+// MaxHourlySpendAnnotation. The projected spend is the price of the existing EquinixMetalMachines plus
+// the price of the new machine, or of one machine created from the new template.
+//
+// Admission requests are not serialised: machines created concurrently are each checked against the
+// spend of the machines that existed before them, so a burst of creations can exceed the budget.
+type Validator struct {
+	Client client.Client
+	// Reader lists the existing EquinixMetalMachines, it must not be cached so that the machines
+	// created just before the new one are counted.
+	Reader client.Reader
+	Plans  *metal.PlanCache
+
+	decoder *admission.Decoder
+}
+
+// budget is the maximum hourly spend of a Cluster, or of a Namespace when clusterName is empty.
+type budget struct {
+	clusterName string
+	description string
+	max         float64
+}
+
+// NewValidator returns a Validator pricing the machines with the plans of the Equinix Metal plan catalog.
+func NewValidator(plans *metal.PlanCache) *Validator {
+	return &Validator{ //nolint:exhaustivestruct
+		Plans: plans,
+	}
+}
+
+// SetupWebhookWithManager registers the budget webhook with the webhook server of the Manager.
+func (v *Validator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if v.Client == nil {
+		v.Client = mgr.GetClient()
+	}
+
+	if v.Reader == nil {
+		v.Reader = mgr.GetAPIReader()
+	}
+
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("failed to create budget webhook decoder: %w", err)
+	}
+
+	v.decoder = decoder
+
+	mgr.GetWebhookServer().Register(WebhookPath, &webhook.Admission{Handler: v})
+
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (v *Validator) Handle(ctx context.Context, req admission.Request) admission.Response { //nolint:gocritic
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	var (
+		spec   infrav1.EquinixMetalMachineSpec
+		labels map[string]string
+	)
+
+	switch req.Kind.Kind {
+	case "EquinixMetalMachine":
+		machine := new(infrav1.EquinixMetalMachine)
+		if err := v.decoder.Decode(req, machine); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		spec, labels = machine.Spec, machine.Labels
+	case "EquinixMetalMachineTemplate":
+		template := new(infrav1.EquinixMetalMachineTemplate)
+		if err := v.decoder.Decode(req, template); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		spec, labels = template.Spec.Template.Spec, template.Labels
+	default:
+		return admission.Allowed("")
+	}
+
+	budgets, err := v.budgets(ctx, req.Namespace, labels[clusterv1.ClusterLabelName])
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if len(budgets) == 0 {
+		return admission.Allowed("")
+	}
+
+	price, err := v.hourlyPrice(ctx, spec.MachineType, spec.Metro, spec.Facility)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	for _, b := range budgets {
+		spend, err := v.spend(ctx, req.Namespace, b.clusterName)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+
+		if spend+price > b.max {
+			return admission.Denied(fmt.Sprintf("a %s device costs $%s per hour, which would raise the hourly "+
+				"spend of %s from $%s to $%s, over its budget of $%s",
+				spec.MachineType, metal.FormatPrice(price), b.description, metal.FormatPrice(spend),
+				metal.FormatPrice(spend+price), metal.FormatPrice(b.max),
+			))
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+// budgets returns the budgets of the Namespace and of the Cluster, if any.
+func (v *Validator) budgets(ctx context.Context, namespace, clusterName string) ([]budget, error) {
+	var budgets []budget
+
+	ns := new(corev1.Namespace)
+	if err := v.Client.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return nil, fmt.Errorf("failed to get Namespace %s: %w", namespace, err)
+	}
+
+	if value, ok := ns.Annotations[infrav1.MaxHourlySpendAnnotation]; ok {
+		limit, err := parseBudget(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget of Namespace %s: %w", namespace, err)
+		}
+
+		budgets = append(budgets, budget{clusterName: "", description: "Namespace " + namespace, max: limit})
+	}
+
+	if clusterName == "" {
+		return budgets, nil
+	}
+
+	cluster := new(clusterv1.Cluster)
+	if err := v.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: clusterName}, cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return budgets, nil
+		}
+
+		return nil, fmt.Errorf("failed to get Cluster %s: %w", clusterName, err)
+	}
+
+	if value, ok := cluster.Annotations[infrav1.MaxHourlySpendAnnotation]; ok {
+		limit, err := parseBudget(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget of Cluster %s: %w", clusterName, err)
+		}
+
+		budgets = append(budgets, budget{clusterName: clusterName, description: "Cluster " + clusterName, max: limit})
+	}
+
+	return budgets, nil
+}
+
+// spend returns the hourly price of the EquinixMetalMachines of a Namespace, or of one of its Clusters
+// when clusterName is set. Machines being deleted are not counted, nor are the machines that cannot be
+// priced, so that a machine type removed from the plan catalog does not block the creation of all machines.
+func (v *Validator) spend(ctx context.Context, namespace, clusterName string) (float64, error) {
+	opts := []client.ListOption{client.InNamespace(namespace)}
+	if clusterName != "" {
+		opts = append(opts, client.MatchingLabels{clusterv1.ClusterLabelName: clusterName})
+	}
+
+	machines := new(infrav1.EquinixMetalMachineList)
+	if err := v.Reader.List(ctx, machines, opts...); err != nil {
+		return 0, fmt.Errorf("failed to list EquinixMetalMachines: %w", err)
+	}
+
+	var spend float64
+
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if !machine.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if machine.Status.Cost != nil {
+			if price, err := strconv.ParseFloat(machine.Status.Cost.HourlyPrice, 64); err == nil {
+				spend += price
+
+				continue
+			}
+		}
+
+		price, err := v.hourlyPrice(ctx, machine.Spec.MachineType, machine.Spec.Metro, machine.Spec.Facility)
+		if err != nil {
+			ctrl.LoggerFrom(ctx).Info("Not counting the spend of an EquinixMetalMachine that cannot be priced",
+				"equinixMetalMachine", machine.Name, "error", err.Error())
+
+			continue
+		}
+
+		spend += price
+	}
+
+	return spend, nil
+}
+
+// hourlyPrice returns the hourly price of a device of a plan in a metro or facility.
+func (v *Validator) hourlyPrice(ctx context.Context, machineType, metro, facility string) (float64, error) {
+	plan, err := v.Plans.GetPlan(ctx, machineType)
+	if err != nil {
+		return 0, fmt.Errorf("failed to price machine type: %w", err)
+	}
+
+	if plan == nil {
+		return 0, fmt.Errorf("machine type %q not found in the Equinix Metal plan catalog", machineType)
+	}
+
+	price := plan.Price(metro, facility)
+	if price == nil {
+		return 0, fmt.Errorf("machine type %q has no price in the Equinix Metal plan catalog", machineType)
+	}
+
+	hourly, _ := price.ForBillingCycle(metal.BillingCycleHourly)
+
+	return hourly, nil
+}
+
+func parseBudget(value string) (float64, error) {
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("%s annotation must be a positive number of US dollars, got %q",
+			infrav1.MaxHourlySpendAnnotation, value)
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/cloud/metal"
+)
+
+func TestParseBudget(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{name: "integer", value: "10", want: 10},
+		{name: "decimal", value: "2.5", want: 2.5},
+		{name: "zero", value: "0", want: 0},
+		{name: "negative", value: "-1", wantErr: true},
+		{name: "currency symbol", value: "$10", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseBudget(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBudget(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseBudget(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpend(t *testing.T) {
+	t.Parallel()
+
+	machines := []client.Object{
+		newMachine("priced", "a", "c3.small.x86", &infrav1.MachineCost{HourlyPrice: "0.5"}), //nolint:exhaustivestruct
+		newMachine("unpriced", "a", "m3.large.x86", nil),
+		newMachine("removed-plan", "a", "t1.small.x86", nil),
+		newMachine("no-plan", "a", "unknown", nil),
+		newMachine("other-cluster", "b", "c3.small.x86", &infrav1.MachineCost{HourlyPrice: "1"}), //nolint:exhaustivestruct
+	}
+
+	deleted := newMachine("deleted", "a", "c3.small.x86", &infrav1.MachineCost{HourlyPrice: "2"}) //nolint:exhaustivestruct
+	deleted.Finalizers = []string{infrav1.MachineFinalizer}
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	machines = append(machines, deleted)
+
+	tests := []struct {
+		name        string
+		clusterName string
+		want        float64
+	}{
+		{name: "namespace", clusterName: "", want: 3.5},
+		{name: "cluster", clusterName: "a", want: 2.5},
+		{name: "cluster without machines", clusterName: "c", want: 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v := newTestValidator(t, machines...)
+
+			got, err := v.spend(context.Background(), "default", tt.clusterName)
+			if err != nil {
+				t.Fatalf("spend() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("spend() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	namespace := func(budget string) *corev1.Namespace {
+		return &corev1.Namespace{ //nolint:exhaustivestruct
+			ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustivestruct
+				Name:        "default",
+				Annotations: map[string]string{infrav1.MaxHourlySpendAnnotation: budget},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		namespace   *corev1.Namespace
+		machineType string
+		wantAllowed bool
+		wantCode    int32
+	}{
+		{name: "within budget", namespace: namespace("3"), machineType: "m3.large.x86", wantAllowed: true},
+		{name: "over budget", namespace: namespace("1"), machineType: "m3.large.x86", wantCode: http.StatusForbidden},
+		{name: "missing namespace", machineType: "m3.large.x86", wantCode: http.StatusInternalServerError},
+		{
+			name:        "invalid budget",
+			namespace:   namespace("$1"),
+			machineType: "m3.large.x86",
+			wantCode:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var objects []client.Object
+			if tt.namespace != nil {
+				objects = append(objects, tt.namespace)
+			}
+
+			v := newTestValidator(t, objects...)
+
+			raw, err := json.Marshal(newMachine("new", "", tt.machineType, nil))
+			if err != nil {
+				t.Fatalf("failed to encode machine: %v", err)
+			}
+
+			resp := v.Handle(context.Background(), admission.Request{ //nolint:exhaustivestruct
+				AdmissionRequest: admissionv1.AdmissionRequest{ //nolint:exhaustivestruct
+					Operation: admissionv1.Create,
+					Namespace: "default",
+					Kind:      metav1.GroupVersionKind{Group: infrav1.GroupVersion.Group, Kind: "EquinixMetalMachine"},
+					Object:    runtime.RawExtension{Raw: raw}, //nolint:exhaustivestruct
+				},
+			})
+
+			if resp.Allowed != tt.wantAllowed {
+				t.Fatalf("Handle() allowed = %v, want %v: %+v", resp.Allowed, tt.wantAllowed, resp.Result)
+			}
+
+			if !tt.wantAllowed && resp.Result.Code != tt.wantCode {
+				t.Errorf("Handle() code = %d, want %d", resp.Result.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+// newTestValidator returns a Validator listing the given objects, with a plan catalog where m3.large.x86
+// costs $2 per hour, t1.small.x86 has no price and no other plan exists.
+func newTestValidator(t *testing.T, objects ...client.Object) *Validator {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to create scheme: %v", err)
+	}
+
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to create scheme: %v", err)
+	}
+
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatalf("failed to create decoder: %v", err)
+	}
+
+	plans := map[string]metal.Plan{
+		"m3.large.x86": {Slug: "m3.large.x86", Pricing: &metal.PlanPrice{Hour: 2}}, //nolint:exhaustivestruct
+		"t1.small.x86": {Slug: "t1.small.x86"},                                     //nolint:exhaustivestruct
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list := map[string][]metal.Plan{"plans": {}}
+		if plan, ok := plans[r.URL.Query().Get("slug")]; ok {
+			list["plans"] = append(list["plans"], plan)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(server.Close)
+
+	metalClient, err := metal.NewClient(server.URL, "token", server.Client())
+	if err != nil {
+		t.Fatalf("failed to create Metal client: %v", err)
+	}
+
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	return &Validator{
+		Client:  reader,
+		Reader:  reader,
+		Plans:   metal.NewPlanCache(metalClient),
+		decoder: decoder,
+	}
+}
+
+func newMachine(name, clusterName, machineType string, cost *infrav1.MachineCost) *infrav1.EquinixMetalMachine {
+	return &infrav1.EquinixMetalMachine{ //nolint:exhaustivestruct
+		ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustivestruct
+			Namespace: "default",
+			Name:      name,
+			Labels:    map[string]string{clusterv1.ClusterLabelName: clusterName},
+		},
+		Spec: infrav1.EquinixMetalMachineSpec{ //nolint:exhaustivestruct
+			MachineType: machineType,
+		},
+		Status: infrav1.EquinixMetalMachineStatus{ //nolint:exhaustivestruct
+			Cost: cost,
+		},
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ServerCapacity is a request, and the answer, for the availability of a number of servers of a plan
+// in a metro or facility. Only one of Metro and Facility must be set.
+type ServerCapacity struct {
+	Metro     string `json:"metro,omitempty"`
+	Facility  string `json:"facility,omitempty"`
+	Plan      string `json:"plan"`
+	Quantity  int    `json:"quantity"`
+	Available bool   `json:"available,omitempty"`
+}
+
+type capacityRequest struct {
+	Servers []ServerCapacity `json:"servers"`
+}
+
+// CheckCapacity returns whether the requested number of servers of a plan are available in a metro or
+// facility.
+func (c *Client) CheckCapacity(ctx context.Context, req ServerCapacity) (bool, error) {
+	path := "capacity"
+	if req.Metro != "" {
+		path = "capacity/metros"
+	}
+
+	resp := new(capacityRequest)
+	if err := c.do(ctx, http.MethodPost, path, nil, &capacityRequest{Servers: []ServerCapacity{req}}, resp); err != nil {
+		return false, fmt.Errorf("failed to check capacity of plan %q: %w", req.Plan, err)
+	}
+
+	for _, server := range resp.Servers {
+		if !server.Available {
+			return false, nil
+		}
+	}
+
+	return len(resp.Servers) > 0, nil
+}
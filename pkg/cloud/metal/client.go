@@ -0,0 +1,346 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metal contains a minimal client for the Equinix Metal API.
+package metal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"sigs.k8s.io/cluster-api-provider-equinixmetal/pkg/metrics"
+)
+
+const (
+	// APIKeyEnvVar is the environment variable holding the Equinix Metal API key.
+	APIKeyEnvVar = "EQUINIX_METAL_API_KEY" //nolint:gosec
+	// APIURLEnvVar is the environment variable that can be used to override the Equinix Metal API URL.
+	APIURLEnvVar = "EQUINIX_METAL_API_URL"
+	// DefaultAPIURL is the default Equinix Metal API URL.
+	DefaultAPIURL = "https://api.equinix.com/metal/v1/"
+
+	defaultTimeout = 30 * time.Second
+	defaultPerPage = 100
+	userAgent      = "cluster-api-provider-equinixmetal"
+)
+
+var (
+	// ErrMissingAPIKey is returned when no Equinix Metal API key has been configured.
+	ErrMissingAPIKey = fmt.Errorf("missing Equinix Metal API key, %s must be set", APIKeyEnvVar)
+	// ErrNotFound is returned when the requested Equinix Metal resource does not exist.
+	ErrNotFound = errors.New("resource not found")
+)
+
+// Client is a client for the Equinix Metal API.
+type Client struct {
+	baseURL    *url.URL
+	apiKey     string
+	httpClient *http.Client
+	rateLimit  RateLimitOptions
+	limiter    *rate.Limiter
+}
+
+// NewClient returns a new Client using the given API key. If httpClient is nil a default client is used.
+// Requests are rate limited and retried with the DefaultRateLimitOptions unless WithRateLimit is given.
+func NewClient(baseURL, apiKey string, httpClient *http.Client, opts ...Option) (*Client, error) {
+	if apiKey == "" {
+		return nil, ErrMissingAPIKey
+	}
+
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Equinix Metal API URL %q: %w", baseURL, err)
+	}
+
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout} //nolint:exhaustivestruct
+	}
+
+	rateLimit := DefaultRateLimitOptions()
+
+	c := &Client{
+		baseURL:    u,
+		apiKey:     apiKey,
+		httpClient: httpClient,
+		rateLimit:  rateLimit,
+		limiter:    newLimiter(rateLimit),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// NewClientFromEnv returns a new Client configured from the environment.
+func NewClientFromEnv(httpClient *http.Client, opts ...Option) (*Client, error) {
+	return NewClient(os.Getenv(APIURLEnvVar), os.Getenv(APIKeyEnvVar), httpClient, opts...)
+}
+
+// APIError is returned when the Equinix Metal API responds with an unsuccessful status code.
+type APIError struct {
+	StatusCode int
+	Messages   []string
+	// RetryAfter is the delay requested by the Retry-After header of the response, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("equinix metal api returned status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("equinix metal api returned status %d: %s", e.StatusCode, strings.Join(e.Messages, ", "))
+}
+
+// Unwrap allows errors.Is(err, ErrNotFound) to match 404 responses.
+func (e *APIError) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// IsNotFound returns true if the error reports a missing Equinix Metal resource.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsAccessDenied returns true if the error reports that the API key is not valid or not allowed to access
+// the requested Equinix Metal resource.
+func IsAccessDenied(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+}
+
+// capacityErrorMessages are the parts of the messages of the Equinix Metal API reporting that a plan is
+// out of capacity, such as "The facility da11 has no provisionable c3.small.x86 servers matching your
+// criteria." The API has no error code for them.
+var capacityErrorMessages = []string{"no provisionable", "capacity", "not available"} //nolint:gochecknoglobals
+
+// IsCapacityError returns true if the error reports that the requested plan is out of capacity
+// in the requested location.
+func IsCapacityError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.StatusCode != http.StatusUnprocessableEntity && apiErr.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+
+	for _, message := range apiErr.Messages {
+		message = strings.ToLower(message)
+
+		for _, part := range capacityErrorMessages {
+			if strings.Contains(message, part) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type errorResponse struct {
+	Errors []string `json:"errors,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+type listMeta struct {
+	CurrentPage int `json:"current_page"` //nolint:tagliatelle
+	LastPage    int `json:"last_page"`    //nolint:tagliatelle
+}
+
+// do performs a request against the Equinix Metal API, encoding in as the request body
+// and decoding the response body into out when they are not nil. Requests are rate limited,
+// and retried with an exponential backoff when they are throttled or the API is unavailable.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", path, err)
+	}
+
+	u := c.baseURL.ResolveReference(ref)
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	var reqBody []byte
+
+	if in != nil {
+		if reqBody, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		respBody, err := c.send(ctx, method, path, u, reqBody)
+		if err == nil {
+			if out == nil || len(respBody) == 0 {
+				return nil
+			}
+
+			if err := json.Unmarshal(respBody, out); err != nil {
+				return fmt.Errorf("failed to decode response body: %w", err)
+			}
+
+			return nil
+		}
+
+		delay, retry := c.backoff(method, attempt, err)
+		if !retry {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return fmt.Errorf("%s %s failed: %w", method, path, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// send performs a single attempt of a request and returns the response body.
+func (c *Client) send(ctx context.Context, method, path string, u *url.URL, reqBody []byte) ([]byte, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("%s %s failed waiting for rate limiter: %w", method, path, err)
+	}
+
+	var body io.Reader
+	if reqBody != nil {
+		body = bytes.NewReader(reqBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Auth-Token", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveMetalAPIRequest(method, path, 0, time.Since(start))
+
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	metrics.ObserveMetalAPIRequest(method, path, resp.StatusCode, time.Since(start))
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		apiErr := newAPIError(resp.StatusCode, respBody)
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+
+		return nil, apiErr
+	}
+
+	return respBody, nil
+}
+
+// listPages calls fn for every page of a paginated list endpoint. fn must decode the
+// page into its own result and return the page metadata.
+func (c *Client) listPages(
+	ctx context.Context,
+	path string,
+	query url.Values,
+	fn func(page json.RawMessage) (listMeta, error),
+) error {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	query.Set("per_page", strconv.Itoa(defaultPerPage))
+
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+
+		var raw json.RawMessage
+		if err := c.do(ctx, http.MethodGet, path, query, nil, &raw); err != nil {
+			return err
+		}
+
+		meta, err := fn(raw)
+		if err != nil {
+			return err
+		}
+
+		if meta.LastPage == 0 || meta.CurrentPage >= meta.LastPage {
+			return nil
+		}
+	}
+}
+
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Messages: nil, RetryAfter: 0}
+
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		apiErr.Messages = append(apiErr.Messages, errResp.Errors...)
+
+		if errResp.Error != "" {
+			apiErr.Messages = append(apiErr.Messages, errResp.Error)
+		}
+	}
+
+	return apiErr
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestIsCapacityError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		want       bool
+	}{
+		{
+			name:       "no provisionable servers in facility",
+			statusCode: http.StatusUnprocessableEntity,
+			body: `{"errors":["The facility da11 has no provisionable c3.small.x86 servers matching ` +
+				`your criteria."]}`,
+			want: true,
+		},
+		{
+			name:       "no provisionable servers in metro",
+			statusCode: http.StatusUnprocessableEntity,
+			body:       `{"errors":["The metro da has no provisionable m3.large.x86 servers matching your criteria."]}`,
+			want:       true,
+		},
+		{
+			name:       "capacity unavailable",
+			statusCode: http.StatusServiceUnavailable,
+			body:       `{"error":"Server capacity is not available, please try again later."}`,
+			want:       true,
+		},
+		{
+			name:       "invalid plan",
+			statusCode: http.StatusUnprocessableEntity,
+			body:       `{"errors":["Plan is not valid"]}`,
+			want:       false,
+		},
+		{
+			name:       "capacity message with another status",
+			statusCode: http.StatusBadRequest,
+			body:       `{"errors":["The facility da11 has no provisionable c3.small.x86 servers matching your criteria."]}`,
+			want:       false,
+		},
+		{
+			name:       "unavailable without body",
+			statusCode: http.StatusServiceUnavailable,
+			body:       "",
+			want:       false,
+		},
+		{
+			name:       "html body",
+			statusCode: http.StatusServiceUnavailable,
+			body:       "<html><body>503 Service Unavailable</body></html>",
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := fmt.Errorf("failed to create device: %w", newAPIError(tt.statusCode, []byte(tt.body)))
+
+			if got := IsCapacityError(err); got != tt.want {
+				t.Errorf("IsCapacityError(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+
+	if IsCapacityError(errors.New("connection refused")) {
+		t.Error("IsCapacityError() = true for an error that is not an APIError")
+	}
+}
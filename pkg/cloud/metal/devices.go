@@ -0,0 +1,246 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProviderIDPrefix is the prefix of the providerID of nodes backed by Equinix Metal devices.
+const ProviderIDPrefix = "equinixmetal://"
+
+// NextAvailableHardwareReservation lets the Equinix Metal API pick any provisionable hardware reservation
+// of the project for a new device.
+const NextAvailableHardwareReservation = "next-available"
+
+// Device states reported by the Equinix Metal API.
+const (
+	DeviceStateQueued         = "queued"
+	DeviceStateProvisioning   = "provisioning"
+	DeviceStateActive         = "active"
+	DeviceStateInactive       = "inactive"
+	DeviceStateFailed         = "failed"
+	DeviceStateDeprovisioning = "deprovisioning"
+	DeviceStateReinstalling   = "reinstalling"
+)
+
+// Device is an Equinix Metal device.
+type Device struct {
+	ID            string         `json:"id"`
+	Hostname      string         `json:"hostname"`
+	State         string         `json:"state"`
+	Tags          []string       `json:"tags,omitempty"`
+	AlwaysPXE     bool           `json:"always_pxe"`      //nolint:tagliatelle
+	IPXEScriptURL string         `json:"ipxe_script_url"` //nolint:tagliatelle
+	Network       []IPAssignment `json:"ip_addresses"`    //nolint:tagliatelle
+	Facility      *Facility      `json:"facility,omitempty"`
+	Metro         *Metro         `json:"metro,omitempty"`
+	Plan          *Plan          `json:"plan,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`              //nolint:tagliatelle
+	RootPassword  string         `json:"root_password,omitempty"` //nolint:tagliatelle
+	Locked        bool           `json:"locked"`
+
+	HardwareReservation *HardwareReservation `json:"hardware_reservation,omitempty"` //nolint:tagliatelle
+}
+
+// Facility is an Equinix Metal facility.
+type Facility struct {
+	Code string `json:"code"`
+}
+
+// Metro is an Equinix Metal metro.
+type Metro struct {
+	Code string `json:"code"`
+}
+
+// IPAssignment is an IP address assigned to a device.
+type IPAssignment struct {
+	Address       string `json:"address"`
+	Public        bool   `json:"public"`
+	AddressFamily int    `json:"address_family"` //nolint:tagliatelle
+}
+
+// DeviceCreateRequest holds the parameters of a new device.
+type DeviceCreateRequest struct {
+	Hostname              string                 `json:"hostname"`
+	Plan                  string                 `json:"plan"`
+	Metro                 string                 `json:"metro,omitempty"`
+	Facility              []string               `json:"facility,omitempty"`
+	OperatingSystem       string                 `json:"operating_system"`        //nolint:tagliatelle
+	BillingCycle          string                 `json:"billing_cycle,omitempty"` //nolint:tagliatelle
+	UserData              string                 `json:"userdata,omitempty"`
+	CustomData            map[string]interface{} `json:"customdata,omitempty"`
+	Tags                  []string               `json:"tags,omitempty"`
+	IPXEScriptURL         string                 `json:"ipxe_script_url,omitempty"`         //nolint:tagliatelle
+	AlwaysPXE             bool                   `json:"always_pxe,omitempty"`              //nolint:tagliatelle
+	HardwareReservationID string                 `json:"hardware_reservation_id,omitempty"` //nolint:tagliatelle
+	Storage               *Storage               `json:"storage,omitempty"`
+	ProjectSSHKeys        []string               `json:"project_ssh_keys,omitempty"` //nolint:tagliatelle
+}
+
+// DeviceUpdateRequest holds the device attributes to update. Nil fields are left unchanged.
+type DeviceUpdateRequest struct {
+	Tags          *[]string              `json:"tags,omitempty"`
+	AlwaysPXE     *bool                  `json:"always_pxe,omitempty"`      //nolint:tagliatelle
+	IPXEScriptURL *string                `json:"ipxe_script_url,omitempty"` //nolint:tagliatelle
+	UserData      *string                `json:"userdata,omitempty"`
+	CustomData    map[string]interface{} `json:"customdata,omitempty"`
+	Locked        *bool                  `json:"locked,omitempty"`
+}
+
+type deviceList struct {
+	Devices []Device `json:"devices"`
+	Meta    listMeta `json:"meta"`
+}
+
+// ProviderID returns the providerID of the node running on the device.
+func (d *Device) ProviderID() string {
+	return ProviderIDPrefix + d.ID
+}
+
+// SOSConsoleHost returns the host of the serial over SSH console of the device, or an empty
+// string if its facility is not known. The SSH username of the console is the device ID.
+func (d *Device) SOSConsoleHost() string {
+	if d.Facility == nil || d.Facility.Code == "" {
+		return ""
+	}
+
+	return "sos." + d.Facility.Code + ".platformequinix.com"
+}
+
+// DeviceIDFromProviderID returns the device ID referenced by a providerID.
+func DeviceIDFromProviderID(providerID string) string {
+	return strings.TrimPrefix(providerID, ProviderIDPrefix)
+}
+
+// GetDevice returns the device with the given ID.
+func (c *Client) GetDevice(ctx context.Context, deviceID string) (*Device, error) {
+	device := new(Device)
+
+	if err := c.do(ctx, http.MethodGet, "devices/"+url.PathEscape(deviceID), nil, nil, device); err != nil {
+		return nil, fmt.Errorf("failed to get device %q: %w", deviceID, err)
+	}
+
+	return device, nil
+}
+
+// ListDevices returns all the devices of a project.
+func (c *Client) ListDevices(ctx context.Context, projectID string) ([]Device, error) {
+	var devices []Device
+
+	path := "projects/" + url.PathEscape(projectID) + "/devices"
+
+	err := c.listPages(ctx, path, nil, func(page json.RawMessage) (listMeta, error) {
+		var list deviceList
+		if err := json.Unmarshal(page, &list); err != nil {
+			return listMeta{}, fmt.Errorf("failed to decode device list: %w", err)
+		}
+
+		devices = append(devices, list.Devices...)
+
+		return list.Meta, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices of project %q: %w", projectID, err)
+	}
+
+	return devices, nil
+}
+
+// GetDeviceByTags returns the device of a project carrying all the given tags, or nil if there is none.
+// Devices being deprovisioned are ignored.
+func (c *Client) GetDeviceByTags(ctx context.Context, projectID string, tags ...string) (*Device, error) {
+	devices, err := c.ListDevices(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range devices {
+		if devices[i].State != DeviceStateDeprovisioning && HasTags(devices[i].Tags, tags...) {
+			return &devices[i], nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil
+}
+
+// CreateDevice creates a new device in a project.
+func (c *Client) CreateDevice(ctx context.Context, projectID string, req *DeviceCreateRequest) (*Device, error) {
+	device := new(Device)
+
+	path := "projects/" + url.PathEscape(projectID) + "/devices"
+	if err := c.do(ctx, http.MethodPost, path, nil, req, device); err != nil {
+		return nil, fmt.Errorf("failed to create device %q: %w", req.Hostname, err)
+	}
+
+	return device, nil
+}
+
+// UpdateDevice updates the attributes of the device with the given ID.
+func (c *Client) UpdateDevice(ctx context.Context, deviceID string, req *DeviceUpdateRequest) (*Device, error) {
+	device := new(Device)
+
+	if err := c.do(ctx, http.MethodPut, "devices/"+url.PathEscape(deviceID), nil, req, device); err != nil {
+		return nil, fmt.Errorf("failed to update device %q: %w", deviceID, err)
+	}
+
+	return device, nil
+}
+
+// DeleteDevice deletes the device with the given ID.
+func (c *Client) DeleteDevice(ctx context.Context, deviceID string) error {
+	if err := c.do(ctx, http.MethodDelete, "devices/"+url.PathEscape(deviceID), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete device %q: %w", deviceID, err)
+	}
+
+	return nil
+}
+
+// Device actions supported by the Equinix Metal API.
+const (
+	DeviceActionReboot    = "reboot"
+	DeviceActionPowerOn   = "power_on"
+	DeviceActionPowerOff  = "power_off"
+	DeviceActionReinstall = "reinstall"
+	DeviceActionRescue    = "rescue"
+)
+
+// DeviceActionRequest is the body of a device action request. The operating system and data
+// options only apply to reinstall actions.
+type DeviceActionRequest struct {
+	Type            string `json:"type"`
+	OperatingSystem string `json:"operating_system,omitempty"` //nolint:tagliatelle
+	PreserveData    bool   `json:"preserve_data,omitempty"`    //nolint:tagliatelle
+	DeprovisionFast bool   `json:"deprovision_fast,omitempty"` //nolint:tagliatelle
+}
+
+// PerformDeviceAction performs an action, such as a reboot, on the device with the given ID.
+func (c *Client) PerformDeviceAction(ctx context.Context, deviceID string, req *DeviceActionRequest) error {
+	path := "devices/" + url.PathEscape(deviceID) + "/actions"
+
+	if err := c.do(ctx, http.MethodPost, path, nil, req, nil); err != nil {
+		return fmt.Errorf("failed to %s device %q: %w", req.Type, deviceID, err)
+	}
+
+	return nil
+}
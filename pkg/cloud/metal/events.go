@@ -0,0 +1,109 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Device event types.
+const (
+	EventTypeDeviceProvisioned = "instance.provisioned"
+	EventTypeDevicePoweredOn   = "instance.powered_on"
+	EventTypeDevicePoweredOff  = "instance.powered_off"
+	EventTypeDeviceRebooted    = "instance.rebooted"
+	EventTypeDeviceDeleted     = "instance.deleted"
+	EventTypeDeviceFailed      = "instance.failed"
+
+	devicesPathSegment = "/devices/"
+)
+
+// Event is an Equinix Metal project event.
+type Event struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	State         string    `json:"state,omitempty"`
+	Body          string    `json:"body,omitempty"`
+	Relationships []Href    `json:"relationships,omitempty"`
+	CreatedAt     time.Time `json:"created_at"` //nolint:tagliatelle
+}
+
+type eventList struct {
+	Events []Event  `json:"events"`
+	Meta   listMeta `json:"meta"`
+}
+
+// IsDeviceStateEvent returns true if the event reports a change of the state of a device.
+func (e *Event) IsDeviceStateEvent() bool {
+	switch e.Type {
+	case EventTypeDeviceProvisioned,
+		EventTypeDevicePoweredOn,
+		EventTypeDevicePoweredOff,
+		EventTypeDeviceRebooted,
+		EventTypeDeviceDeleted,
+		EventTypeDeviceFailed:
+		return true
+	}
+
+	return false
+}
+
+// DeviceID returns the ID of the device the event relates to, or an empty string if there is none.
+func (e *Event) DeviceID() string {
+	for _, rel := range e.Relationships {
+		if i := strings.LastIndex(rel.Href, devicesPathSegment); i >= 0 {
+			return strings.Trim(rel.Href[i+len(devicesPathSegment):], "/")
+		}
+	}
+
+	return ""
+}
+
+// ListProjectEvents returns the events of a project created after since, newest first.
+func (c *Client) ListProjectEvents(ctx context.Context, projectID string, since time.Time) ([]Event, error) {
+	var events []Event
+
+	path := "projects/" + url.PathEscape(projectID) + "/events"
+
+	err := c.listPages(ctx, path, nil, func(page json.RawMessage) (listMeta, error) {
+		var list eventList
+		if err := json.Unmarshal(page, &list); err != nil {
+			return listMeta{}, fmt.Errorf("failed to decode event list: %w", err)
+		}
+
+		for _, event := range list.Events {
+			// Events are listed newest first, stop at the first one already seen.
+			if !event.CreatedAt.After(since) {
+				return listMeta{}, nil
+			}
+
+			events = append(events, event)
+		}
+
+		return list.Meta, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events of project %q: %w", projectID, err)
+	}
+
+	return events, nil
+}
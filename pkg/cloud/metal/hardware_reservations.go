@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// HardwareReservation is an Equinix Metal hardware reservation.
+type HardwareReservation struct {
+	ID string `json:"id"`
+	// Provisionable is true when no device runs on the hardware reservation.
+	Provisionable bool     `json:"provisionable,omitempty"`
+	Plan          *Plan    `json:"plan,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+type hardwareReservationList struct {
+	HardwareReservations []HardwareReservation `json:"hardware_reservations"` //nolint:tagliatelle
+	Meta                 listMeta              `json:"meta"`
+}
+
+type hardwareReservationUpdateRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// ListHardwareReservations returns all the hardware reservations of a project.
+func (c *Client) ListHardwareReservations(ctx context.Context, projectID string) ([]HardwareReservation, error) {
+	var reservations []HardwareReservation
+
+	path := "projects/" + url.PathEscape(projectID) + "/hardware-reservations"
+
+	err := c.listPages(ctx, path, nil, func(page json.RawMessage) (listMeta, error) {
+		var list hardwareReservationList
+		if err := json.Unmarshal(page, &list); err != nil {
+			return listMeta{}, fmt.Errorf("failed to decode hardware reservation list: %w", err)
+		}
+
+		reservations = append(reservations, list.HardwareReservations...)
+
+		return list.Meta, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hardware reservations of project %q: %w", projectID, err)
+	}
+
+	return reservations, nil
+}
+
+// GetHardwareReservation returns the hardware reservation with the given ID.
+func (c *Client) GetHardwareReservation(ctx context.Context, reservationID string) (*HardwareReservation, error) {
+	reservation := new(HardwareReservation)
+
+	path := "hardware-reservations/" + url.PathEscape(reservationID)
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, reservation); err != nil {
+		return nil, fmt.Errorf("failed to get hardware reservation %q: %w", reservationID, err)
+	}
+
+	return reservation, nil
+}
+
+// UpdateHardwareReservationTags replaces the tags of the hardware reservation with the given ID.
+func (c *Client) UpdateHardwareReservationTags(ctx context.Context, reservationID string, tags []string) error {
+	path := "hardware-reservations/" + url.PathEscape(reservationID)
+
+	err := c.do(ctx, http.MethodPatch, path, nil, &hardwareReservationUpdateRequest{Tags: tags}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update hardware reservation %q: %w", reservationID, err)
+	}
+
+	return nil
+}
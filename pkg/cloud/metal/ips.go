@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// PublicIPv4 is the type of public IPv4 reservations.
+const PublicIPv4 = "public_ipv4"
+
+// IPReservation is an Equinix Metal IP address reservation.
+type IPReservation struct {
+	ID          string    `json:"id"`
+	Address     string    `json:"address"`
+	Type        string    `json:"type"`
+	Tags        []string  `json:"tags,omitempty"`
+	Assignments []Href    `json:"assignments,omitempty"`
+	CreatedAt   time.Time `json:"created_at"` //nolint:tagliatelle
+}
+
+// Href is a reference to another Equinix Metal resource.
+type Href struct {
+	Href string `json:"href"`
+}
+
+// IPReservationRequest holds the parameters of a new IP reservation.
+type IPReservationRequest struct {
+	Type     string   `json:"type"`
+	Quantity int      `json:"quantity"`
+	Metro    string   `json:"metro,omitempty"`
+	Facility string   `json:"facility,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Comments string   `json:"comments,omitempty"`
+}
+
+type ipReservationUpdateRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type ipAssignmentRequest struct {
+	Address string `json:"address"`
+}
+
+type ipReservationList struct {
+	IPAddresses []IPReservation `json:"ip_addresses"` //nolint:tagliatelle
+	Meta        listMeta        `json:"meta"`
+}
+
+// ListIPReservations returns all the IP reservations of a project.
+func (c *Client) ListIPReservations(ctx context.Context, projectID string) ([]IPReservation, error) {
+	var reservations []IPReservation
+
+	path := "projects/" + url.PathEscape(projectID) + "/ips"
+
+	err := c.listPages(ctx, path, nil, func(page json.RawMessage) (listMeta, error) {
+		var list ipReservationList
+		if err := json.Unmarshal(page, &list); err != nil {
+			return listMeta{}, fmt.Errorf("failed to decode ip reservation list: %w", err)
+		}
+
+		reservations = append(reservations, list.IPAddresses...)
+
+		return list.Meta, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ip reservations of project %q: %w", projectID, err)
+	}
+
+	return reservations, nil
+}
+
+// GetIPReservationByTags returns the IP reservation of a project carrying all the given tags,
+// or nil if there is none.
+func (c *Client) GetIPReservationByTags(ctx context.Context, projectID string, tags ...string) (*IPReservation, error) {
+	reservations, err := c.ListIPReservations(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range reservations {
+		if HasTags(reservations[i].Tags, tags...) {
+			return &reservations[i], nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil
+}
+
+// CreateIPReservation requests a new IP reservation in a project.
+func (c *Client) CreateIPReservation(
+	ctx context.Context,
+	projectID string,
+	req *IPReservationRequest,
+) (*IPReservation, error) {
+	reservation := new(IPReservation)
+
+	path := "projects/" + url.PathEscape(projectID) + "/ips"
+	if err := c.do(ctx, http.MethodPost, path, nil, req, reservation); err != nil {
+		return nil, fmt.Errorf("failed to reserve ip addresses: %w", err)
+	}
+
+	return reservation, nil
+}
+
+// UpdateIPReservationTags replaces the tags of the IP reservation with the given ID.
+func (c *Client) UpdateIPReservationTags(ctx context.Context, reservationID string, tags []string) error {
+	path := "ips/" + url.PathEscape(reservationID)
+	if err := c.do(ctx, http.MethodPatch, path, nil, &ipReservationUpdateRequest{Tags: tags}, nil); err != nil {
+		return fmt.Errorf("failed to update ip reservation %q: %w", reservationID, err)
+	}
+
+	return nil
+}
+
+// AssignIP assigns an IP address, in CIDR notation, to a device.
+func (c *Client) AssignIP(ctx context.Context, deviceID, address string) error {
+	path := "devices/" + url.PathEscape(deviceID) + "/ips"
+	if err := c.do(ctx, http.MethodPost, path, nil, &ipAssignmentRequest{Address: address}, nil); err != nil {
+		return fmt.Errorf("failed to assign %s to device %q: %w", address, deviceID, err)
+	}
+
+	return nil
+}
+
+// DeleteIPReservation releases the IP reservation with the given ID.
+func (c *Client) DeleteIPReservation(ctx context.Context, reservationID string) error {
+	if err := c.do(ctx, http.MethodDelete, "ips/"+url.PathEscape(reservationID), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete ip reservation %q: %w", reservationID, err)
+	}
+
+	return nil
+}
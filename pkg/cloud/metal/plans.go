@@ -0,0 +1,277 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Architectures of the CPUs of Equinix Metal plans, named after the Go architectures reported by Nodes.
+const (
+	ArchitectureAMD64 = "amd64"
+	ArchitectureARM64 = "arm64"
+)
+
+// Billing cycles of Equinix Metal devices.
+const (
+	BillingCycleHourly  = "hourly"
+	BillingCycleMonthly = "monthly"
+)
+
+// HoursPerMonth is the number of hours Equinix Metal bills per month for hourly prices.
+const HoursPerMonth = 730
+
+// planCacheTTL is how long the plans looked up in the Equinix Metal plan catalog are cached.
+const planCacheTTL = time.Hour
+
+// Plan is an Equinix Metal device plan.
+type Plan struct {
+	Slug              string         `json:"slug"`
+	Specs             *PlanSpecs     `json:"specs,omitempty"`
+	Pricing           *PlanPrice     `json:"pricing,omitempty"`
+	AvailableIn       []PlanLocation `json:"available_in,omitempty"`        //nolint:tagliatelle
+	AvailableInMetros []PlanLocation `json:"available_in_metros,omitempty"` //nolint:tagliatelle
+}
+
+// PlanPrice is the price of the devices of a plan, in US dollars.
+type PlanPrice struct {
+	Hour  float64 `json:"hour"`
+	Month float64 `json:"month,omitempty"`
+}
+
+// PlanLocation is a facility or metro a plan is available in, with its price there.
+type PlanLocation struct {
+	Code  string     `json:"code,omitempty"`
+	Price *PlanPrice `json:"price,omitempty"`
+}
+
+// PlanSpecs is the hardware of the devices of a plan.
+type PlanSpecs struct {
+	CPUs   []PlanComponent `json:"cpus,omitempty"`
+	GPUs   []PlanComponent `json:"gpu,omitempty"` //nolint:tagliatelle
+	Memory *PlanMemory     `json:"memory,omitempty"`
+}
+
+// PlanComponent is a number of identical CPUs or GPUs of a plan.
+type PlanComponent struct {
+	Count int    `json:"count"`
+	Type  string `json:"type"`
+}
+
+// PlanMemory is the memory of the devices of a plan, such as "64GB".
+type PlanMemory struct {
+	Total string `json:"total"`
+}
+
+type planList struct {
+	Plans []Plan `json:"plans"`
+}
+
+//nolint:gochecknoglobals
+var cpuCoresRegexp = regexp.MustCompile(`(\d+)-Core`)
+
+// GetPlan returns the plan with the given slug, or nil if there is none.
+func (c *Client) GetPlan(ctx context.Context, slug string) (*Plan, error) {
+	var list planList
+
+	query := url.Values{"slug": {slug}, "include": {"available_in,available_in_metros"}}
+
+	if err := c.do(ctx, http.MethodGet, "plans", query, nil, &list); err != nil {
+		return nil, fmt.Errorf("failed to get plan %q: %w", slug, err)
+	}
+
+	for i := range list.Plans {
+		if list.Plans[i].Slug == slug {
+			return &list.Plans[i], nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil
+}
+
+// PlanCache looks plans up in the Equinix Metal plan catalog and caches them for an hour, including the
+// plans that do not exist, as the catalog rarely changes and many machines share the same plans.
+type PlanCache struct {
+	client *Client
+
+	mu    sync.Mutex
+	plans map[string]cachedPlan
+}
+
+type cachedPlan struct {
+	plan    *Plan
+	expires time.Time
+}
+
+// NewPlanCache returns a PlanCache looking the plans up with the given client.
+func NewPlanCache(client *Client) *PlanCache {
+	return &PlanCache{ //nolint:exhaustivestruct
+		client: client,
+		plans:  map[string]cachedPlan{},
+	}
+}
+
+// GetPlan returns the plan with the given slug, or nil if there is none.
+func (c *PlanCache) GetPlan(ctx context.Context, slug string) (*Plan, error) {
+	c.mu.Lock()
+	cached, ok := c.plans[slug]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(cached.expires) {
+		return cached.plan, nil
+	}
+
+	plan, err := c.client.GetPlan(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.plans[slug] = cachedPlan{plan: plan, expires: time.Now().Add(planCacheTTL)}
+	c.mu.Unlock()
+
+	return plan, nil
+}
+
+// Architecture returns the CPU architecture of the devices of the plan.
+func (p *Plan) Architecture() string {
+	if strings.Contains(p.Slug, "arm") {
+		return ArchitectureARM64
+	}
+
+	if p.Specs != nil {
+		for _, cpu := range p.Specs.CPUs {
+			if strings.Contains(strings.ToLower(cpu.Type), "ampere") {
+				return ArchitectureARM64
+			}
+		}
+	}
+
+	return ArchitectureAMD64
+}
+
+// CPUCores returns the number of CPU cores of the devices of the plan. The core count is taken from the
+// CPU model when it has one, such as "AMD EPYC 7402P 24-Core Processor", otherwise each CPU counts as one.
+func (p *Plan) CPUCores() int {
+	if p.Specs == nil {
+		return 0
+	}
+
+	cores := 0
+
+	for _, cpu := range p.Specs.CPUs {
+		perCPU := 1
+		if match := cpuCoresRegexp.FindStringSubmatch(cpu.Type); match != nil {
+			if n, err := strconv.Atoi(match[1]); err == nil {
+				perCPU = n
+			}
+		}
+
+		cores += cpu.Count * perCPU
+	}
+
+	return cores
+}
+
+// GPUs returns the number of GPUs of the devices of the plan.
+func (p *Plan) GPUs() int {
+	if p.Specs == nil {
+		return 0
+	}
+
+	gpus := 0
+	for _, gpu := range p.Specs.GPUs {
+		gpus += gpu.Count
+	}
+
+	return gpus
+}
+
+// MemoryBytes returns the memory of the devices of the plan in bytes, or 0 if it is unknown.
+func (p *Plan) MemoryBytes() int64 {
+	if p.Specs == nil || p.Specs.Memory == nil {
+		return 0
+	}
+
+	total := strings.ToUpper(strings.TrimSpace(p.Specs.Memory.Total))
+
+	units := []struct {
+		suffix string
+		shift  uint
+	}{
+		{"TB", 40}, //nolint:gomnd
+		{"GB", 30}, //nolint:gomnd
+		{"MB", 20}, //nolint:gomnd
+	}
+
+	for _, unit := range units {
+		if !strings.HasSuffix(total, unit.suffix) {
+			continue
+		}
+
+		n, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(total, unit.suffix)), 10, 64)
+		if err != nil {
+			return 0
+		}
+
+		return n << unit.shift
+	}
+
+	return 0
+}
+
+// Price returns the price of the devices of the plan in a facility or metro, falling back to the
+// price of the plan when there is no specific one for the location. It returns nil if the plan has no price.
+func (p *Plan) Price(metro, facility string) *PlanPrice {
+	for _, location := range p.AvailableIn {
+		if facility != "" && location.Code == facility && location.Price != nil {
+			return location.Price
+		}
+	}
+
+	for _, location := range p.AvailableInMetros {
+		if metro != "" && location.Code == metro && location.Price != nil {
+			return location.Price
+		}
+	}
+
+	return p.Pricing
+}
+
+// ForBillingCycle returns the hourly and monthly price of a device billed with the given billing cycle.
+// Monthly billed devices are charged the monthly price when the plan has one, others the hourly price.
+func (p *PlanPrice) ForBillingCycle(billingCycle string) (float64, float64) {
+	if billingCycle == BillingCycleMonthly && p.Month > 0 {
+		return p.Month / HoursPerMonth, p.Month
+	}
+
+	return p.Hour, p.Hour * HoursPerMonth
+}
+
+// FormatPrice formats a price in US dollars, rounded to a hundredth of a cent.
+func FormatPrice(price float64) string {
+	return strconv.FormatFloat(math.Round(price*10000)/10000, 'f', -1, 64) //nolint:gomnd
+}
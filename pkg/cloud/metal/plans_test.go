@@ -0,0 +1,327 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPlanCPUCores(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		specs *PlanSpecs
+		want  int
+	}{
+		{
+			name:  "no specs",
+			specs: nil,
+			want:  0,
+		},
+		{
+			name: "core count in model",
+			specs: &PlanSpecs{ //nolint:exhaustivestruct
+				CPUs: []PlanComponent{{Count: 1, Type: "AMD EPYC 7402P 24-Core Processor"}},
+			},
+			want: 24,
+		},
+		{
+			name: "several CPUs with core count",
+			specs: &PlanSpecs{ //nolint:exhaustivestruct
+				CPUs: []PlanComponent{{Count: 2, Type: "Intel Xeon Gold 6338 32-Core Processor @ 2.0GHz"}},
+			},
+			want: 64,
+		},
+		{
+			name: "no core count in model",
+			specs: &PlanSpecs{ //nolint:exhaustivestruct
+				CPUs: []PlanComponent{{Count: 2, Type: "Intel Xeon E-2278G"}},
+			},
+			want: 2,
+		},
+		{
+			name: "mixed CPUs",
+			specs: &PlanSpecs{ //nolint:exhaustivestruct
+				CPUs: []PlanComponent{
+					{Count: 1, Type: "Ampere Altra Q80-30 80-Core Processor @ 3.0GHz"},
+					{Count: 1, Type: "Intel Xeon E-2278G"},
+				},
+			},
+			want: 81,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			plan := &Plan{Slug: "test", Specs: tt.specs} //nolint:exhaustivestruct
+
+			if got := plan.CPUCores(); got != tt.want {
+				t.Errorf("CPUCores() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanMemoryBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		memory *PlanMemory
+		want   int64
+	}{
+		{name: "unknown", memory: nil, want: 0},
+		{name: "gigabytes", memory: &PlanMemory{Total: "64GB"}, want: 64 << 30},
+		{name: "terabytes", memory: &PlanMemory{Total: "1TB"}, want: 1 << 40},
+		{name: "megabytes", memory: &PlanMemory{Total: "512MB"}, want: 512 << 20},
+		{name: "lower case with spaces", memory: &PlanMemory{Total: " 32 gb "}, want: 32 << 30},
+		{name: "unknown unit", memory: &PlanMemory{Total: "64GiB"}, want: 0},
+		{name: "not a number", memory: &PlanMemory{Total: "lots GB"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			plan := &Plan{Slug: "test", Specs: &PlanSpecs{Memory: tt.memory}} //nolint:exhaustivestruct
+
+			if got := plan.MemoryBytes(); got != tt.want {
+				t.Errorf("MemoryBytes() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanPrice(t *testing.T) {
+	t.Parallel()
+
+	plan := &Plan{ //nolint:exhaustivestruct
+		Slug:    "c3.small.x86",
+		Pricing: &PlanPrice{Hour: 0.5, Month: 0},
+		AvailableIn: []PlanLocation{
+			{Code: "da11", Price: &PlanPrice{Hour: 0.75, Month: 0}},
+			{Code: "sv15", Price: nil},
+		},
+		AvailableInMetros: []PlanLocation{
+			{Code: "da", Price: &PlanPrice{Hour: 0.6, Month: 0}},
+			{Code: "sv", Price: nil},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		plan     *Plan
+		metro    string
+		facility string
+		want     *PlanPrice
+	}{
+		{
+			name:     "facility price",
+			plan:     plan,
+			metro:    "da",
+			facility: "da11",
+			want:     &PlanPrice{Hour: 0.75, Month: 0},
+		},
+		{
+			name:     "metro price",
+			plan:     plan,
+			metro:    "da",
+			facility: "",
+			want:     &PlanPrice{Hour: 0.6, Month: 0},
+		},
+		{
+			name:     "metro price for facility without price",
+			plan:     plan,
+			metro:    "da",
+			facility: "da6",
+			want:     &PlanPrice{Hour: 0.6, Month: 0},
+		},
+		{
+			name:     "plan price",
+			plan:     plan,
+			metro:    "ny",
+			facility: "",
+			want:     &PlanPrice{Hour: 0.5, Month: 0},
+		},
+		{
+			name:     "plan price for locations without price",
+			plan:     plan,
+			metro:    "sv",
+			facility: "sv15",
+			want:     &PlanPrice{Hour: 0.5, Month: 0},
+		},
+		{
+			name:     "no price",
+			plan:     &Plan{Slug: "test"}, //nolint:exhaustivestruct
+			metro:    "da",
+			facility: "",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := tt.plan.Price(tt.metro, tt.facility)
+
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || *got != *tt.want:
+				t.Errorf("Price(%q, %q) = %+v, want %+v", tt.metro, tt.facility, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanPriceForBillingCycle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		price        PlanPrice
+		billingCycle string
+		wantHourly   float64
+		wantMonthly  float64
+	}{
+		{
+			name:         "hourly",
+			price:        PlanPrice{Hour: 1, Month: 500},
+			billingCycle: BillingCycleHourly,
+			wantHourly:   1,
+			wantMonthly:  HoursPerMonth,
+		},
+		{
+			name:         "monthly",
+			price:        PlanPrice{Hour: 1, Month: 365},
+			billingCycle: BillingCycleMonthly,
+			wantHourly:   0.5,
+			wantMonthly:  365,
+		},
+		{
+			name:         "monthly without monthly price",
+			price:        PlanPrice{Hour: 2, Month: 0},
+			billingCycle: BillingCycleMonthly,
+			wantHourly:   2,
+			wantMonthly:  2 * HoursPerMonth,
+		},
+		{
+			name:         "default billing cycle",
+			price:        PlanPrice{Hour: 0.5, Month: 300},
+			billingCycle: "",
+			wantHourly:   0.5,
+			wantMonthly:  0.5 * HoursPerMonth,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			hourly, monthly := tt.price.ForBillingCycle(tt.billingCycle)
+			if hourly != tt.wantHourly || monthly != tt.wantMonthly {
+				t.Errorf("ForBillingCycle(%q) = %v, %v, want %v, %v",
+					tt.billingCycle, hourly, monthly, tt.wantHourly, tt.wantMonthly)
+			}
+		})
+	}
+}
+
+func TestFormatPrice(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		price float64
+		want  string
+	}{
+		{price: 0, want: "0"},
+		{price: 1.5, want: "1.5"},
+		{price: 0.123456, want: "0.1235"},
+		{price: 365.0 / HoursPerMonth, want: "0.5"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.want, func(t *testing.T) {
+			t.Parallel()
+
+			if got := FormatPrice(tt.price); got != tt.want {
+				t.Errorf("FormatPrice(%v) = %q, want %q", tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanCache(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		list := planList{Plans: []Plan{}}
+		if slug := r.URL.Query().Get("slug"); slug == "c3.small.x86" {
+			list.Plans = append(list.Plans, Plan{Slug: slug}) //nolint:exhaustivestruct
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL, "token", server.Client())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	cache := NewPlanCache(client)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		plan, err := cache.GetPlan(ctx, "c3.small.x86")
+		if err != nil || plan == nil || plan.Slug != "c3.small.x86" {
+			t.Fatalf("GetPlan(c3.small.x86) = %v, %v, want plan c3.small.x86", plan, err)
+		}
+
+		plan, err = cache.GetPlan(ctx, "unknown")
+		if err != nil || plan != nil {
+			t.Fatalf("GetPlan(unknown) = %v, %v, want nil", plan, err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("GetPlan() sent %d requests, want 2", got)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// projectOwnerKey is the key of the custom data of a project recording the Cluster that created it.
+const projectOwnerKey = TagPrefix + ":cluster"
+
+// Project is an Equinix Metal project.
+type Project struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	CustomData map[string]interface{} `json:"customdata,omitempty"` //nolint:tagliatelle
+}
+
+// ProjectCreateRequest holds the parameters of a new project.
+type ProjectCreateRequest struct {
+	Name       string                 `json:"name"`
+	CustomData map[string]interface{} `json:"customdata,omitempty"` //nolint:tagliatelle
+}
+
+// ProjectOwnerCustomData returns the custom data marking a project as created for the given Cluster.
+func ProjectOwnerCustomData(namespace, name string) map[string]interface{} {
+	return map[string]interface{}{projectOwnerKey: namespace + "/" + name}
+}
+
+// IsOwnedBy returns true if the project was created for the given Cluster.
+func (p *Project) IsOwnedBy(namespace, name string) bool {
+	owner, ok := p.CustomData[projectOwnerKey].(string)
+
+	return ok && owner == namespace+"/"+name
+}
+
+type projectList struct {
+	Projects []Project `json:"projects"`
+	Meta     listMeta  `json:"meta"`
+}
+
+// ListOrganizationProjects returns all the projects of an organization.
+func (c *Client) ListOrganizationProjects(ctx context.Context, organizationID string) ([]Project, error) {
+	var projects []Project
+
+	path := "organizations/" + url.PathEscape(organizationID) + "/projects"
+
+	err := c.listPages(ctx, path, nil, func(page json.RawMessage) (listMeta, error) {
+		var list projectList
+		if err := json.Unmarshal(page, &list); err != nil {
+			return listMeta{}, fmt.Errorf("failed to decode project list: %w", err)
+		}
+
+		projects = append(projects, list.Projects...)
+
+		return list.Meta, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects of organization %q: %w", organizationID, err)
+	}
+
+	return projects, nil
+}
+
+// GetProject returns the project with the given ID.
+func (c *Client) GetProject(ctx context.Context, projectID string) (*Project, error) {
+	project := new(Project)
+
+	if err := c.do(ctx, http.MethodGet, "projects/"+url.PathEscape(projectID), nil, nil, project); err != nil {
+		return nil, fmt.Errorf("failed to get project %q: %w", projectID, err)
+	}
+
+	return project, nil
+}
+
+// GetOrganizationProjectByName returns the project of an organization with the given name, or nil if there is none.
+func (c *Client) GetOrganizationProjectByName(ctx context.Context, organizationID, name string) (*Project, error) {
+	projects, err := c.ListOrganizationProjects(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range projects {
+		if projects[i].Name == name {
+			return &projects[i], nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil
+}
+
+// CreateProject creates a new project in an organization.
+func (c *Client) CreateProject(
+	ctx context.Context,
+	organizationID string,
+	req *ProjectCreateRequest,
+) (*Project, error) {
+	project := new(Project)
+
+	path := "organizations/" + url.PathEscape(organizationID) + "/projects"
+	if err := c.do(ctx, http.MethodPost, path, nil, req, project); err != nil {
+		return nil, fmt.Errorf("failed to create project %q: %w", req.Name, err)
+	}
+
+	return project, nil
+}
+
+// DeleteProject deletes the project with the given ID. Projects can only be deleted once all
+// their devices are gone.
+func (c *Client) DeleteProject(ctx context.Context, projectID string) error {
+	if err := c.do(ctx, http.MethodDelete, "projects/"+url.PathEscape(projectID), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete project %q: %w", projectID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultQPS is the default sustained number of Equinix Metal API requests per second.
+	DefaultQPS = 5
+	// DefaultBurst is the default number of Equinix Metal API requests that can be sent at once.
+	DefaultBurst = 10
+	// DefaultMaxRetries is the default number of times a throttled or failed request is retried.
+	DefaultMaxRetries = 5
+	// DefaultMinBackoff is the default delay before the first retry of a request.
+	DefaultMinBackoff = time.Second
+	// DefaultMaxBackoff is the default maximum delay between two retries of a request.
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// RateLimitOptions configures the client-side rate limiting and retries of the Equinix Metal API requests.
+type RateLimitOptions struct {
+	// QPS is the sustained number of requests per second. Rate limiting is disabled when QPS is 0.
+	QPS float64
+	// Burst is the number of requests that can be sent at once. It must be at least 1 when QPS is
+	// positive, otherwise every request is rejected.
+	Burst int
+	// MaxRetries is the number of times a request is retried on 429 and 5xx responses.
+	MaxRetries int
+	// MinBackoff is the delay before the first retry, doubled on every attempt.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between two retries. A request is not retried when the
+	// Retry-After header of the response asks to wait longer than MaxBackoff.
+	MaxBackoff time.Duration
+}
+
+// DefaultRateLimitOptions returns the rate limiting options used when none are given to NewClient.
+func DefaultRateLimitOptions() RateLimitOptions {
+	return RateLimitOptions{
+		QPS:        DefaultQPS,
+		Burst:      DefaultBurst,
+		MaxRetries: DefaultMaxRetries,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithRateLimit configures the rate limiting and retries of the Client. The limiter is shared
+// by all the users of the Client.
+func WithRateLimit(opts RateLimitOptions) Option {
+	return func(c *Client) {
+		c.rateLimit = opts
+		c.limiter = newLimiter(opts)
+	}
+}
+
+func newLimiter(opts RateLimitOptions) *rate.Limiter {
+	if opts.QPS <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	return rate.NewLimiter(rate.Limit(opts.QPS), opts.Burst)
+}
+
+// RetryAfter returns how long to wait before retrying a request that failed because the
+// Equinix Metal API throttled it or was unavailable. It returns false for any other error.
+// The returned duration is 0 when the API did not send a Retry-After header.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.retryable() {
+		return 0, false
+	}
+
+	return apiErr.RetryAfter, true
+}
+
+// retryable returns true if the request might succeed when sent again.
+func (e *APIError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// backoff returns the delay before the next attempt of a request, or false if the request
+// must not be retried. Server errors are only retried for idempotent methods, since a
+// non idempotent request might have been processed, while throttled requests never were.
+func (c *Client) backoff(method string, attempt int, err error) (time.Duration, bool) {
+	if attempt >= c.rateLimit.MaxRetries {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.retryable() {
+		return 0, false
+	}
+
+	if apiErr.StatusCode != http.StatusTooManyRequests && !idempotent(method) {
+		return 0, false
+	}
+
+	if apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, apiErr.RetryAfter <= c.rateLimit.MaxBackoff
+	}
+
+	delay := c.rateLimit.MinBackoff
+	for i := 0; i < attempt && delay < c.rateLimit.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > c.rateLimit.MaxBackoff {
+		delay = c.rateLimit.MaxBackoff
+	}
+
+	return delay, true
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// parseRetryAfter parses the value of a Retry-After header, given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
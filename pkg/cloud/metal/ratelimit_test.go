@@ -0,0 +1,207 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewLimiter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    RateLimitOptions
+		allowed int
+	}{
+		{
+			name:    "disabled",
+			opts:    RateLimitOptions{QPS: 0, Burst: 0}, //nolint:exhaustivestruct
+			allowed: 100,
+		},
+		{
+			name:    "burst",
+			opts:    RateLimitOptions{QPS: 0.001, Burst: 3}, //nolint:exhaustivestruct
+			allowed: 3,
+		},
+		{
+			name:    "no burst rejects every request",
+			opts:    RateLimitOptions{QPS: 0.001, Burst: 0}, //nolint:exhaustivestruct
+			allowed: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			limiter := newLimiter(tt.opts)
+			now := time.Now()
+
+			allowed := 0
+
+			for i := 0; i < 100; i++ {
+				if limiter.AllowN(now, 1) {
+					allowed++
+				}
+			}
+
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d requests, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		err       error
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{
+			name:      "throttled with Retry-After",
+			err:       &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}, //nolint:exhaustivestruct
+			wantDelay: 7 * time.Second,
+			wantRetry: true,
+		},
+		{
+			name:      "unavailable without Retry-After",
+			err:       &APIError{StatusCode: http.StatusServiceUnavailable}, //nolint:exhaustivestruct
+			wantRetry: true,
+		},
+		{
+			name: "wrapped",
+			err: fmt.Errorf("failed to get device: %w",
+				&APIError{StatusCode: http.StatusBadGateway}), //nolint:exhaustivestruct
+			wantRetry: true,
+		},
+		{
+			name: "not found",
+			err:  &APIError{StatusCode: http.StatusNotFound}, //nolint:exhaustivestruct
+		},
+		{
+			name: "unprocessable",
+			err:  &APIError{StatusCode: http.StatusUnprocessableEntity}, //nolint:exhaustivestruct
+		},
+		{
+			name: "not an API error",
+			err:  errors.New("connection refused"), //nolint:goerr113
+		},
+		{
+			name: "nil",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			delay, retry := RetryAfter(tt.err)
+			if delay != tt.wantDelay || retry != tt.wantRetry {
+				t.Errorf("RetryAfter() = %s, %t, want %s, %t", delay, retry, tt.wantDelay, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	c := &Client{rateLimit: RateLimitOptions{ //nolint:exhaustivestruct
+		MaxRetries: 3,
+		MinBackoff: time.Second,
+		MaxBackoff: 3 * time.Second,
+	}}
+
+	throttled := &APIError{StatusCode: http.StatusTooManyRequests}      //nolint:exhaustivestruct
+	unavailable := &APIError{StatusCode: http.StatusServiceUnavailable} //nolint:exhaustivestruct
+
+	tests := []struct {
+		name      string
+		method    string
+		attempt   int
+		err       error
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{name: "first retry", method: http.MethodGet, err: throttled, wantDelay: time.Second, wantRetry: true},
+		{name: "doubled", method: http.MethodGet, attempt: 1, err: throttled, wantDelay: 2 * time.Second, wantRetry: true},
+		{name: "capped", method: http.MethodGet, attempt: 2, err: throttled, wantDelay: 3 * time.Second, wantRetry: true},
+		{name: "out of retries", method: http.MethodGet, attempt: 3, err: throttled},
+		{name: "throttled post", method: http.MethodPost, err: throttled, wantDelay: time.Second, wantRetry: true},
+		{name: "unavailable post", method: http.MethodPost, err: unavailable},
+		{
+			name:      "Retry-After",
+			method:    http.MethodGet,
+			err:       &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second}, //nolint:exhaustivestruct
+			wantDelay: 2 * time.Second,
+			wantRetry: true,
+		},
+		{
+			name:      "Retry-After longer than MaxBackoff",
+			method:    http.MethodGet,
+			err:       &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}, //nolint:exhaustivestruct
+			wantDelay: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			delay, retry := c.backoff(tt.method, tt.attempt, tt.err)
+			if delay != tt.wantDelay || retry != tt.wantRetry {
+				t.Errorf("backoff() = %s, %t, want %s, %t", delay, retry, tt.wantDelay, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "5", want: 5 * time.Second},
+		{value: "-1", want: 0},
+		{value: "soon", want: 0},
+		{value: "Mon, 02 Jan 2006 15:04:05 GMT", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
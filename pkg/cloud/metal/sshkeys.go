@@ -0,0 +1,89 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// SSHKey is an SSH public key registered in an Equinix Metal project.
+type SSHKey struct {
+	ID    string   `json:"id"`
+	Label string   `json:"label"`
+	Key   string   `json:"key"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// SSHKeyCreateRequest holds the parameters of a new project SSH key.
+type SSHKeyCreateRequest struct {
+	Label string   `json:"label"`
+	Key   string   `json:"key"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type sshKeyList struct {
+	SSHKeys []SSHKey `json:"ssh_keys"` //nolint:tagliatelle
+	Meta    listMeta `json:"meta"`
+}
+
+// ListProjectSSHKeys returns all the SSH keys of a project.
+func (c *Client) ListProjectSSHKeys(ctx context.Context, projectID string) ([]SSHKey, error) {
+	var keys []SSHKey
+
+	path := "projects/" + url.PathEscape(projectID) + "/ssh-keys"
+
+	err := c.listPages(ctx, path, nil, func(page json.RawMessage) (listMeta, error) {
+		var list sshKeyList
+		if err := json.Unmarshal(page, &list); err != nil {
+			return listMeta{}, fmt.Errorf("failed to decode SSH key list: %w", err)
+		}
+
+		keys = append(keys, list.SSHKeys...)
+
+		return list.Meta, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SSH keys of project %q: %w", projectID, err)
+	}
+
+	return keys, nil
+}
+
+// CreateProjectSSHKey registers a new SSH key in a project.
+func (c *Client) CreateProjectSSHKey(ctx context.Context, projectID string, req *SSHKeyCreateRequest) (*SSHKey, error) {
+	key := new(SSHKey)
+
+	path := "projects/" + url.PathEscape(projectID) + "/ssh-keys"
+	if err := c.do(ctx, http.MethodPost, path, nil, req, key); err != nil {
+		return nil, fmt.Errorf("failed to create SSH key %q: %w", req.Label, err)
+	}
+
+	return key, nil
+}
+
+// DeleteSSHKey deletes the SSH key with the given ID.
+func (c *Client) DeleteSSHKey(ctx context.Context, keyID string) error {
+	if err := c.do(ctx, http.MethodDelete, "ssh-keys/"+url.PathEscape(keyID), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete SSH key %q: %w", keyID, err)
+	}
+
+	return nil
+}
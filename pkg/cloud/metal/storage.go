@@ -0,0 +1,63 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+// Storage is the custom storage layout of a device.
+type Storage struct {
+	Disks       []Disk       `json:"disks,omitempty"`
+	RAID        []RAID       `json:"raid,omitempty"`
+	Filesystems []Filesystem `json:"filesystems,omitempty"`
+}
+
+// Disk is the partitioning of a device disk.
+type Disk struct {
+	Device     string      `json:"device"`
+	WipeTable  bool        `json:"wipeTable"`
+	Partitions []Partition `json:"partitions,omitempty"`
+}
+
+// Partition is a disk partition.
+type Partition struct {
+	Label  string `json:"label"`
+	Number int    `json:"number"`
+	Size   string `json:"size"`
+}
+
+// RAID is a software RAID array.
+type RAID struct {
+	Devices []string `json:"devices"`
+	Level   string   `json:"level"`
+	Name    string   `json:"name"`
+}
+
+// Filesystem is a filesystem created on a partition or RAID array.
+type Filesystem struct {
+	Mount FilesystemMount `json:"mount"`
+}
+
+// FilesystemMount describes how a filesystem is created and mounted.
+type FilesystemMount struct {
+	Device string            `json:"device"`
+	Format string            `json:"format"`
+	Point  string            `json:"point"`
+	Create *FilesystemCreate `json:"create,omitempty"`
+}
+
+// FilesystemCreate holds the options used when creating a filesystem.
+type FilesystemCreate struct {
+	Options []string `json:"options,omitempty"`
+}
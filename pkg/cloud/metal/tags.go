@@ -0,0 +1,153 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// TagPrefix is the prefix of all the ownership tags set by this provider.
+	TagPrefix = "cluster-api-provider-equinixmetal"
+	// ControlPlaneEndpointTag marks the IP reservation used as a cluster's control plane endpoint.
+	ControlPlaneEndpointTag = TagPrefix + ":control-plane-endpoint"
+
+	clusterTagPrefix = TagPrefix + ":cluster-id:"
+	machineTagPrefix = TagPrefix + ":machine-uid:"
+	managerTagPrefix = TagPrefix + ":manager-id:"
+	poolTagPrefix    = TagPrefix + ":hardware-reservation-pool:"
+)
+
+// ClusterTag returns the ownership tag for resources that belong to the given Cluster.
+func ClusterTag(namespace, name string) string {
+	return clusterTagPrefix + namespace + "/" + name
+}
+
+// MachineTag returns the ownership tag for a device that belongs to the EquinixMetalMachine with the given UID.
+func MachineTag(uid types.UID) string {
+	return machineTagPrefix + string(uid)
+}
+
+// ManagerTag returns the ownership tag for resources that belong to the management cluster with the given ID.
+func ManagerTag(managerID string) string {
+	return managerTagPrefix + managerID
+}
+
+// HardwareReservationPoolTag returns the tag retaining a hardware reservation for the machines of a pool.
+func HardwareReservationPoolTag(pool string) string {
+	return poolTagPrefix + pool
+}
+
+// HardwareReservationPoolFromTags returns the pool a hardware reservation is retained for by its tags.
+func HardwareReservationPoolFromTags(tags []string) (string, bool) {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, poolTagPrefix) {
+			return strings.TrimPrefix(tag, poolTagPrefix), true
+		}
+	}
+
+	return "", false
+}
+
+// IsOwnershipTag returns true if the tag is one of the ownership tags set by this provider.
+func IsOwnershipTag(tag string) bool {
+	return strings.HasPrefix(tag, TagPrefix+":")
+}
+
+// HasTags returns true if tags contains all the wanted tags.
+func HasTags(tags []string, wanted ...string) bool {
+	for _, w := range wanted {
+		found := false
+
+		for _, t := range tags {
+			if t == w {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// EqualTags returns true if both lists hold the same tags, in any order.
+func EqualTags(a, b []string) bool {
+	return len(a) == len(b) && HasTags(a, b...) && HasTags(b, a...)
+}
+
+// ReplaceOwnershipTags returns the tags with their ownership tags replaced by the given ones.
+func ReplaceOwnershipTags(tags []string, ownershipTags []string) []string {
+	replaced := make([]string, 0, len(tags)+len(ownershipTags))
+
+	for _, tag := range tags {
+		if !IsOwnershipTag(tag) {
+			replaced = append(replaced, tag)
+		}
+	}
+
+	return append(replaced, ownershipTags...)
+}
+
+// ClusterFromTags returns the namespace and name of the Cluster referenced by the ownership tags.
+func ClusterFromTags(tags []string) (types.NamespacedName, bool) {
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, clusterTagPrefix) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(tag, clusterTagPrefix), "/", 2) //nolint:gomnd
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {                   //nolint:gomnd
+			continue
+		}
+
+		return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, true
+	}
+
+	return types.NamespacedName{}, false
+}
+
+// MachineUIDFromTags returns the UID of the EquinixMetalMachine referenced by the ownership tags.
+func MachineUIDFromTags(tags []string) (types.UID, bool) {
+	uids := MachineUIDsFromTags(tags)
+	if len(uids) == 0 {
+		return "", false
+	}
+
+	return uids[0], true
+}
+
+// MachineUIDsFromTags returns the UIDs of all the EquinixMetalMachines referenced by the ownership tags.
+// Devices tagged before their machine tags were kept in sync may reference the previous UIDs of their
+// EquinixMetalMachine, for instance from before it was moved to another management cluster.
+func MachineUIDsFromTags(tags []string) []types.UID {
+	var uids []types.UID
+
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, machineTagPrefix) {
+			uids = append(uids, types.UID(strings.TrimPrefix(tag, machineTagPrefix)))
+		}
+	}
+
+	return uids
+}
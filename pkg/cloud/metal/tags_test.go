@@ -0,0 +1,114 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReplaceOwnershipTags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		tags          []string
+		ownershipTags []string
+		want          []string
+	}{
+		{
+			name:          "untagged",
+			ownershipTags: []string{ClusterTag("ns", "cluster"), MachineTag("uid")},
+			want:          []string{ClusterTag("ns", "cluster"), MachineTag("uid")},
+		},
+		{
+			name:          "stale ownership tags",
+			tags:          []string{"team:a", ClusterTag("ns", "old"), MachineTag("old-uid"), ManagerTag("old")},
+			ownershipTags: []string{ClusterTag("ns", "cluster"), MachineTag("uid"), ManagerTag("manager")},
+			want: []string{
+				"team:a", ClusterTag("ns", "cluster"), MachineTag("uid"), ManagerTag("manager"),
+			},
+		},
+		{
+			name:          "other tags are kept",
+			tags:          []string{"team:a", "cost-center:b"},
+			ownershipTags: []string{MachineTag("uid")},
+			want:          []string{"team:a", "cost-center:b", MachineTag("uid")},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := ReplaceOwnershipTags(tt.tags, tt.ownershipTags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReplaceOwnershipTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEqualTags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want bool
+	}{
+		{name: "empty", want: true},
+		{name: "same order", a: []string{"a", "b"}, b: []string{"a", "b"}, want: true},
+		{name: "other order", a: []string{"a", "b"}, b: []string{"b", "a"}, want: true},
+		{name: "missing tag", a: []string{"a", "b"}, b: []string{"a"}, want: false},
+		{name: "other tag", a: []string{"a", "b"}, b: []string{"a", "c"}, want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := EqualTags(tt.a, tt.b); got != tt.want {
+				t.Errorf("EqualTags(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagsOwners(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"team:a", ClusterTag("ns", "cluster"), MachineTag("uid"), MachineTag("old-uid")}
+
+	if got, ok := ClusterFromTags(tags); !ok || got != (types.NamespacedName{Namespace: "ns", Name: "cluster"}) {
+		t.Errorf("ClusterFromTags() = %v, %v, want ns/cluster", got, ok)
+	}
+
+	if got := MachineUIDsFromTags(tags); !reflect.DeepEqual(got, []types.UID{"uid", "old-uid"}) {
+		t.Errorf("MachineUIDsFromTags() = %v, want [uid old-uid]", got)
+	}
+
+	if _, ok := ClusterFromTags([]string{TagPrefix + ":cluster-id:ns"}); ok {
+		t.Errorf("ClusterFromTags() found a cluster in a tag without name")
+	}
+}
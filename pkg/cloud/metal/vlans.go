@@ -0,0 +1,72 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// VirtualNetwork is an Equinix Metal VLAN.
+type VirtualNetwork struct {
+	ID          string    `json:"id"`
+	VXLAN       int       `json:"vxlan"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	CreatedAt   time.Time `json:"created_at"` //nolint:tagliatelle
+}
+
+type virtualNetworkList struct {
+	VirtualNetworks []VirtualNetwork `json:"virtual_networks"` //nolint:tagliatelle
+	Meta            listMeta         `json:"meta"`
+}
+
+// ListVirtualNetworks returns all the VLANs of a project.
+func (c *Client) ListVirtualNetworks(ctx context.Context, projectID string) ([]VirtualNetwork, error) {
+	var vlans []VirtualNetwork
+
+	path := "projects/" + url.PathEscape(projectID) + "/virtual-networks"
+
+	err := c.listPages(ctx, path, nil, func(page json.RawMessage) (listMeta, error) {
+		var list virtualNetworkList
+		if err := json.Unmarshal(page, &list); err != nil {
+			return listMeta{}, fmt.Errorf("failed to decode virtual network list: %w", err)
+		}
+
+		vlans = append(vlans, list.VirtualNetworks...)
+
+		return list.Meta, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list virtual networks of project %q: %w", projectID, err)
+	}
+
+	return vlans, nil
+}
+
+// DeleteVirtualNetwork deletes the VLAN with the given ID.
+func (c *Client) DeleteVirtualNetwork(ctx context.Context, vlanID string) error {
+	if err := c.do(ctx, http.MethodDelete, "virtual-networks/"+url.PathEscape(vlanID), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete virtual network %q: %w", vlanID, err)
+	}
+
+	return nil
+}
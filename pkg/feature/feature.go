@@ -0,0 +1,49 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package feature contains the provider specific feature gates. They are registered with the
+// cluster-api feature gates, so they are configured with the same --feature-gates flag.
+package feature
+
+import (
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/component-base/featuregate"
+	"sigs.k8s.io/cluster-api/feature"
+)
+
+const (
+	// SOSConsole is a feature gate for surfacing the serial over SSH console of the devices, and their
+	// root password in a Secret, on the EquinixMetalMachines. Disabling it removes them.
+	//
+	// alpha: v1.1
+	SOSConsole featuregate.Feature = "SOSConsole"
+)
+
+func init() { //nolint:gochecknoinits
+	runtime.Must(feature.MutableGates.Add(defaultFeatureGates()))
+}
+
+// defaultFeatureGates consists of all known provider specific feature keys.
+func defaultFeatureGates() map[featuregate.Feature]featuregate.FeatureSpec {
+	return map[featuregate.Feature]featuregate.FeatureSpec{
+		SOSConsole: {Default: false, PreRelease: featuregate.Alpha},
+	}
+}
+
+// Enabled returns true if the feature gate is enabled.
+func Enabled(f featuregate.Feature) bool {
+	return feature.Gates.Enabled(f)
+}
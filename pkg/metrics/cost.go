@@ -0,0 +1,158 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+)
+
+// costCollector reports the hourly and monthly cost of the machines and clusters, read from the status
+// of the EquinixMetalMachines and EquinixMetalClusters in the manager cache at scrape time.
+type costCollector struct {
+	reader             client.Reader
+	machineHourlyDesc  *prometheus.Desc
+	machineMonthlyDesc *prometheus.Desc
+	clusterHourlyDesc  *prometheus.Desc
+	clusterMonthlyDesc *prometheus.Desc
+}
+
+// RegisterCostCollector registers the machine and cluster cost gauges, using reader to list the
+// EquinixMetalMachines and EquinixMetalClusters.
+func RegisterCostCollector(reader client.Reader) error {
+	machineLabels := []string{"namespace", "cluster", "machine", "machine_type", "metro", "billing_cycle"}
+	clusterLabels := []string{"namespace", "cluster"}
+
+	collector := &costCollector{
+		reader: reader,
+		machineHourlyDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "machine", "hourly_cost_dollars"),
+			"Hourly price of the device of an EquinixMetalMachine in US dollars.",
+			machineLabels,
+			nil,
+		),
+		machineMonthlyDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "machine", "monthly_cost_dollars"),
+			"Monthly price of the device of an EquinixMetalMachine in US dollars.",
+			machineLabels,
+			nil,
+		),
+		clusterHourlyDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cluster", "hourly_cost_dollars"),
+			"Hourly price of the devices of an EquinixMetalCluster in US dollars.",
+			clusterLabels,
+			nil,
+		),
+		clusterMonthlyDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cluster", "monthly_cost_dollars"),
+			"Monthly price of the devices of an EquinixMetalCluster in US dollars.",
+			clusterLabels,
+			nil,
+		),
+	}
+
+	if err := metrics.Registry.Register(collector); err != nil {
+		return fmt.Errorf("failed to register cost collector: %w", err)
+	}
+
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *costCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.machineHourlyDesc
+	ch <- c.machineMonthlyDesc
+	ch <- c.clusterHourlyDesc
+	ch <- c.clusterMonthlyDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *costCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	machines := new(infrav1.EquinixMetalMachineList)
+	if err := c.reader.List(ctx, machines); err != nil {
+		ch <- prometheus.NewInvalidMetric(c.machineHourlyDesc, err)
+	} else {
+		for i := range machines.Items {
+			m := &machines.Items[i]
+
+			cost := m.Status.Cost
+			if cost == nil || m.Spec.ProviderID == nil {
+				continue
+			}
+
+			labels := []string{
+				m.Namespace, m.Labels[clusterv1.ClusterLabelName], m.Name, cost.MachineType, cost.Metro, cost.BillingCycle,
+			}
+			collectPrice(ch, c.machineHourlyDesc, cost.HourlyPrice, labels)
+			collectPrice(ch, c.machineMonthlyDesc, cost.MonthlyPrice, labels)
+		}
+	}
+
+	clusters := new(infrav1.EquinixMetalClusterList)
+	if err := c.reader.List(ctx, clusters); err != nil {
+		ch <- prometheus.NewInvalidMetric(c.clusterHourlyDesc, err)
+
+		return
+	}
+
+	for i := range clusters.Items {
+		cluster := &clusters.Items[i]
+		if cluster.Status.Cost == nil {
+			continue
+		}
+
+		labels := []string{cluster.Namespace, ownerClusterName(cluster)}
+		collectPrice(ch, c.clusterHourlyDesc, cluster.Status.Cost.HourlyPrice, labels)
+		collectPrice(ch, c.clusterMonthlyDesc, cluster.Status.Cost.MonthlyPrice, labels)
+	}
+}
+
+// collectPrice sends a gauge for a price recorded on a status, skipping prices that can't be parsed.
+func collectPrice(ch chan<- prometheus.Metric, desc *prometheus.Desc, price string, labels []string) {
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+}
+
+// ownerClusterName returns the name of the Cluster owning an EquinixMetalCluster.
+func ownerClusterName(equinixMetalCluster *infrav1.EquinixMetalCluster) string {
+	if name, ok := equinixMetalCluster.Labels[clusterv1.ClusterLabelName]; ok {
+		return name
+	}
+
+	for _, ref := range equinixMetalCluster.OwnerReferences {
+		if ref.Kind == "Cluster" {
+			return ref.Name
+		}
+	}
+
+	return ""
+}
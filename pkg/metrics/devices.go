@@ -0,0 +1,100 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-equinixmetal/api/v1beta1"
+)
+
+const (
+	collectTimeout = 10 * time.Second
+	unknownState   = "unknown"
+)
+
+// deviceStateCollector reports the number of devices per state and cluster, computed from the
+// EquinixMetalMachines in the manager cache at scrape time.
+type deviceStateCollector struct {
+	reader client.Reader
+	desc   *prometheus.Desc
+}
+
+// RegisterDeviceStateCollector registers the devices per state gauge, using reader to list the EquinixMetalMachines.
+func RegisterDeviceStateCollector(reader client.Reader) error {
+	collector := &deviceStateCollector{
+		reader: reader,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "devices"),
+			"Number of Equinix Metal devices by cluster and state.",
+			[]string{"namespace", "cluster", "state"},
+			nil,
+		),
+	}
+
+	if err := metrics.Registry.Register(collector); err != nil {
+		return fmt.Errorf("failed to register device state collector: %w", err)
+	}
+
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *deviceStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *deviceStateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	machines := new(infrav1.EquinixMetalMachineList)
+	if err := c.reader.List(ctx, machines); err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+
+		return
+	}
+
+	type key struct{ namespace, cluster, state string }
+
+	counts := map[key]float64{}
+
+	for _, m := range machines.Items {
+		if m.Spec.ProviderID == nil {
+			continue
+		}
+
+		state := unknownState
+		if m.Status.InstanceStatus != nil {
+			state = string(*m.Status.InstanceStatus)
+		}
+
+		counts[key{m.Namespace, m.Labels[clusterv1.ClusterLabelName], state}]++
+	}
+
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, count, k.namespace, k.cluster, k.state)
+	}
+}
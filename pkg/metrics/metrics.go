@@ -0,0 +1,112 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics contains the provider specific Prometheus metrics, registered with the
+// controller-runtime metrics registry so they are served by the manager metrics endpoint.
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "capem"
+
+	// ErrorCode is the code label used for Metal API requests that did not get a response.
+	ErrorCode = "error"
+)
+
+var (
+	// MetalAPIRequestsTotal counts the Equinix Metal API requests by endpoint, method and status code.
+	MetalAPIRequestsTotal = prometheus.NewCounterVec( //nolint:gochecknoglobals
+		prometheus.CounterOpts{ //nolint:exhaustivestruct
+			Namespace: namespace,
+			Name:      "metal_api_requests_total",
+			Help:      "Number of Equinix Metal API requests by endpoint, method and status code.",
+		},
+		[]string{"endpoint", "method", "code"},
+	)
+
+	// MetalAPIRequestDuration observes the latency of the Equinix Metal API requests.
+	MetalAPIRequestDuration = prometheus.NewHistogramVec( //nolint:gochecknoglobals
+		prometheus.HistogramOpts{ //nolint:exhaustivestruct
+			Namespace: namespace,
+			Name:      "metal_api_request_duration_seconds",
+			Help:      "Latency of Equinix Metal API requests by endpoint, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"endpoint", "method", "code"},
+	)
+
+	// DeviceProvisioningDuration observes the time it takes for a device to become active after its creation.
+	DeviceProvisioningDuration = prometheus.NewHistogramVec( //nolint:gochecknoglobals
+		prometheus.HistogramOpts{ //nolint:exhaustivestruct
+			Namespace: namespace,
+			Name:      "device_provisioning_duration_seconds",
+			Help:      "Time between the creation of a device and it becoming active, by plan and metro.",
+			// 1 minute to ~2 hours.
+			Buckets: prometheus.ExponentialBuckets(60, 1.5, 12), //nolint:gomnd
+		},
+		[]string{"plan", "metro"},
+	)
+
+	// uuidPattern matches the IDs of Equinix Metal resources in request paths.
+	uuidPattern = regexp.MustCompile( //nolint:gochecknoglobals
+		`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+	)
+)
+
+func init() { //nolint:gochecknoinits
+	metrics.Registry.MustRegister(
+		MetalAPIRequestsTotal,
+		MetalAPIRequestDuration,
+		DeviceProvisioningDuration,
+	)
+}
+
+// ObserveMetalAPIRequest records an Equinix Metal API request. A statusCode of 0 means that
+// the request failed without a response.
+func ObserveMetalAPIRequest(method, path string, statusCode int, duration time.Duration) {
+	code := ErrorCode
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+
+	endpoint := Endpoint(path)
+
+	MetalAPIRequestsTotal.WithLabelValues(endpoint, method, code).Inc()
+	MetalAPIRequestDuration.WithLabelValues(endpoint, method, code).Observe(duration.Seconds())
+}
+
+// Endpoint returns the path of a request with the resource IDs replaced by a placeholder,
+// to keep the cardinality of the endpoint label bounded.
+func Endpoint(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range segments {
+		if uuidPattern.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
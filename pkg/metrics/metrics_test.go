@@ -0,0 +1,60 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+)
+
+func TestEndpoint(t *testing.T) {
+	t.Parallel()
+
+	const id = "2f1a2b9c-1d3e-4f5a-8b6c-7d8e9f0a1b2c"
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "collection", path: "plans", want: "plans"},
+		{name: "resource", path: "devices/" + id, want: "devices/{id}"},
+		{name: "nested collection", path: "projects/" + id + "/devices", want: "projects/{id}/devices"},
+		{name: "action", path: "devices/" + id + "/actions", want: "devices/{id}/actions"},
+		{
+			name: "several IDs",
+			path: "projects/" + id + "/bgp/neighbors/" + id,
+			want: "projects/{id}/bgp/neighbors/{id}",
+		},
+		{name: "upper case ID", path: "ips/2F1A2B9C-1D3E-4F5A-8B6C-7D8E9F0A1B2C", want: "ips/{id}"},
+		{name: "leading and trailing slashes", path: "/devices/" + id + "/", want: "devices/{id}"},
+		{name: "not an ID", path: "hardware-reservations/next-available", want: "hardware-reservations/next-available"},
+		{name: "truncated ID", path: "devices/2f1a2b9c-1d3e-4f5a-8b6c", want: "devices/2f1a2b9c-1d3e-4f5a-8b6c"},
+		{name: "ID with suffix", path: "devices/" + id + "x", want: "devices/" + id + "x"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := Endpoint(tt.path); got != tt.want {
+				t.Errorf("Endpoint(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}